@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"os"
 	"strings"
@@ -39,10 +40,15 @@ func UseCustomConfigProvider(params *service.CollectorSettings) error {
 	// to get these, we take the command the service uses to start, parse the flags, and read the values
 	var err error
 	tempCmd := service.NewCommand(*params)
-	_, flags, err := tempCmd.Find(os.Args[1:])
+	tempCmd.AddCommand(NewMigrateCommand())
+	foundCmd, flags, err := tempCmd.Find(os.Args[1:])
 	if err != nil {
 		return err
 	}
+	if foundCmd != tempCmd {
+		// subcommands don't run the collector, so there's no config to load
+		return nil
+	}
 
 	err = tempCmd.ParseFlags(flags)
 	if err != nil {
@@ -80,17 +86,37 @@ func UseCustomConfigProvider(params *service.CollectorSettings) error {
 }
 
 func NewConfigProvider(locations []string, setFlags []string) (service.ConfigProvider, error) {
-	settings := NewConfigProviderSettings(locations, setFlags)
-	return service.NewConfigProvider(settings)
+	watchingProvider := globprovider.NewWatching()
+	settings := NewConfigProviderSettings(locations, setFlags, watchingProvider)
+	cp, err := service.NewConfigProvider(settings)
+	if err != nil {
+		return nil, err
+	}
+	return &configProvider{ConfigProvider: cp, watchingProvider: watchingProvider}, nil
+}
+
+// configProvider shuts down the watching glob provider before the config provider, because
+// the config provider closes its watch channel before it stops watching the configuration.
+type configProvider struct {
+	service.ConfigProvider
+	watchingProvider confmap.Provider
+}
+
+func (cp *configProvider) Shutdown(ctx context.Context) error {
+	watchingErr := cp.watchingProvider.Shutdown(ctx)
+	if err := cp.ConfigProvider.Shutdown(ctx); err != nil {
+		return err
+	}
+	return watchingErr
 }
 
 // see https://github.com/open-telemetry/opentelemetry-collector/blob/72011ca22dff6614d518768b3bb53a1193c6ad02/service/command.go#L38
 // for the logic we're emulating here
-// we only add the glob provider, everything else should be the same
-func NewConfigProviderSettings(locations []string, setFlags []string) service.ConfigProviderSettings {
+// we only add the glob providers, everything else should be the same
+func NewConfigProviderSettings(locations []string, setFlags []string, watchingProvider confmap.Provider) service.ConfigProviderSettings {
 	return service.ConfigProviderSettings{
 		Locations:    locations,
-		MapProviders: makeMapProvidersMap(fileprovider.New(), envprovider.New(), yamlprovider.New(), globprovider.New()),
+		MapProviders: makeMapProvidersMap(fileprovider.New(), envprovider.New(), yamlprovider.New(), globprovider.New(), watchingProvider),
 		MapConverters: []confmap.Converter{
 			overwritepropertiesconverter.New(setFlags),
 			expandconverter.New(),
@@ -0,0 +1,31 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"go.opentelemetry.io/collector/service"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension"
+)
+
+// UseSelfObservabilityLogging makes the collector logs available to
+// sumologicextension, so that they can be forwarded to Sumo Logic
+// when self observability is enabled.
+func UseSelfObservabilityLogging(params *service.CollectorSettings) {
+	params.LoggingOptions = append(params.LoggingOptions,
+		zap.WrapCore(sumologicextension.WrapCoreWithSelfObservability),
+	)
+}
@@ -0,0 +1,748 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// This file contains the `migrate` subcommand which converts Installed Collector
+// configuration (user.properties and sources JSON) into collector configuration.
+// The mapping follows docs/Migration.md.
+
+const (
+	migrateExtension = "sumologic"
+	migrateExporter  = "sumologic"
+
+	sourceTypeLocalFile   = "LocalFile"
+	sourceTypeSyslog      = "Syslog"
+	sourceTypeSystemStats = "SystemStats"
+
+	filterTypeInclude = "Include"
+	filterTypeExclude = "Exclude"
+
+	// installTokenPlaceholder is used when user.properties doesn't contain
+	// the installation token, so that it can be provided via environment.
+	installTokenPlaceholder = "${SUMOLOGIC_INSTALL_TOKEN}"
+)
+
+// NewMigrateCommand returns the `migrate` subcommand.
+func NewMigrateCommand() *cobra.Command {
+	var (
+		sourcesPath        string
+		userPropertiesPath string
+		outputPath         string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Converts Installed Collector configuration into collector configuration",
+		Long: "Converts Installed Collector user.properties and sources JSON into collector configuration.\n" +
+			"The report of settings which could not be converted is printed to stderr.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			props := userProperties{}
+			if userPropertiesPath != "" {
+				var err error
+				if props, err = readUserProperties(userPropertiesPath); err != nil {
+					return err
+				}
+			}
+
+			if sourcesPath == "" {
+				sourcesPath = props.sourcesPath()
+			}
+			if sourcesPath == "" {
+				return errors.New("sources JSON has to be provided either with --sources or in user.properties")
+			}
+
+			sources, err := readSources(sourcesPath)
+			if err != nil {
+				return err
+			}
+
+			out, report, err := migrate(props, sources)
+			if err != nil {
+				return err
+			}
+
+			if outputPath == "" {
+				if _, err := cmd.OutOrStdout().Write(out); err != nil {
+					return err
+				}
+			} else if err := os.WriteFile(outputPath, out, 0600); err != nil {
+				return fmt.Errorf("failed to write configuration to %s: %w", outputPath, err)
+			}
+
+			writeReport(cmd.ErrOrStderr(), report)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&sourcesPath, "sources", "", "Path to sources JSON file or to a directory with sources JSON files")
+	cmd.Flags().StringVar(&userPropertiesPath, "user-properties", "", "Path to user.properties file")
+	cmd.Flags().StringVar(&outputPath, "output", "", "Path to write the configuration to (default stdout)")
+
+	return cmd
+}
+
+func writeReport(w io.Writer, report []string) {
+	if len(report) == 0 {
+		fmt.Fprintln(w, "All settings have been converted.")
+		return
+	}
+
+	fmt.Fprintln(w, "The following settings could not be converted:")
+	for _, line := range report {
+		fmt.Fprintf(w, "  - %s\n", line)
+	}
+}
+
+// userProperties holds the Installed Collector user.properties entries.
+type userProperties map[string]string
+
+// userPropertiesUnsupported lists user.properties keys which don't have
+// a counterpart in the collector configuration.
+var userPropertiesUnsupported = []string{
+	"accessid", "accesskey", "disableActionSource", "disableScriptSource",
+	"disableUpgrade", "enableActionSource", "enableScriptSource", "fipsJce",
+	"proxyHost", "proxyNtlmDomain", "proxyPassword", "proxyPort", "proxyUser",
+	"skipAccessKeyRemoval", "targetCPU", "url",
+}
+
+func (p userProperties) sourcesPath() string {
+	if v := p["syncSources"]; v != "" {
+		return v
+	}
+	return p["sources"]
+}
+
+// readUserProperties reads the Java properties file.
+// Only the subset of the format used by Installed Collector is supported:
+// `key=value` or `key: value` entries, comments and line continuations.
+func readUserProperties(path string) (userProperties, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user.properties: %w", err)
+	}
+	defer f.Close()
+
+	props := userProperties{}
+	scanner := bufio.NewScanner(f)
+	var entry string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if entry == "" && (line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!")) {
+			continue
+		}
+
+		if strings.HasSuffix(line, `\`) {
+			entry += strings.TrimSuffix(line, `\`)
+			continue
+		}
+		entry += line
+
+		i := strings.IndexAny(entry, "=:")
+		if i > 0 {
+			props[strings.TrimSpace(entry[:i])] = strings.TrimSpace(entry[i+1:])
+		}
+		entry = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read user.properties: %w", err)
+	}
+
+	return props, nil
+}
+
+type installedCollectorSources struct {
+	Source  *installedCollectorSource  `json:"source"`
+	Sources []installedCollectorSource `json:"sources"`
+}
+
+type installedCollectorFilter struct {
+	FilterType string `json:"filterType"`
+	Name       string `json:"name"`
+	Regexp     string `json:"regexp"`
+}
+
+type installedCollectorSource struct {
+	SourceType  string                     `json:"sourceType"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Category    string                     `json:"category"`
+	HostName    string                     `json:"hostName"`
+	Fields      map[string]interface{}     `json:"fields"`
+	Filters     []installedCollectorFilter `json:"filters"`
+
+	// Timestamp and cutoff related settings aren't converted.
+	TimeZone           string `json:"timeZone"`
+	ForceTimeZone      bool   `json:"forceTimeZone"`
+	CutoffTimestamp    int64  `json:"cutoffTimestamp"`
+	CutoffRelativeTime string `json:"cutoffRelativeTime"`
+
+	// LocalFile
+	PathExpression             string   `json:"pathExpression"`
+	Denylist                   []string `json:"denylist"`
+	Blacklist                  []string `json:"blacklist"`
+	Encoding                   string   `json:"encoding"`
+	MultilineProcessingEnabled bool     `json:"multilineProcessingEnabled"`
+	UseAutolineMatching        bool     `json:"useAutolineMatching"`
+	ManualPrefixRegexp         string   `json:"manualPrefixRegexp"`
+
+	// Syslog
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`
+
+	// SystemStats
+	Interval int64    `json:"interval"`
+	Metrics  []string `json:"metrics"`
+}
+
+// readSources reads sources from the JSON file or from all JSON files
+// in the directory, the same way Installed Collector does for `syncSources`.
+func readSources(path string) ([]installedCollectorSource, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sources: %w", err)
+	}
+
+	files := []string{path}
+	if fi.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	var sources []installedCollectorSource
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sources: %w", err)
+		}
+
+		var s installedCollectorSources
+		if err := json.Unmarshal(content, &s); err != nil {
+			return nil, fmt.Errorf("failed to parse sources file %s: %w", file, err)
+		}
+		if s.Source != nil {
+			sources = append(sources, *s.Source)
+		}
+		sources = append(sources, s.Sources...)
+	}
+
+	return sources, nil
+}
+
+// migration holds the state of the configuration being built.
+type migration struct {
+	props userProperties
+
+	extensions *yaml.Node
+	receivers  *yaml.Node
+	processors *yaml.Node
+	exporters  *yaml.Node
+	pipelines  *yaml.Node
+
+	names  map[string]bool
+	report []string
+}
+
+// migrate converts user.properties and sources into the collector
+// configuration. It returns the configuration YAML together with the list
+// of settings which could not be converted.
+func migrate(props userProperties, sources []installedCollectorSource) ([]byte, []string, error) {
+	m := &migration{
+		props:      props,
+		extensions: mappingNode(),
+		receivers:  mappingNode(),
+		processors: mappingNode(),
+		exporters:  mappingNode(),
+		pipelines:  mappingNode(),
+		names:      map[string]bool{},
+	}
+
+	m.migrateUserProperties()
+	for i, source := range sources {
+		m.migrateSource(i, source)
+	}
+
+	exporter := mappingNode()
+	auth := mappingNode()
+	addEntry(auth, "authenticator", stringNode(migrateExtension))
+	addEntry(exporter, "auth", auth)
+	addEntry(m.exporters, migrateExporter, exporter)
+
+	service := mappingNode()
+	addEntry(service, "extensions", sequenceNode(migrateExtension))
+	addEntry(service, "pipelines", m.pipelines)
+
+	root := mappingNode()
+	addEntry(root, "extensions", m.extensions)
+	addEntry(root, "receivers", m.receivers)
+	addEntry(root, "processors", m.processors)
+	addEntry(root, "exporters", m.exporters)
+	addEntry(root, "service", service)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return buf.Bytes(), m.report, nil
+}
+
+func (m *migration) reportf(format string, args ...interface{}) {
+	m.report = append(m.report, fmt.Sprintf(format, args...))
+}
+
+func (m *migration) migrateUserProperties() {
+	ext := mappingNode()
+
+	token := m.props["token"]
+	if token == "" {
+		token = installTokenPlaceholder
+		m.reportf("user.properties: installation token not found, set SUMOLOGIC_INSTALL_TOKEN environment variable")
+	}
+	addEntry(ext, "install_token", stringNode(token))
+
+	for _, p := range []struct{ key, option string }{
+		{"name", "collector_name"},
+		{"description", "collector_description"},
+		{"category", "collector_category"},
+		{"timeZone", "time_zone"},
+	} {
+		if v := m.props[p.key]; v != "" {
+			addEntry(ext, p.option, stringNode(v))
+		}
+	}
+
+	if v := m.props["fields"]; v != "" {
+		fields := mappingNode()
+		for _, field := range strings.Split(v, ",") {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				m.reportf("user.properties: invalid field %q", field)
+				continue
+			}
+			addEntry(fields, strings.TrimSpace(key), stringNode(strings.TrimSpace(value)))
+		}
+		addEntry(ext, "collector_fields", fields)
+	}
+
+	for _, key := range []string{"clobber", "ephemeral"} {
+		if v, ok := m.props[key]; ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				m.reportf("user.properties: invalid %s value %q", key, v)
+				continue
+			}
+			addEntry(ext, key, boolNode(b))
+		}
+	}
+
+	for _, key := range userPropertiesUnsupported {
+		if _, ok := m.props[key]; ok {
+			m.reportf("user.properties: %s is not supported", key)
+		}
+	}
+
+	addEntry(m.extensions, migrateExtension, ext)
+}
+
+// componentName returns a unique name for the components created for the source.
+func (m *migration) componentName(i int, source installedCollectorSource) string {
+	name := strings.TrimSpace(source.Name)
+	if name == "" {
+		name = fmt.Sprintf("%s_%d", strings.ToLower(source.SourceType), i)
+	}
+
+	unique := name
+	for n := 2; m.names[unique]; n++ {
+		unique = fmt.Sprintf("%s_%d", name, n)
+	}
+	m.names[unique] = true
+	return unique
+}
+
+func (m *migration) migrateSource(i int, source installedCollectorSource) {
+	var (
+		receivers  []string
+		processors []string
+		signal     string
+		name       = m.componentName(i, source)
+	)
+
+	switch source.SourceType {
+	case sourceTypeLocalFile:
+		signal = "logs"
+		receivers = m.migrateLocalFile(name, source)
+	case sourceTypeSyslog:
+		signal = "logs"
+		receivers = m.migrateSyslog(name, source)
+		processors = append(processors, m.addProcessor("sumologic_syslog", name, mappingNode()))
+	case sourceTypeSystemStats:
+		signal = "metrics"
+		receivers = m.migrateHostMetrics(name, source)
+		processors = append(processors, m.addProcessor("sumologic_schema", name, mappingNode()))
+	default:
+		m.reportf("source %q: source type %s is not supported", name, source.SourceType)
+		return
+	}
+
+	processors = append(processors, m.migrateSourceAttributes(name, source))
+	if len(source.Fields) > 0 {
+		processors = append(processors, m.migrateFields(name, source.Fields))
+	}
+	if len(source.Filters) > 0 {
+		if signal == "logs" {
+			if p := m.migrateFilters(name, source.Filters); p != "" {
+				processors = append(processors, p)
+			}
+		} else {
+			m.reportf("source %q: filters are supported only for logs", name)
+		}
+	}
+
+	if source.TimeZone != "" || source.ForceTimeZone {
+		m.reportf("source %q: time zone settings are not supported, use time_zone in sumologic extension", name)
+	}
+	if source.CutoffTimestamp != 0 || source.CutoffRelativeTime != "" {
+		m.reportf("source %q: cutoff time is not supported", name)
+	}
+
+	pipeline := mappingNode()
+	addEntry(pipeline, "receivers", sequenceNode(receivers...))
+	addEntry(pipeline, "processors", sequenceNode(processors...))
+	addEntry(pipeline, "exporters", sequenceNode(migrateExporter))
+	addEntry(m.pipelines, signal+"/"+name, pipeline)
+}
+
+// addReceiver adds the receiver with the description as a comment
+// and returns its ID.
+func (m *migration) addReceiver(typ string, name string, description string, cfg *yaml.Node) string {
+	id := typ + "/" + name
+	key := addEntry(m.receivers, id, cfg)
+	if description != "" {
+		key.HeadComment = description
+	}
+	return id
+}
+
+func (m *migration) addProcessor(typ string, name string, cfg *yaml.Node) string {
+	id := typ + "/" + name
+	addEntry(m.processors, id, cfg)
+	return id
+}
+
+func (m *migration) migrateLocalFile(name string, source installedCollectorSource) []string {
+	cfg := mappingNode()
+	addEntry(cfg, "include", sequenceNode(source.PathExpression))
+	if exclude := append(source.Denylist, source.Blacklist...); len(exclude) > 0 {
+		addEntry(cfg, "exclude", sequenceNode(exclude...))
+	}
+	addEntry(cfg, "include_file_name", boolNode(false))
+	addEntry(cfg, "include_file_path_resolved", boolNode(true))
+	addEntry(cfg, "start_at", stringNode("beginning"))
+	if source.Encoding != "" {
+		addEntry(cfg, "encoding", stringNode(strings.ToLower(source.Encoding)))
+	}
+
+	if source.MultilineProcessingEnabled {
+		if !source.UseAutolineMatching && source.ManualPrefixRegexp != "" {
+			multiline := mappingNode()
+			addEntry(multiline, "line_start_pattern", stringNode(source.ManualPrefixRegexp))
+			addEntry(cfg, "multiline", multiline)
+		} else {
+			m.reportf("source %q: automatic multiline boundary detection is not supported, logs are read line by line", name)
+		}
+	}
+
+	return []string{m.addReceiver("filelog", name, source.Description, cfg)}
+}
+
+func (m *migration) migrateSyslog(name string, source installedCollectorSource) []string {
+	port := source.Port
+	if port == 0 {
+		port = 514
+	}
+
+	var typ string
+	switch strings.ToUpper(source.Protocol) {
+	case "TCP":
+		typ = "tcplog"
+	case "", "UDP":
+		typ = "udplog"
+	default:
+		m.reportf("source %q: syslog protocol %s is not supported", name, source.Protocol)
+		return nil
+	}
+
+	cfg := mappingNode()
+	addEntry(cfg, "listen_address", stringNode(fmt.Sprintf("0.0.0.0:%d", port)))
+	addEntry(cfg, "add_attributes", boolNode(true))
+
+	return []string{m.addReceiver(typ, name, source.Description, cfg)}
+}
+
+// telegrafPlugin is a telegraf input plugin together with its static settings.
+type telegrafPlugin struct {
+	name     string
+	settings []string
+}
+
+var (
+	telegrafPlugins = []telegrafPlugin{
+		{name: "cpu", settings: []string{"percpu = false", "totalcpu = true", "collect_cpu_time = false", "report_active = true", `namepass = [ "cpu" ]`}},
+		{name: "system", settings: []string{`namepass = [ "system" ]`}},
+		{name: "mem"},
+		{name: "netstat"},
+		{name: "net", settings: []string{`interfaces = ["eth*", "en*", "lo*"]`, "ignore_protocol_stats = true"}},
+		{name: "disk", settings: []string{`namepass = [ "disk" ]`, `ignore_fs = ["tmpfs", "devtmpfs", "devfs", "iso9660", "overlay", "aufs", "squashfs"]`}},
+		{name: "diskio"},
+	}
+
+	// hostMetrics maps Installed Collector host metrics to telegraf plugins and fields.
+	hostMetrics = map[string]struct{ plugin, field string }{
+		"CPU_User":             {"cpu", "usage_user"},
+		"CPU_Sys":              {"cpu", "usage_system"},
+		"CPU_Nice":             {"cpu", "usage_nice"},
+		"CPU_Idle":             {"cpu", "usage_idle"},
+		"CPU_IOWait":           {"cpu", "usage_iowait"},
+		"CPU_Irq":              {"cpu", "usage_irq"},
+		"CPU_SoftIrq":          {"cpu", "usage_softirq"},
+		"CPU_Stolen":           {"cpu", "usage_steal"},
+		"CPU_Total":            {"cpu", "usage_active"},
+		"CPU_LoadAvg_1min":     {"system", "load1"},
+		"CPU_LoadAvg_5min":     {"system", "load5"},
+		"CPU_LoadAvg_15min":    {"system", "load15"},
+		"Mem_Total":            {"mem", "total"},
+		"Mem_Free":             {"mem", "free"},
+		"Mem_ActualFree":       {"mem", "available"},
+		"Mem_ActualUsed":       {"mem", "used"},
+		"Mem_UsedPercent":      {"mem", "used_percent"},
+		"Mem_FreePercent":      {"mem", "available_percent"},
+		"TCP_Established":      {"netstat", "tcp_established"},
+		"TCP_Listen":           {"netstat", "tcp_listen"},
+		"TCP_Closing":          {"netstat", "tcp_closing"},
+		"TCP_CloseWait":        {"netstat", "tcp_close_wait"},
+		"TCP_Close":            {"netstat", "tcp_close"},
+		"TCP_TimeWait":         {"netstat", "tcp_time_wait"},
+		"Net_InPackets":        {"net", "packets_recv"},
+		"Net_OutPackets":       {"net", "packets_sent"},
+		"Net_InBytes":          {"net", "bytes_recv"},
+		"Net_OutBytes":         {"net", "bytes_sent"},
+		"Disk_Reads":           {"diskio", "reads"},
+		"Disk_ReadBytes":       {"diskio", "read_bytes"},
+		"Disk_Writes":          {"diskio", "writes"},
+		"Disk_WriteBytes":      {"diskio", "write_bytes"},
+		"Disk_InodesAvailable": {"disk", "inodes_free"},
+		"Disk_Used":            {"disk", "used"},
+		"Disk_UsedPercent":     {"disk", "used_percent"},
+	}
+)
+
+func (m *migration) migrateHostMetrics(name string, source installedCollectorSource) []string {
+	metrics := source.Metrics
+	if len(metrics) == 0 {
+		for metric := range hostMetrics {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	fields := map[string][]string{}
+	for _, metric := range metrics {
+		hm, ok := hostMetrics[metric]
+		if !ok {
+			m.reportf("source %q: host metric %s is not supported", name, metric)
+			continue
+		}
+		fields[hm.plugin] = append(fields[hm.plugin], strconv.Quote(hm.field))
+	}
+
+	interval := time.Minute
+	if source.Interval > 0 {
+		interval = time.Duration(source.Interval) * time.Millisecond
+	}
+
+	var agentConfig strings.Builder
+	fmt.Fprintf(&agentConfig, "[agent]\n  interval = %q\n  flush_interval = %q\n", interval, interval)
+	for _, plugin := range telegrafPlugins {
+		pluginFields, ok := fields[plugin.name]
+		if !ok {
+			continue
+		}
+		sort.Strings(pluginFields)
+
+		fmt.Fprintf(&agentConfig, "\n[[inputs.%s]]\n", plugin.name)
+		for _, setting := range plugin.settings {
+			fmt.Fprintf(&agentConfig, "  %s\n", setting)
+		}
+		fmt.Fprintf(&agentConfig, "  fieldpass = [ %s ]\n", strings.Join(pluginFields, ", "))
+	}
+
+	cfg := mappingNode()
+	addEntry(cfg, "separate_field", boolNode(false))
+	agentConfigNode := stringNode(agentConfig.String())
+	agentConfigNode.Style = yaml.LiteralStyle
+	addEntry(cfg, "agent_config", agentConfigNode)
+
+	return []string{m.addReceiver("telegraf", name, source.Description, cfg)}
+}
+
+// migrateSourceAttributes creates source processor setting the source
+// category, name and host the same way Installed Collector does: source
+// settings take precedence over the collector ones.
+func (m *migration) migrateSourceAttributes(name string, source installedCollectorSource) string {
+	category := source.Category
+	if category == "" {
+		category = m.props["category"]
+	}
+	host := source.HostName
+	if host == "" {
+		host = m.props["hostName"]
+	}
+
+	cfg := mappingNode()
+	addEntry(cfg, "source_name", stringNode(strings.TrimSpace(source.Name)))
+	addEntry(cfg, "source_category", stringNode(category))
+	addEntry(cfg, "source_category_prefix", stringNode(""))
+	// Keep the dashes as they are, by default they are replaced with slashes.
+	addEntry(cfg, "source_category_replace_dash", stringNode("-"))
+	addEntry(cfg, "source_host", stringNode(host))
+
+	return m.addProcessor("source", name, cfg)
+}
+
+func (m *migration) migrateFields(name string, fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attributes := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, k := range keys {
+		attribute := mappingNode()
+		addEntry(attribute, "key", stringNode(k))
+		addEntry(attribute, "value", stringNode(fmt.Sprint(fields[k])))
+		addEntry(attribute, "action", stringNode("insert"))
+		attributes.Content = append(attributes.Content, attribute)
+	}
+
+	cfg := mappingNode()
+	addEntry(cfg, "attributes", attributes)
+	return m.addProcessor("resource", name, cfg)
+}
+
+// migrateFilters converts include and exclude filters.
+//
+// The filter processor can't match log bodies, so the logstransform processor
+// is used instead, as described in docs/Migration.md. The deviation is
+// reported, so that it's not missed by the user.
+// Installed Collector filters have to match the whole message, hence
+// the expressions are anchored.
+func (m *migration) migrateFilters(name string, filters []installedCollectorFilter) string {
+	var includes, excludes []string
+	for _, f := range filters {
+		expr := fmt.Sprintf("body matches '^(?:%s)$'", escapeExprString(f.Regexp))
+		switch f.FilterType {
+		case filterTypeInclude:
+			includes = append(includes, expr)
+		case filterTypeExclude:
+			excludes = append(excludes, expr)
+		default:
+			m.reportf("source %q: filter %q of type %s is not supported", name, f.Name, f.FilterType)
+		}
+	}
+
+	operators := &yaml.Node{Kind: yaml.SequenceNode}
+	addFilter := func(expr string) {
+		op := mappingNode()
+		addEntry(op, "type", stringNode("filter"))
+		addEntry(op, "expr", stringNode(expr))
+		operators.Content = append(operators.Content, op)
+	}
+	if len(includes) > 0 {
+		// Drop everything which doesn't match any of include filters.
+		addFilter(fmt.Sprintf("not(%s)", strings.Join(includes, " or ")))
+	}
+	for _, expr := range excludes {
+		addFilter(expr)
+	}
+	if len(operators.Content) == 0 {
+		return ""
+	}
+	m.reportf("source %q: filters are converted to logstransform processor filter operators instead of filter processor rules, as filter processor can't match the log body", name)
+
+	cfg := mappingNode()
+	addEntry(cfg, "operators", operators)
+	return m.addProcessor("logstransform", name, cfg)
+}
+
+// escapeExprString escapes the string so that it can be put into single
+// quoted expr language string literal.
+func escapeExprString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+func mappingNode() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode}
+}
+
+func stringNode(v string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+}
+
+func boolNode(v bool) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+}
+
+func sequenceNode(values ...string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, v := range values {
+		n.Content = append(n.Content, stringNode(v))
+	}
+	return n
+}
+
+// addEntry appends the key with the value to the mapping node and returns
+// the key node.
+func addEntry(m *yaml.Node, key string, value *yaml.Node) *yaml.Node {
+	k := stringNode(key)
+	m.Content = append(m.Content, k, value)
+	return k
+}
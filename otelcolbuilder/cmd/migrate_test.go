@@ -0,0 +1,144 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateCommand(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "config.yaml")
+
+	cmd := NewMigrateCommand()
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{
+		"--user-properties", "testdata/migrate/user.properties",
+		"--sources", "testdata/migrate/sources",
+		"--output", output,
+	})
+	require.NoError(t, cmd.Execute())
+
+	expected, err := os.ReadFile("testdata/migrate/expected.yaml")
+	require.NoError(t, err)
+	actual, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+
+	report := stderr.String()
+	for _, line := range []string{
+		`user.properties: url is not supported`,
+		`user.properties: proxyHost is not supported`,
+		`source "app-logs": filter "passwords" of type Mask is not supported`,
+		`source "app-logs": filters are converted to logstransform processor filter operators instead of filter processor rules, as filter processor can't match the log body`,
+		`source "syslog": time zone settings are not supported, use time_zone in sumologic extension`,
+		`source "host-metrics": host metric Unknown_Metric is not supported`,
+		`source "script": source type Script is not supported`,
+	} {
+		assert.Contains(t, report, line)
+	}
+}
+
+func TestMigrateCommandSourcesFromUserProperties(t *testing.T) {
+	dir := t.TempDir()
+	props := filepath.Join(dir, "user.properties")
+	require.NoError(t, os.WriteFile(props, []byte("syncSources=testdata/migrate/sources/metrics.json\n"), 0600))
+
+	cmd := NewMigrateCommand()
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"--user-properties", props})
+	require.NoError(t, cmd.Execute())
+
+	assert.Contains(t, stdout.String(), "telegraf/host-metrics:")
+	assert.Contains(t, stdout.String(), "install_token: ${SUMOLOGIC_INSTALL_TOKEN}")
+	assert.Contains(t, stderr.String(), "installation token not found")
+}
+
+func TestMigrateCommandNoSources(t *testing.T) {
+	cmd := NewMigrateCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{})
+	assert.EqualError(t, cmd.Execute(), "sources JSON has to be provided either with --sources or in user.properties")
+}
+
+func TestReadUserProperties(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.properties")
+	content := "# comment\n! other comment\nname = collector\ncategory: a/b\nfields=a=1,\\\n  b=2\n\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	props, err := readUserProperties(path)
+	require.NoError(t, err)
+	assert.Equal(t, userProperties{
+		"name":     "collector",
+		"category": "a/b",
+		"fields":   "a=1,b=2",
+	}, props)
+}
+
+func TestMigrateFilters(t *testing.T) {
+	testcases := []struct {
+		name     string
+		filters  []installedCollectorFilter
+		expected []string
+	}{
+		{
+			name: "exclude",
+			filters: []installedCollectorFilter{
+				{FilterType: filterTypeExclude, Regexp: `.*debug.*`},
+			},
+			expected: []string{`body matches '^(?:.*debug.*)$'`},
+		},
+		{
+			name: "multiple includes are ORed",
+			filters: []installedCollectorFilter{
+				{FilterType: filterTypeInclude, Regexp: `.*a.*`},
+				{FilterType: filterTypeInclude, Regexp: `.*b.*`},
+			},
+			expected: []string{`not(body matches '^(?:.*a.*)$' or body matches '^(?:.*b.*)$')`},
+		},
+		{
+			name: "escaping",
+			filters: []installedCollectorFilter{
+				{FilterType: filterTypeExclude, Regexp: `it's \d+`},
+			},
+			expected: []string{`body matches '^(?:it\'s \\d+)$'`},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &migration{processors: mappingNode(), names: map[string]bool{}}
+			id := m.migrateFilters("test", tc.filters)
+			require.Equal(t, "logstransform/test", id)
+
+			// processors: [id, cfg], cfg: [operators, [op...]]
+			operators := m.processors.Content[1].Content[1].Content
+			require.Len(t, operators, len(tc.expected))
+			for i, op := range operators {
+				// op: [type, filter, expr, <expr>]
+				assert.Equal(t, tc.expected[i], op.Content[3].Value)
+			}
+		})
+	}
+}
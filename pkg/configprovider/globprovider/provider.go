@@ -15,12 +15,16 @@
 package globprovider
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
@@ -28,57 +32,163 @@ import (
 )
 
 const (
-	schemeName   = "glob"
-	schemePrefix = schemeName + ":"
+	schemeName        = "glob"
+	schemePrefix      = schemeName + ":"
+	watchSchemeName   = "globwatch"
+	watchSchemePrefix = watchSchemeName + ":"
 )
 
-type provider struct{}
+// watchInterval is how often the files matching the pattern are checked for changes.
+var watchInterval = 10 * time.Second
 
+type provider struct {
+	scheme string
+	watch  bool
+
+	// mu guards the notifications, so that none is sent after Shutdown.
+	mu sync.Mutex
+	// pending is set when the watcher was notified and the configuration wasn't retrieved since then.
+	// The resolver's watch channel holds a single notification, so further ones could block.
+	pending  bool
+	shutdown bool
+}
+
+// New creates the provider for the glob scheme, the files matching the pattern are read once.
 func New() confmap.Provider {
-	return &provider{}
+	return &provider{scheme: schemeName}
 }
 
-func (fmp *provider) Retrieve(ctx context.Context, uri string, _ confmap.WatcherFunc) (confmap.Retrieved, error) {
-	var rawConf map[string]interface{}
-	if !strings.HasPrefix(uri, schemePrefix) {
-		return confmap.Retrieved{}, fmt.Errorf("%q uri is not supported by %q provider", uri, schemeName)
+// NewWatching creates the provider for the globwatch scheme, which also watches the files
+// matching the pattern and notifies the collector to reload the configuration when they change.
+//
+// The provider has to be shut down before the resolver using it, as the resolver closes
+// its watch channel before closing the retrieved configurations.
+func NewWatching() confmap.Provider {
+	return &provider{scheme: watchSchemeName, watch: true}
+}
+
+func (fmp *provider) Retrieve(ctx context.Context, uri string, watcher confmap.WatcherFunc) (confmap.Retrieved, error) {
+	prefix := fmp.scheme + ":"
+	if !strings.HasPrefix(uri, prefix) {
+		return confmap.Retrieved{}, fmt.Errorf("%q uri is not supported by %q provider", uri, fmp.scheme)
 	}
 
-	globPattern := uri[len(schemePrefix):]
-	paths, err := filepath.Glob(globPattern)
+	globPattern := uri[len(prefix):]
+	conf, digest, err := load(globPattern)
 	if err != nil {
 		return confmap.Retrieved{}, err
 	}
+
+	if !fmp.watch || watcher == nil {
+		return confmap.NewRetrieved(conf.ToStringMap())
+	}
+
+	fmp.mu.Lock()
+	// The configuration is retrieved again after the notification is received.
+	fmp.pending = false
+	fmp.mu.Unlock()
+
+	var (
+		stop     = make(chan struct{})
+		stopOnce sync.Once
+	)
+	go fmp.watchFiles(globPattern, digest, watchInterval, watcher, stop)
+
+	return confmap.NewRetrieved(conf.ToStringMap(), confmap.WithRetrievedClose(func(context.Context) error {
+		stopOnce.Do(func() { close(stop) })
+		return nil
+	}))
+}
+
+// load reads and merges the files matching the pattern and returns
+// the configuration together with a digest of the files.
+func load(globPattern string) (*confmap.Conf, []byte, error) {
+	var rawConf map[string]interface{}
+
+	paths, err := filepath.Glob(globPattern)
+	if err != nil {
+		return nil, nil, err
+	}
 	if len(paths) == 0 {
-		return confmap.Retrieved{}, fmt.Errorf("no matching files found for pattern %s", globPattern)
+		return nil, nil, fmt.Errorf("no matching files found for pattern %s", globPattern)
 	}
 
 	// sort the paths alphabetically to have consistent ordering
 	sort.Strings(paths)
 
 	conf := confmap.New()
+	hash := sha256.New()
 	for _, path := range paths {
 		content, err := os.ReadFile(path)
 		if err != nil {
-			return confmap.Retrieved{}, err
+			return nil, nil, err
 		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", path, len(content))
+		hash.Write(content)
 
 		if err := yaml.Unmarshal(content, &rawConf); err != nil {
-			return confmap.Retrieved{}, err
+			return nil, nil, err
 		}
 		pathConf := confmap.NewFromStringMap(rawConf)
 		if err := conf.Merge(pathConf); err != nil {
-			return confmap.Retrieved{}, err
+			return nil, nil, err
+		}
+	}
+
+	return conf, hash.Sum(nil), nil
+}
+
+// watchFiles notifies the watcher once the files matching the pattern change,
+// i.e. a file is added, removed or modified.
+func (fmp *provider) watchFiles(globPattern string, digest []byte, interval time.Duration, watcher confmap.WatcherFunc, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
 		}
+
+		// Files which cannot be loaded at the moment, e.g. in the middle
+		// of being written, are checked again on the next tick.
+		_, newDigest, err := load(globPattern)
+		if err != nil || bytes.Equal(digest, newDigest) {
+			continue
+		}
+
+		fmp.notify(watcher, stop)
+		return
+	}
+}
+
+func (fmp *provider) notify(watcher confmap.WatcherFunc, stop <-chan struct{}) {
+	fmp.mu.Lock()
+	defer fmp.mu.Unlock()
+
+	if fmp.shutdown || fmp.pending {
+		return
+	}
+	select {
+	case <-stop:
+		return
+	default:
 	}
 
-	return confmap.NewRetrieved(conf.ToStringMap())
+	fmp.pending = true
+	watcher(&confmap.ChangeEvent{})
 }
 
-func (*provider) Scheme() string {
-	return schemeName
+func (fmp *provider) Scheme() string {
+	return fmp.scheme
 }
 
+// Shutdown stops notifying the watchers. It waits for the notification in progress,
+// so the watchers are not used after it returns.
 func (fmp *provider) Shutdown(context.Context) error {
+	fmp.mu.Lock()
+	defer fmp.mu.Unlock()
+	fmp.shutdown = true
 	return nil
 }
@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -149,3 +150,130 @@ func ValidateProviderScheme(p confmap.Provider) error {
 
 	return nil
 }
+
+func TestValidateWatchingProviderScheme(t *testing.T) {
+	assert.NoError(t, ValidateProviderScheme(NewWatching()))
+}
+
+func setWatchInterval(t *testing.T) {
+	interval := watchInterval
+	watchInterval = 10 * time.Millisecond
+	t.Cleanup(func() { watchInterval = interval })
+}
+
+func TestNotWatchingByDefault(t *testing.T) {
+	setWatchInterval(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "first.yaml"), []byte("processors:\n  batch:\n"), 0600))
+
+	events := make(chan *confmap.ChangeEvent, 10)
+	fp := New()
+	_, err := fp.Retrieve(context.Background(), watchSchemePrefix+filepath.Join(dir, "*.yaml"), nil)
+	assert.Error(t, err)
+	ret, err := fp.Retrieve(context.Background(), schemePrefix+filepath.Join(dir, "*.yaml"), func(event *confmap.ChangeEvent) {
+		events <- event
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "second.yaml"), []byte("exporters:\n  logging:\n"), 0600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, events, 0)
+
+	require.NoError(t, ret.Close(context.Background()))
+	assert.NoError(t, fp.Shutdown(context.Background()))
+}
+
+func TestWatch(t *testing.T) {
+	setWatchInterval(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "first.yaml"), []byte("processors:\n  batch:\n"), 0600))
+
+	events := make(chan *confmap.ChangeEvent, 10)
+	fp := NewWatching()
+	_, err := fp.Retrieve(context.Background(), schemePrefix+filepath.Join(dir, "*.yaml"), nil)
+	assert.Error(t, err)
+	ret, err := fp.Retrieve(context.Background(), watchSchemePrefix+filepath.Join(dir, "*.yaml"), func(event *confmap.ChangeEvent) {
+		events <- event
+	})
+	require.NoError(t, err)
+
+	// Unchanged files don't trigger a notification.
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, events, 0)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "second.yaml"), []byte("exporters:\n  logging:\n"), 0600))
+	select {
+	case event := <-events:
+		assert.NoError(t, event.Error)
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not detected")
+	}
+	require.NoError(t, ret.Close(context.Background()))
+
+	// The watch is finished after closing.
+	ret, err = fp.Retrieve(context.Background(), watchSchemePrefix+filepath.Join(dir, "*.yaml"), func(event *confmap.ChangeEvent) {
+		events <- event
+	})
+	require.NoError(t, err)
+	require.NoError(t, ret.Close(context.Background()))
+	require.NoError(t, ret.Close(context.Background()))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "second.yaml"), []byte("exporters:\n  otlp:\n"), 0600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, events, 0)
+
+	assert.NoError(t, fp.Shutdown(context.Background()))
+}
+
+func TestWatchSingleNotification(t *testing.T) {
+	setWatchInterval(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "first.yaml"), []byte("processors:\n  batch:\n"), 0600))
+
+	events := make(chan *confmap.ChangeEvent, 10)
+	watcher := func(event *confmap.ChangeEvent) { events <- event }
+	fp := NewWatching()
+	first, err := fp.Retrieve(context.Background(), watchSchemePrefix+filepath.Join(dir, "first.yaml"), watcher)
+	require.NoError(t, err)
+	second, err := fp.Retrieve(context.Background(), watchSchemePrefix+filepath.Join(dir, "*.yaml"), watcher)
+	require.NoError(t, err)
+
+	// Both patterns match the changed file, but the watcher is notified only once
+	// until the configuration is retrieved again.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "first.yaml"), []byte("processors:\n  batch/2:\n"), 0600))
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not detected")
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, events, 0)
+
+	require.NoError(t, first.Close(context.Background()))
+	require.NoError(t, second.Close(context.Background()))
+	assert.NoError(t, fp.Shutdown(context.Background()))
+}
+
+func TestWatchShutdown(t *testing.T) {
+	setWatchInterval(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "first.yaml"), []byte("processors:\n  batch:\n"), 0600))
+
+	// The resolver closes its watch channel after the provider is shut down,
+	// but before closing the retrieved configuration.
+	events := make(chan *confmap.ChangeEvent, 1)
+	fp := NewWatching()
+	ret, err := fp.Retrieve(context.Background(), watchSchemePrefix+filepath.Join(dir, "*.yaml"), func(event *confmap.ChangeEvent) {
+		events <- event
+	})
+	require.NoError(t, err)
+	require.NoError(t, fp.Shutdown(context.Background()))
+	close(events)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "second.yaml"), []byte("exporters:\n  logging:\n"), 0600))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, ret.Close(context.Background()))
+}
@@ -22,6 +22,7 @@ import (
 
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/config/configgrpc"
 	"go.opentelemetry.io/collector/config/confighttp"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
 )
@@ -33,6 +34,18 @@ type Config struct {
 	exporterhelper.QueueSettings  `mapstructure:"sending_queue"`
 	exporterhelper.RetrySettings  `mapstructure:"retry_on_failure"`
 
+	// Transport is the protocol used to send the data, either http or grpc (default http).
+	// The grpc transport sends the data using OTLP/gRPC, so it requires otlp formats.
+	Transport TransportType `mapstructure:"transport"`
+	// GRPCClientSettings defines the gRPC client used with the grpc transport.
+	// When the endpoint is not set, it's discovered from the auth extension,
+	// which also provides the credentials.
+	GRPCClientSettings configgrpc.GRPCClientSettings `mapstructure:"grpc"`
+
+	// Tenants configures sending the data on behalf of multiple collectors,
+	// each registered by a separate sumologicextension.
+	Tenants TenantsConfig `mapstructure:"tenants"`
+
 	// Compression encoding format, either empty string, gzip or deflate (default gzip)
 	// Empty string means no compression
 	CompressEncoding CompressEncodingType `mapstructure:"compress_encoding"`
@@ -114,6 +127,16 @@ type JSONLogs struct {
 	FlattenBody bool `mapstructure:"flatten_body"`
 }
 
+// TenantsConfig defines which sumologicextension is used to send each batch.
+type TenantsConfig struct {
+	// Attribute is the name of the resource attribute which value selects the tenant.
+	Attribute string `mapstructure:"attribute"`
+	// Authenticators maps the attribute values to the sumologicextensions
+	// used to send the data. Data without a matching attribute value is sent
+	// using the auth extension or the endpoint of the exporter.
+	Authenticators map[string]config.ComponentID `mapstructure:"authenticators"`
+}
+
 // CreateDefaultHTTPClientSettings returns default http client settings
 func CreateDefaultHTTPClientSettings() confighttp.HTTPClientSettings {
 	return confighttp.HTTPClientSettings{
@@ -173,10 +196,33 @@ Please consult the changelog at https://github.com/SumoLogic/sumologic-otel-coll
 		return err
 	}
 
+	switch cfg.Transport {
+	case "", HTTPTransport:
+	case GRPCTransport:
+		if cfg.LogFormat != OTLPLogFormat || cfg.MetricFormat != OTLPMetricFormat || cfg.TraceFormat != OTLPTraceFormat {
+			return errors.New("grpc transport requires otlp log_format, metric_format and trace_format")
+		}
+		if len(cfg.GRPCClientSettings.Endpoint) == 0 && cfg.HTTPClientSettings.Auth == nil {
+			return errors.New("no grpc endpoint and no auth extension specified")
+		}
+	default:
+		return fmt.Errorf("unexpected transport: %s", cfg.Transport)
+	}
+
 	if len(cfg.HTTPClientSettings.Endpoint) == 0 && cfg.HTTPClientSettings.Auth == nil {
 		return errors.New("no endpoint and no auth extension specified")
 	}
 
+	if len(cfg.Tenants.Authenticators) > 0 && cfg.Tenants.Attribute == "" {
+		return errors.New("tenants attribute is required when tenants authenticators are specified")
+	}
+
+	for value, id := range cfg.Tenants.Authenticators {
+		if string(id.Type()) != "sumologic" {
+			return fmt.Errorf("tenant %q: authenticator %q is not a sumologic extension", value, id)
+		}
+	}
+
 	if _, err := url.Parse(cfg.HTTPClientSettings.Endpoint); err != nil {
 		return fmt.Errorf("failed parsing endpoint URL: %s; err: %w",
 			cfg.HTTPClientSettings.Endpoint, err,
@@ -199,6 +245,9 @@ type MetricFormatType string
 // TraceFormatType represents trace_format
 type TraceFormatType string
 
+// TransportType represents transport
+type TransportType string
+
 // PipelineType represents type of the pipeline
 type PipelineType string
 
@@ -235,6 +284,10 @@ const (
 	OTLPMetricFormat MetricFormatType = "otlp"
 	// OTLPTraceFormat represents trace_format: otlp
 	OTLPTraceFormat TraceFormatType = "otlp"
+	// HTTPTransport represents transport: http
+	HTTPTransport TransportType = "http"
+	// GRPCTransport represents transport: grpc
+	GRPCTransport TransportType = "grpc"
 	// GZIPCompression represents compress_encoding: gzip
 	GZIPCompression CompressEncodingType = "gzip"
 	// DeflateCompression represents compress_encoding: deflate
@@ -259,6 +312,8 @@ const (
 	DefaultLogFormat LogFormatType = OTLPLogFormat
 	// DefaultMetricFormat defines default MetricFormat
 	DefaultMetricFormat MetricFormatType = OTLPMetricFormat
+	// DefaultTransport defines default Transport
+	DefaultTransport TransportType = HTTPTransport
 	// DefaultSourceCategory defines default SourceCategory
 	DefaultSourceCategory string = ""
 	// DefaultSourceName defines default SourceName
@@ -31,6 +31,7 @@ import (
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension"
 )
@@ -54,8 +55,11 @@ type sumologicexporter struct {
 	host    component.Host
 	logger  *zap.Logger
 
+	// clientLock guards the HTTP client and the gRPC connection used with
+	// the grpc transport.
 	clientLock sync.RWMutex
 	client     *http.Client
+	grpcConn   *grpc.ClientConn
 
 	compressorPool sync.Pool
 
@@ -67,6 +71,16 @@ type sumologicexporter struct {
 	dataUrlMetrics string
 	dataUrlLogs    string
 	dataUrlTraces  string
+
+	// unregisterCredentialsListener stops the reconfiguration on collector
+	// credentials change, it's set when sending data using sumologicextension.
+	unregisterCredentialsListener func()
+
+	// tenants are the exporters sending the data on behalf of the collectors
+	// selected by the tenants attribute, keyed by its value; the data without
+	// a matching tenant is sent by defaultTenant.
+	tenants       map[string]*sumologicexporter
+	defaultTenant *sumologicexporter
 }
 
 func initExporter(cfg *Config, createSettings component.ExporterCreateSettings) (*sumologicexporter, error) {
@@ -101,6 +115,10 @@ func initExporter(cfg *Config, createSettings component.ExporterCreateSettings)
 		prometheusFormatter: pf,
 	}
 
+	if err := se.initTenants(createSettings); err != nil {
+		return nil, err
+	}
+
 	se.logger.Info(
 		"Sumo Logic Exporter configured",
 		zap.String("log_format", string(cfg.LogFormat)),
@@ -184,6 +202,10 @@ func newTracesExporter(
 // It returns the number of unsent logs and an error which contains a list of dropped records
 // so they can be handled by OTC retry mechanism
 func (se *sumologicexporter) pushLogsData(ctx context.Context, ld plog.Logs) error {
+	if len(se.tenants) > 0 {
+		return se.pushLogsDataToTenants(ctx, ld)
+	}
+
 	compr, err := se.getCompressor()
 	if err != nil {
 		return consumererror.NewLogs(err, ld)
@@ -195,6 +217,7 @@ func (se *sumologicexporter) pushLogsData(ctx context.Context, ld plog.Logs) err
 		se.logger,
 		se.config,
 		se.getHTTPClient(),
+		se.getGRPCConn(),
 		se.sources,
 		compr,
 		se.prometheusFormatter,
@@ -267,6 +290,10 @@ func (se *sumologicexporter) pushLogsData(ctx context.Context, ld plog.Logs) err
 // it returns number of unsent metrics and error which contains list of dropped records
 // so they can be handle by the OTC retry mechanism
 func (se *sumologicexporter) pushMetricsData(ctx context.Context, md pmetric.Metrics) error {
+	if len(se.tenants) > 0 {
+		return se.pushMetricsDataToTenants(ctx, md)
+	}
+
 	compr, err := se.getCompressor()
 	if err != nil {
 		return consumererror.NewMetrics(err, md)
@@ -278,6 +305,7 @@ func (se *sumologicexporter) pushMetricsData(ctx context.Context, md pmetric.Met
 		se.logger,
 		se.config,
 		se.getHTTPClient(),
+		se.getGRPCConn(),
 		se.sources,
 		compr,
 		se.prometheusFormatter,
@@ -333,6 +361,10 @@ func (se *sumologicexporter) handleUnauthorizedErrors(ctx context.Context, errs
 }
 
 func (se *sumologicexporter) pushTracesData(ctx context.Context, td ptrace.Traces) error {
+	if len(se.tenants) > 0 {
+		return se.pushTracesDataToTenants(ctx, td)
+	}
+
 	compr, err := se.getCompressor()
 	if err != nil {
 		return consumererror.NewTraces(err, td)
@@ -344,6 +376,7 @@ func (se *sumologicexporter) pushTracesData(ctx context.Context, td ptrace.Trace
 		se.logger,
 		se.config,
 		se.getHTTPClient(),
+		se.getGRPCConn(),
 		se.sources,
 		compr,
 		se.prometheusFormatter,
@@ -376,25 +409,55 @@ func (se *sumologicexporter) getCompressor() (*compressor, error) {
 
 func (se *sumologicexporter) start(ctx context.Context, host component.Host) error {
 	se.host = host
-	return se.configure(ctx)
-}
+	if len(se.tenants) > 0 {
+		return se.startTenants(ctx, host)
+	}
 
-func (se *sumologicexporter) configure(ctx context.Context) error {
-	var (
-		ext          *sumologicextension.SumologicExtension
-		foundSumoExt bool
-	)
+	if se.config.Transport == GRPCTransport {
+		return se.configureGRPC(ctx)
+	}
 
-	httpSettings := se.config.HTTPClientSettings
+	if err := se.configure(ctx); err != nil {
+		return err
+	}
+
+	// Reconfigure the exporter whenever sumologicextension replaces
+	// the collector credentials, e.g. when they are rotated.
+	if ext, ok := se.getSumologicExtension(); ok && se.config.HTTPClientSettings.Endpoint == "" {
+		se.unregisterCredentialsListener = ext.OnCredentialsChange(func() {
+			se.logger.Info("Collector credentials changed, triggering reconfiguration")
+			if err := se.configure(context.Background()); err != nil {
+				se.logger.Error("Error configuring the exporter with new credentials", zap.Error(err))
+			}
+		})
+	}
+	return nil
+}
+
+// getSumologicExtension returns the sumologicextension used as the authenticator.
+func (se *sumologicexporter) getSumologicExtension() (*sumologicextension.SumologicExtension, bool) {
+	if se.config.HTTPClientSettings.Auth == nil {
+		return nil, false
+	}
 
 	for _, e := range se.host.GetExtensions() {
 		v, ok := e.(*sumologicextension.SumologicExtension)
-		if ok && httpSettings.Auth.AuthenticatorID == v.ComponentID() {
-			ext = v
-			foundSumoExt = true
-			break
+		if ok && se.config.HTTPClientSettings.Auth.AuthenticatorID == v.ComponentID() {
+			return v, true
 		}
 	}
+	return nil, false
+}
+
+func (se *sumologicexporter) configure(ctx context.Context) error {
+	if se.config.Transport == GRPCTransport {
+		// The gRPC connection doesn't depend on the credentials, see configureGRPC.
+		return nil
+	}
+
+	httpSettings := se.config.HTTPClientSettings
+
+	ext, foundSumoExt := se.getSumologicExtension()
 
 	if httpSettings.Endpoint == "" && httpSettings.Auth != nil &&
 		string(httpSettings.Auth.AuthenticatorID.Type()) == "sumologic" {
@@ -471,7 +534,16 @@ func (se *sumologicexporter) getDataURLs() (logs, metrics, traces string) {
 	return se.dataUrlLogs, se.dataUrlMetrics, se.dataUrlTraces
 }
 
-func (se *sumologicexporter) shutdown(context.Context) error {
+func (se *sumologicexporter) shutdown(ctx context.Context) error {
+	if len(se.tenants) > 0 {
+		return se.shutdownTenants(ctx)
+	}
+	if se.unregisterCredentialsListener != nil {
+		se.unregisterCredentialsListener()
+	}
+	if conn := se.getGRPCConn(); conn != nil {
+		return conn.Close()
+	}
 	return nil
 }
 
@@ -62,6 +62,7 @@ func createDefaultConfig() config.Exporter {
 			FlattenBody:  DefaultFlattenBody,
 		},
 		TraceFormat: OTLPTraceFormat,
+		Transport:   DefaultTransport,
 
 		HTTPClientSettings:   CreateDefaultHTTPClientSettings(),
 		RetrySettings:        exporterhelper.NewDefaultRetrySettings(),
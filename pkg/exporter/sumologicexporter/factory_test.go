@@ -54,6 +54,7 @@ func TestCreateDefaultConfig(t *testing.T) {
 			TimestampKey: "timestamp",
 		},
 		TraceFormat: "otlp",
+		Transport:   "http",
 
 		HTTPClientSettings: confighttp.HTTPClientSettings{
 			Timeout: 5 * time.Second,
@@ -0,0 +1,174 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicexporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/exporter/sumologicexporter/internal/observability"
+)
+
+// configureGRPC creates the gRPC connection used with the grpc transport.
+// If the endpoint is not set, it's discovered from the API base URL of
+// sumologicextension, which then authenticates the requests.
+//
+// The connection is created once on start and closed on shutdown. The per-RPC
+// credentials of sumologicextension read the current collector credentials for
+// every request, so it doesn't have to be recreated when they change.
+func (se *sumologicexporter) configureGRPC(ctx context.Context) error {
+	grpcSettings := se.config.GRPCClientSettings
+
+	if grpcSettings.Endpoint == "" {
+		httpSettings := se.config.HTTPClientSettings
+		ext, foundSumoExt := se.getSumologicExtension()
+		if !foundSumoExt {
+			if httpSettings.Auth != nil && string(httpSettings.Auth.AuthenticatorID.Type()) == "sumologic" {
+				return fmt.Errorf(
+					"sumologic was specified as auth extension (named: %q) but "+
+						"a matching extension was not found in the config, "+
+						"please re-check the config and/or define the sumologicextension",
+					httpSettings.Auth.AuthenticatorID.String(),
+				)
+			}
+			return fmt.Errorf("no auth extension and no grpc endpoint specified")
+		}
+
+		u, err := url.Parse(ext.BaseUrl())
+		if err != nil {
+			return fmt.Errorf("failed to parse API base URL from sumologicextension: %w", err)
+		}
+		port := u.Port()
+		if port == "" {
+			port = "443"
+			if u.Scheme == "http" {
+				port = "80"
+			}
+		}
+		grpcSettings.Endpoint = net.JoinHostPort(u.Hostname(), port)
+		grpcSettings.TLSSetting.Insecure = u.Scheme == "http"
+		grpcSettings.Auth = httpSettings.Auth
+	}
+
+	opts, err := grpcSettings.ToDialOptions(se.host, component.TelemetrySettings{
+		TracerProvider: trace.NewNoopTracerProvider(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC dial options: %w", err)
+	}
+
+	conn, err := grpc.DialContext(ctx, grpcSettings.SanitizedEndpoint(), opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC connection: %w", err)
+	}
+
+	se.clientLock.Lock()
+	se.grpcConn = conn
+	se.clientLock.Unlock()
+	return nil
+}
+
+func (se *sumologicexporter) getGRPCConn() *grpc.ClientConn {
+	se.clientLock.RLock()
+	defer se.clientLock.RUnlock()
+	return se.grpcConn
+}
+
+// exportGRPC sends the data using the export function and records the request
+// metrics. Unauthenticated requests result in errUnauthorized, the same as
+// with the http transport, the retried request uses the current credentials.
+func (s *sender) exportGRPC(ctx context.Context, pipeline PipelineType, count int64, export func(context.Context) error) error {
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := export(ctx)
+	s.recordGRPCMetrics(time.Since(start), count, pipeline, err)
+
+	if err == nil {
+		return nil
+	}
+	if st := status.Convert(err); st.Code() == codes.Unauthenticated {
+		return fmt.Errorf("%w: %s", errUnauthorized, st.Message())
+	}
+	return err
+}
+
+// recordGRPCMetrics records the request metrics, using the HTTP equivalent
+// of the gRPC status code, so that both transports report the same codes.
+func (s *sender) recordGRPCMetrics(duration time.Duration, count int64, pipeline PipelineType, err error) {
+	var (
+		statusCode = httpStatusFromGRPCCode(status.Code(err))
+		endpoint   = s.grpcConn.Target()
+		id         = s.config.ID().String()
+	)
+
+	if err := observability.RecordRequestsDuration(duration, statusCode, endpoint, string(pipeline), id); err != nil {
+		s.logger.Debug("error for recording metric for request duration", zap.Error(err))
+	}
+
+	if err := observability.RecordRequestsRecords(count, statusCode, endpoint, string(pipeline), id); err != nil {
+		s.logger.Debug("error for recording metric for sent records", zap.Error(err))
+	}
+
+	if err := observability.RecordRequestsSent(statusCode, endpoint, string(pipeline), id); err != nil {
+		s.logger.Debug("error for recording metric for sent request", zap.Error(err))
+	}
+}
+
+// httpStatusFromGRPCCode maps the gRPC status code to the corresponding HTTP
+// status code, as specified by the gRPC to HTTP status code mapping.
+func httpStatusFromGRPCCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicexporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension"
+)
+
+// grpcStandIn is a stand-in for the API serving both the collector
+// registration over HTTP and OTLP/gRPC on the same address.
+type grpcStandIn struct {
+	*httptest.Server
+
+	unauthenticated int32
+
+	mu             sync.Mutex
+	authorizations []string
+	logs           int
+	metrics        int
+	spans          int
+}
+
+func newGRPCStandIn(t *testing.T) *grpcStandIn {
+	s := &grpcStandIn{}
+
+	grpcServer := grpc.NewServer()
+	plogotlp.RegisterServer(grpcServer, grpcLogsServer{s})
+	pmetricotlp.RegisterServer(grpcServer, grpcMetricsServer{s})
+	ptraceotlp.RegisterServer(grpcServer, grpcTracesServer{s})
+
+	s.Server = httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.ProtoMajor == 2 && strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, req)
+			return
+		}
+
+		switch req.URL.Path {
+		case "/api/v1/collector/register":
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+		case "/api/v1/collector/heartbeat":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}), &http2.Server{}))
+	t.Cleanup(func() {
+		s.Close()
+		grpcServer.Stop()
+	})
+
+	return s
+}
+
+// export records the request and returns an error if the requests
+// are set to be unauthenticated.
+func (s *grpcStandIn) export(ctx context.Context, record func()) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizations = append(s.authorizations, strings.Join(md.Get("authorization"), ","))
+
+	if atomic.CompareAndSwapInt32(&s.unauthenticated, 1, 0) {
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	record()
+	return nil
+}
+
+type grpcLogsServer struct{ *grpcStandIn }
+
+func (s grpcLogsServer) Export(ctx context.Context, req plogotlp.Request) (plogotlp.Response, error) {
+	return plogotlp.NewResponse(), s.export(ctx, func() { s.logs += req.Logs().LogRecordCount() })
+}
+
+type grpcMetricsServer struct{ *grpcStandIn }
+
+func (s grpcMetricsServer) Export(ctx context.Context, req pmetricotlp.Request) (pmetricotlp.Response, error) {
+	return pmetricotlp.NewResponse(), s.export(ctx, func() { s.metrics += req.Metrics().DataPointCount() })
+}
+
+type grpcTracesServer struct{ *grpcStandIn }
+
+func (s grpcTracesServer) Export(ctx context.Context, req ptraceotlp.Request) (ptraceotlp.Response, error) {
+	return ptraceotlp.NewResponse(), s.export(ctx, func() { s.spans += req.Traces().SpanCount() })
+}
+
+type hostWithExtensions struct {
+	component.Host
+	extensions map[config.ComponentID]component.Extension
+}
+
+func (h hostWithExtensions) GetExtensions() map[config.ComponentID]component.Extension {
+	return h.extensions
+}
+
+func TestGRPCTransport(t *testing.T) {
+	standIn := newGRPCStandIn(t)
+
+	extFactory := sumologicextension.NewFactory()
+	extCfg := extFactory.CreateDefaultConfig().(*sumologicextension.Config)
+	extCfg.ApiBaseUrl = standIn.URL
+	extCfg.CollectorName = "collector_name"
+	extCfg.Credentials.InstallToken = "dummy_install_token"
+	extCfg.CollectorCredentialsDirectory = t.TempDir()
+	ext, err := extFactory.CreateExtension(context.Background(),
+		component.ExtensionCreateSettings{TelemetrySettings: componenttest.NewNopTelemetrySettings()},
+		extCfg,
+	)
+	require.NoError(t, err)
+	require.NoError(t, ext.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, ext.Shutdown(context.Background())) })
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Transport = GRPCTransport
+	require.NoError(t, cfg.Validate())
+
+	exp, err := initExporter(cfg, createExporterCreateSettings())
+	require.NoError(t, err)
+	require.NoError(t, exp.start(context.Background(), hostWithExtensions{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[config.ComponentID]component.Extension{extCfg.ID(): ext},
+	}))
+	t.Cleanup(func() { require.NoError(t, exp.shutdown(context.Background())) })
+
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStringVal("log")
+	require.NoError(t, exp.pushLogsData(context.Background(), logs))
+
+	metrics := pmetric.NewMetrics()
+	metric := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetDataType(pmetric.MetricDataTypeGauge)
+	metric.Gauge().DataPoints().AppendEmpty().SetIntVal(1)
+	require.NoError(t, exp.pushMetricsData(context.Background(), metrics))
+
+	traces := ptrace.NewTraces()
+	traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	require.NoError(t, exp.pushTracesData(context.Background(), traces))
+
+	standIn.mu.Lock()
+	assert.Equal(t, 1, standIn.logs)
+	assert.Equal(t, 1, standIn.metrics)
+	assert.Equal(t, 1, standIn.spans)
+	// base64 of collectorId:collectorKey
+	assert.Equal(t, []string{
+		"Basic Y29sbGVjdG9ySWQ6Y29sbGVjdG9yS2V5",
+		"Basic Y29sbGVjdG9ySWQ6Y29sbGVjdG9yS2V5",
+		"Basic Y29sbGVjdG9ySWQ6Y29sbGVjdG9yS2V5",
+	}, standIn.authorizations)
+	standIn.mu.Unlock()
+
+	// Unauthenticated requests are reported as unauthorized,
+	// but the connection is kept.
+	conn := exp.getGRPCConn()
+	atomic.StoreInt32(&standIn.unauthenticated, 1)
+	err = exp.pushLogsData(context.Background(), logs)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errUnauthorized)
+	assert.Same(t, conn, exp.getGRPCConn())
+
+	require.NoError(t, exp.pushLogsData(context.Background(), logs))
+	standIn.mu.Lock()
+	assert.Equal(t, 2, standIn.logs)
+	standIn.mu.Unlock()
+}
+
+func TestGRPCTransportValidation(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Transport = GRPCTransport
+	assert.NoError(t, cfg.Validate())
+
+	cfg.LogFormat = JSONFormat
+	assert.EqualError(t, cfg.Validate(), "grpc transport requires otlp log_format, metric_format and trace_format")
+
+	cfg.LogFormat = OTLPLogFormat
+	cfg.HTTPClientSettings.Auth = nil
+	cfg.HTTPClientSettings.Endpoint = "https://collectors.sumologic.com/receiver/v1/http/token"
+	assert.EqualError(t, cfg.Validate(), "no grpc endpoint and no auth extension specified")
+
+	cfg.GRPCClientSettings.Endpoint = "localhost:4317"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestHTTPStatusFromGRPCCode(t *testing.T) {
+	assert.Equal(t, http.StatusOK, httpStatusFromGRPCCode(codes.OK))
+	assert.Equal(t, http.StatusUnauthorized, httpStatusFromGRPCCode(codes.Unauthenticated))
+	assert.Equal(t, http.StatusTooManyRequests, httpStatusFromGRPCCode(codes.ResourceExhausted))
+	assert.Equal(t, http.StatusServiceUnavailable, httpStatusFromGRPCCode(codes.Unavailable))
+	assert.Equal(t, http.StatusGatewayTimeout, httpStatusFromGRPCCode(codes.DeadlineExceeded))
+	assert.Equal(t, http.StatusInternalServerError, httpStatusFromGRPCCode(codes.Unknown))
+}
@@ -30,10 +30,14 @@ import (
 	"go.opentelemetry.io/collector/model/otlp"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
 	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/exporter/sumologicexporter/internal/observability"
 )
@@ -127,6 +131,7 @@ type sender struct {
 	logger              *zap.Logger
 	config              *Config
 	client              *http.Client
+	grpcConn            *grpc.ClientConn
 	sources             sourceFormats
 	compressor          *compressor
 	prometheusFormatter prometheusFormatter
@@ -164,6 +169,7 @@ func newSender(
 	logger *zap.Logger,
 	cfg *Config,
 	cl *http.Client,
+	gc *grpc.ClientConn,
 	s sourceFormats,
 	c *compressor,
 	pf prometheusFormatter,
@@ -175,6 +181,7 @@ func newSender(
 		logger:              logger,
 		config:              cfg,
 		client:              cl,
+		grpcConn:            gc,
 		sources:             s,
 		compressor:          c,
 		prometheusFormatter: pf,
@@ -485,6 +492,13 @@ func (s *sender) sendOTLPLogs(ctx context.Context, ld plog.Logs) error {
 		s.addSourceResourceAttributes(rl.Resource().Attributes())
 	}
 
+	if s.grpcConn != nil {
+		return s.exportGRPC(ctx, LogsPipeline, int64(ld.LogRecordCount()), func(ctx context.Context) error {
+			_, err := plogotlp.NewClient(s.grpcConn).Export(ctx, plogotlp.NewRequestFromLogs(ld))
+			return err
+		})
+	}
+
 	body, err := logsMarshaler.MarshalLogs(ld)
 	if err != nil {
 		return err
@@ -600,6 +614,13 @@ func (s *sender) sendOTLPMetrics(ctx context.Context, md pmetric.Metrics) error
 		s.addSourceResourceAttributes(rm.Resource().Attributes())
 	}
 
+	if s.grpcConn != nil {
+		return s.exportGRPC(ctx, MetricsPipeline, int64(md.DataPointCount()), func(ctx context.Context) error {
+			_, err := pmetricotlp.NewClient(s.grpcConn).Export(ctx, pmetricotlp.NewRequestFromMetrics(md))
+			return err
+		})
+	}
+
 	body, err := metricsMarshaler.MarshalMetrics(md)
 	if err != nil {
 		return err
@@ -660,6 +681,13 @@ func (s *sender) sendOTLPTraces(ctx context.Context, td ptrace.Traces) error {
 		s.addSourceResourceAttributes(td.ResourceSpans().At(i).Resource().Attributes())
 	}
 
+	if s.grpcConn != nil {
+		return s.exportGRPC(ctx, TracesPipeline, int64(capacity), func(ctx context.Context) error {
+			_, err := ptraceotlp.NewClient(s.grpcConn).Export(ctx, ptraceotlp.NewRequestFromTraces(td))
+			return err
+		})
+	}
+
 	body, err := tracesMarshaler.MarshalTraces(td)
 	if err != nil {
 		return err
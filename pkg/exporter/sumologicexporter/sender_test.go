@@ -94,6 +94,7 @@ func prepareSenderTest(t *testing.T, cb []func(w http.ResponseWriter, req *http.
 			&http.Client{
 				Timeout: cfg.HTTPClientSettings.Timeout,
 			},
+			nil,
 			sourceFormats{
 				host:     getTestSourceFormat(t, "source_host"),
 				category: getTestSourceFormat(t, "source_category"),
@@ -149,6 +150,7 @@ func prepareOTLPSenderTest(t *testing.T, cb []func(w http.ResponseWriter, req *h
 			&http.Client{
 				Timeout: cfg.HTTPClientSettings.Timeout,
 			},
+			nil,
 			sourceFormats{
 				host:     getTestSourceFormat(t, "source_host"),
 				category: getTestSourceFormat(t, "source_category"),
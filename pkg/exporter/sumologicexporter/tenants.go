@@ -0,0 +1,218 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicexporter
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// initTenants creates the exporters sending the data on behalf of the
+// collectors registered by the sumologicextensions configured as tenants.
+// Each of them uses the exporter configuration with its own authenticator,
+// the data without a matching tenant is sent by the default one.
+func (se *sumologicexporter) initTenants(createSettings component.ExporterCreateSettings) error {
+	if len(se.config.Tenants.Authenticators) == 0 {
+		return nil
+	}
+
+	defaultCfg := *se.config
+	defaultCfg.Tenants = TenantsConfig{}
+	defaultTenant, err := initExporter(&defaultCfg, createSettings)
+	if err != nil {
+		return err
+	}
+
+	tenants := make(map[string]*sumologicexporter, len(se.config.Tenants.Authenticators))
+	for value, id := range se.config.Tenants.Authenticators {
+		tenantCfg := defaultCfg
+		tenantCfg.HTTPClientSettings.Endpoint = ""
+		tenantCfg.HTTPClientSettings.Auth = &configauth.Authentication{AuthenticatorID: id}
+		tenantCfg.GRPCClientSettings.Endpoint = ""
+
+		settings := createSettings
+		settings.Logger = createSettings.Logger.With(zap.String("tenant", value))
+		tenant, err := initExporter(&tenantCfg, settings)
+		if err != nil {
+			return err
+		}
+		tenants[value] = tenant
+	}
+
+	se.defaultTenant = defaultTenant
+	se.tenants = tenants
+	return nil
+}
+
+// getTenant returns the exporter used to send the data of the resource.
+func (se *sumologicexporter) getTenant(resource pcommon.Resource) *sumologicexporter {
+	if v, ok := resource.Attributes().Get(se.config.Tenants.Attribute); ok {
+		if tenant, ok := se.tenants[v.AsString()]; ok {
+			return tenant
+		}
+	}
+	return se.defaultTenant
+}
+
+// allTenants returns the default exporter and the tenant exporters.
+func (se *sumologicexporter) allTenants() []*sumologicexporter {
+	all := make([]*sumologicexporter, 0, len(se.tenants)+1)
+	all = append(all, se.defaultTenant)
+	for _, tenant := range se.tenants {
+		all = append(all, tenant)
+	}
+	return all
+}
+
+func (se *sumologicexporter) startTenants(ctx context.Context, host component.Host) error {
+	for _, tenant := range se.allTenants() {
+		if err := tenant.start(ctx, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (se *sumologicexporter) shutdownTenants(ctx context.Context) error {
+	var errs error
+	for _, tenant := range se.allTenants() {
+		errs = multierr.Append(errs, tenant.shutdown(ctx))
+	}
+	return errs
+}
+
+// pushLogsDataToTenants splits the logs by tenant and sends them using the
+// tenant exporters. The logs which failed to be sent are returned in the error.
+// ld is copied, as it can be shared with other consumers.
+func (se *sumologicexporter) pushLogsDataToTenants(ctx context.Context, ld plog.Logs) error {
+	batches := map[*sumologicexporter]plog.Logs{}
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		rl := rls.At(i)
+		tenant := se.getTenant(rl.Resource())
+		batch, ok := batches[tenant]
+		if !ok {
+			batch = plog.NewLogs()
+			batches[tenant] = batch
+		}
+		rl.CopyTo(batch.ResourceLogs().AppendEmpty())
+	}
+
+	var errs []error
+	failed := plog.NewLogs()
+	for tenant, batch := range batches {
+		err := tenant.pushLogsData(ctx, batch)
+		if err == nil {
+			continue
+		}
+		var logsErr consumererror.Logs
+		if errors.As(err, &logsErr) {
+			batch = logsErr.GetLogs()
+		}
+		batch.ResourceLogs().MoveAndAppendTo(failed.ResourceLogs())
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return consumererror.NewLogs(multierr.Combine(errs...), failed)
+	}
+	return nil
+}
+
+// pushMetricsDataToTenants splits the metrics by tenant and sends them using the
+// tenant exporters. The metrics which failed to be sent are returned in the error.
+// md is copied, as it can be shared with other consumers.
+func (se *sumologicexporter) pushMetricsDataToTenants(ctx context.Context, md pmetric.Metrics) error {
+	batches := map[*sumologicexporter]pmetric.Metrics{}
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		tenant := se.getTenant(rm.Resource())
+		batch, ok := batches[tenant]
+		if !ok {
+			batch = pmetric.NewMetrics()
+			batches[tenant] = batch
+		}
+		rm.CopyTo(batch.ResourceMetrics().AppendEmpty())
+	}
+
+	var errs []error
+	failed := pmetric.NewMetrics()
+	for tenant, batch := range batches {
+		err := tenant.pushMetricsData(ctx, batch)
+		if err == nil {
+			continue
+		}
+		var metricsErr consumererror.Metrics
+		if errors.As(err, &metricsErr) {
+			batch = metricsErr.GetMetrics()
+		}
+		batch.ResourceMetrics().MoveAndAppendTo(failed.ResourceMetrics())
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return consumererror.NewMetrics(multierr.Combine(errs...), failed)
+	}
+	return nil
+}
+
+// pushTracesDataToTenants splits the traces by tenant and sends them using the
+// tenant exporters. The traces which failed to be sent are returned in the error.
+// td is copied, as it can be shared with other consumers.
+func (se *sumologicexporter) pushTracesDataToTenants(ctx context.Context, td ptrace.Traces) error {
+	batches := map[*sumologicexporter]ptrace.Traces{}
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		tenant := se.getTenant(rs.Resource())
+		batch, ok := batches[tenant]
+		if !ok {
+			batch = ptrace.NewTraces()
+			batches[tenant] = batch
+		}
+		rs.CopyTo(batch.ResourceSpans().AppendEmpty())
+	}
+
+	var errs []error
+	failed := ptrace.NewTraces()
+	for tenant, batch := range batches {
+		err := tenant.pushTracesData(ctx, batch)
+		if err == nil {
+			continue
+		}
+		var tracesErr consumererror.Traces
+		if errors.As(err, &tracesErr) {
+			batch = tracesErr.GetTraces()
+		}
+		batch.ResourceSpans().MoveAndAppendTo(failed.ResourceSpans())
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return consumererror.NewTraces(multierr.Combine(errs...), failed)
+	}
+	return nil
+}
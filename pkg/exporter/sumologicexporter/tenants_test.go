@@ -0,0 +1,191 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicexporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension"
+)
+
+// tenantStandIn is a stand-in for the API of a single tenant which counts
+// the received logs requests.
+type tenantStandIn struct {
+	*httptest.Server
+	logs int32
+	fail int32
+}
+
+func newTenantStandIn(t *testing.T) *tenantStandIn {
+	s := &tenantStandIn{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v1/collector/register":
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+		case "/api/v1/collector/heartbeat":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			if atomic.LoadInt32(&s.fail) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			atomic.AddInt32(&s.logs, 1)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTenantExtension(t *testing.T, name string, standIn *tenantStandIn) (config.ComponentID, component.Extension) {
+	extFactory := sumologicextension.NewFactory()
+	extCfg := extFactory.CreateDefaultConfig().(*sumologicextension.Config)
+	extCfg.SetIDName(name)
+	extCfg.ApiBaseUrl = standIn.URL
+	extCfg.CollectorName = "collector_" + name
+	extCfg.Credentials.InstallToken = "install_token_" + name
+	extCfg.CollectorCredentialsDirectory = t.TempDir()
+	ext, err := extFactory.CreateExtension(context.Background(),
+		component.ExtensionCreateSettings{TelemetrySettings: componenttest.NewNopTelemetrySettings()},
+		extCfg,
+	)
+	require.NoError(t, err)
+	require.NoError(t, ext.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, ext.Shutdown(context.Background())) })
+	return extCfg.ID(), ext
+}
+
+func tenantLogs(tenants ...string) plog.Logs {
+	logs := plog.NewLogs()
+	for _, tenant := range tenants {
+		rl := logs.ResourceLogs().AppendEmpty()
+		if tenant != "" {
+			rl.Resource().Attributes().UpsertString("sumo.tenant", tenant)
+		}
+		rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStringVal("log")
+	}
+	return logs
+}
+
+func TestTenants(t *testing.T) {
+	defaultStandIn := newTenantStandIn(t)
+	standInA := newTenantStandIn(t)
+	standInB := newTenantStandIn(t)
+	idA, extA := newTenantExtension(t, "a", standInA)
+	idB, extB := newTenantExtension(t, "b", standInB)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.HTTPClientSettings.Endpoint = defaultStandIn.URL
+	cfg.Tenants = TenantsConfig{
+		Attribute: "sumo.tenant",
+		Authenticators: map[string]config.ComponentID{
+			"org-a": idA,
+			"org-b": idB,
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	exp, err := initExporter(cfg, createExporterCreateSettings())
+	require.NoError(t, err)
+	require.NoError(t, exp.start(context.Background(), hostWithExtensions{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[config.ComponentID]component.Extension{idA: extA, idB: extB},
+	}))
+	t.Cleanup(func() { require.NoError(t, exp.shutdown(context.Background())) })
+
+	require.NoError(t, exp.pushLogsData(context.Background(), tenantLogs("org-a", "org-b", "org-a", "org-c", "")))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&standInA.logs))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&standInB.logs))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&defaultStandIn.logs))
+
+	// Only the data of the failing tenant is returned for retry.
+	atomic.StoreInt32(&standInB.fail, 1)
+	err = exp.pushLogsData(context.Background(), tenantLogs("org-a", "org-b"))
+	require.Error(t, err)
+	var logsErr consumererror.Logs
+	require.ErrorAs(t, err, &logsErr)
+	failed := logsErr.GetLogs()
+	require.Equal(t, 1, failed.ResourceLogs().Len())
+	tenant, ok := failed.ResourceLogs().At(0).Resource().Attributes().Get("sumo.tenant")
+	require.True(t, ok)
+	assert.Equal(t, "org-b", tenant.StringVal())
+	assert.EqualValues(t, 2, atomic.LoadInt32(&standInA.logs))
+}
+
+// TestTenantsSharedData verifies that the logs are left intact for the other
+// consumers of the pipeline, as the exporter doesn't declare mutating them.
+func TestTenantsSharedData(t *testing.T) {
+	defaultStandIn := newTenantStandIn(t)
+	standInA := newTenantStandIn(t)
+	idA, extA := newTenantExtension(t, "a", standInA)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.HTTPClientSettings.Endpoint = defaultStandIn.URL
+	cfg.Tenants = TenantsConfig{
+		Attribute:      "sumo.tenant",
+		Authenticators: map[string]config.ComponentID{"org-a": idA},
+	}
+	require.NoError(t, cfg.Validate())
+
+	exp, err := initExporter(cfg, createExporterCreateSettings())
+	require.NoError(t, err)
+	require.NoError(t, exp.start(context.Background(), hostWithExtensions{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[config.ComponentID]component.Extension{idA: extA},
+	}))
+	t.Cleanup(func() { require.NoError(t, exp.shutdown(context.Background())) })
+
+	// The same logs are consumed by the exporter and the sink.
+	logs := tenantLogs("org-a", "")
+	sink := new(consumertest.LogsSink)
+	require.NoError(t, exp.pushLogsData(context.Background(), logs))
+	require.NoError(t, sink.ConsumeLogs(context.Background(), logs))
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&standInA.logs))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&defaultStandIn.logs))
+	require.Len(t, sink.AllLogs(), 1)
+	assert.Equal(t, tenantLogs("org-a", ""), sink.AllLogs()[0])
+}
+
+func TestTenantsValidation(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Tenants.Authenticators = map[string]config.ComponentID{
+		"org-a": config.NewComponentIDWithName("sumologic", "a"),
+	}
+	assert.EqualError(t, cfg.Validate(), "tenants attribute is required when tenants authenticators are specified")
+
+	cfg.Tenants.Attribute = "sumo.tenant"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Tenants.Authenticators["org-b"] = config.NewComponentID("basicauth")
+	assert.EqualError(t, cfg.Validate(), `tenant "org-b": authenticator "basicauth" is not a sumologic extension`)
+}
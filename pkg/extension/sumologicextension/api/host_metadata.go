@@ -0,0 +1,47 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+// HostMetadata contains information about the host and the collector
+// which is sent upon registration when host metadata discovery is enabled.
+type HostMetadata struct {
+	OS               string              `json:"os,omitempty"`
+	Kernel           string              `json:"kernel,omitempty"`
+	Architecture     string              `json:"architecture,omitempty"`
+	CollectorVersion string              `json:"collectorVersion,omitempty"`
+	IPAddresses      []string            `json:"ipAddresses,omitempty"`
+	Cloud            *CloudMetadata      `json:"cloud,omitempty"`
+	Kubernetes       *KubernetesMetadata `json:"kubernetes,omitempty"`
+	Signals          []string            `json:"signals,omitempty"`
+	Components       []string            `json:"components,omitempty"`
+}
+
+// CloudMetadata identifies the cloud instance the collector runs on.
+type CloudMetadata struct {
+	Provider         string `json:"provider"`
+	Region           string `json:"region,omitempty"`
+	AvailabilityZone string `json:"availabilityZone,omitempty"`
+	AccountId        string `json:"accountId,omitempty"`
+	InstanceId       string `json:"instanceId,omitempty"`
+	InstanceType     string `json:"instanceType,omitempty"`
+}
+
+// KubernetesMetadata identifies the pod the collector runs in.
+type KubernetesMetadata struct {
+	ClusterName string `json:"clusterName,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	PodName     string `json:"podName,omitempty"`
+	NodeName    string `json:"nodeName,omitempty"`
+}
@@ -23,6 +23,7 @@ type OpenRegisterRequestPayload struct {
 	TimeZone      string                 `json:"timeZone,omitempty"`
 	Clobber       bool                   `json:"clobber,omitempty"`
 	Fields        map[string]interface{} `json:"fields,omitempty"`
+	HostMetadata  *HostMetadata          `json:"hostMetadata,omitempty"`
 }
 
 type OpenRegisterResponsePayload struct {
@@ -15,10 +15,14 @@
 package sumologicextension
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
 	"time"
 
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/config/confighttp"
+	"go.uber.org/zap/zapcore"
 )
 
 // Config has the configuration for the sumologic extension.
@@ -51,11 +55,34 @@ type Config struct {
 
 	HeartBeatInterval time.Duration `mapstructure:"heartbeat_interval"`
 
+	// HeartBeatJitter is the fraction of heartbeat_interval by which
+	// the interval is randomized, so that many collectors started at the same
+	// time don't send heartbeats at the same time.
+	HeartBeatJitter float64 `mapstructure:"heartbeat_jitter"`
+
+	// HeartBeatUnhealthyAfter is the time after which the extension is
+	// reported as unhealthy when heartbeats keep failing.
+	HeartBeatUnhealthyAfter time.Duration `mapstructure:"heartbeat_unhealthy_after"`
+
 	// CollectorCredentialsDirectory is the directory where state files
 	// with collector credentials will be stored after successful collector
 	// registration. Default value is $HOME/.sumologic-otel-collector
 	CollectorCredentialsDirectory string `mapstructure:"collector_credentials_directory"`
 
+	// CredentialsStore defines where the collector credentials are stored
+	// after successful collector registration.
+	CredentialsStore credentialsStoreConfig `mapstructure:"credentials_store"`
+
+	// CredentialsRotationInterval defines how often the collector credentials
+	// are replaced with new ones obtained from the API.
+	// By default this is 0, which disables the rotation.
+	CredentialsRotationInterval time.Duration `mapstructure:"credentials_rotation_interval"`
+
+	// CredentialsEncryption defines the source of additional key material
+	// used to encrypt the stored credentials. Without it the encryption key
+	// is derived from the collector name, install token and API URL only.
+	CredentialsEncryption credentialsEncryptionConfig `mapstructure:"credentials_encryption"`
+
 	// Clobber defines whether to delete any existing collector with the same
 	// name and create a new one upon registration.
 	// By default this is false.
@@ -77,6 +104,16 @@ type Config struct {
 	// By default this is false.
 	Ephemeral bool `mapstructure:"ephemeral"`
 
+	// DeregisterOnShutdown defines whether to deregister the collector and
+	// delete the stored credentials when the collector shuts down cleanly.
+	// This is mostly useful together with Ephemeral, so that short lived
+	// collectors don't wait 12 hours to be deleted.
+	// By default this is false.
+	DeregisterOnShutdown bool `mapstructure:"deregister_on_shutdown"`
+
+	// DeregisterTimeout bounds the time spent on deregistration, including retries.
+	DeregisterTimeout time.Duration `mapstructure:"deregister_timeout"`
+
 	// TimeZone defines the time zone of the Collector.
 	// For a list of possible values, refer to the "TZ" column in
 	// https://en.wikipedia.org/wiki/List_of_tz_database_time_zones#List.
@@ -86,10 +123,29 @@ type Config struct {
 	// Exponential algorithm is being used.
 	// Please see following link for details: https://github.com/cenkalti/backoff
 	BackOff backOffConfig `mapstructure:"backoff"`
+
+	// SelfObservability defines forwarding of the collector's own metrics
+	// and logs to Sumo Logic, tagged with the collector ID.
+	SelfObservability selfObservabilityConfig `mapstructure:"self_observability"`
+
+	// HostMetadata defines discovery of the host metadata which is sent
+	// upon collector registration.
+	HostMetadata hostMetadataConfig `mapstructure:"host_metadata"`
+
+	// OpAMP defines remote configuration management using OpAMP.
+	OpAMP opampConfig `mapstructure:"opamp"`
+
+	// Status defines the local endpoint reporting the registration
+	// and heartbeat state.
+	Status statusConfig `mapstructure:"status"`
 }
 
 type accessCredentials struct {
 	InstallToken string `mapstructure:"install_token"`
+	// InstallTokenFile is the path to a file containing the install token.
+	// The file is read every time the collector registers, so the token can
+	// be rotated without restarting the collector.
+	InstallTokenFile string `mapstructure:"install_token_file"`
 }
 
 // backOff configuration. See following link for details:
@@ -99,3 +155,164 @@ type backOffConfig struct {
 	MaxInterval     time.Duration `mapstructure:"max_interval"`
 	MaxElapsedTime  time.Duration `mapstructure:"max_elapsed_time"`
 }
+
+type credentialsStoreType string
+
+const (
+	// credentialsStoreLocalFs stores the credentials in files in
+	// collector_credentials_directory.
+	credentialsStoreLocalFs credentialsStoreType = "local_fs"
+	// credentialsStoreKubernetesSecret stores the credentials in a Kubernetes
+	// Secret, using the in-cluster API.
+	credentialsStoreKubernetesSecret credentialsStoreType = "kubernetes_secret"
+	// credentialsStoreMemory keeps the credentials in memory only.
+	credentialsStoreMemory credentialsStoreType = "memory"
+)
+
+type credentialsStoreConfig struct {
+	// Type is the type of the store, one of: local_fs, kubernetes_secret, memory.
+	// By default this is local_fs.
+	Type credentialsStoreType `mapstructure:"type"`
+
+	KubernetesSecret kubernetesSecretConfig `mapstructure:"kubernetes_secret"`
+}
+
+type kubernetesSecretConfig struct {
+	// Name is the name of the secret, it's created if it doesn't exist.
+	Name string `mapstructure:"name"`
+	// Namespace is the namespace of the secret.
+	// By default it's the namespace of the collector pod.
+	Namespace string `mapstructure:"namespace"`
+}
+
+// credentialsEncryptionConfig defines the source of the key material.
+// At most one of the sources can be set.
+type credentialsEncryptionConfig struct {
+	// KeyFile is the path to a file containing the key material.
+	KeyFile string `mapstructure:"key_file"`
+	// KeyEnv is the name of the environment variable containing the key material.
+	KeyEnv string `mapstructure:"key_env"`
+	// KeyringKey is the description of a `user` type key in the Linux kernel
+	// keyring containing the key material.
+	KeyringKey string `mapstructure:"keyring_key"`
+	// MachineID defines whether to use /etc/machine-id as the key material,
+	// binding the stored credentials to the machine.
+	MachineID bool `mapstructure:"machine_id"`
+}
+
+type selfObservabilityConfig struct {
+	// Metrics defines whether to forward the collector's own metrics.
+	Metrics bool `mapstructure:"metrics"`
+	// Logs defines whether to forward the collector's own logs.
+	// This requires the collector logger to be wrapped with
+	// WrapCoreWithSelfObservability.
+	Logs bool `mapstructure:"logs"`
+	// LogLevel is the minimum level of the forwarded logs.
+	LogLevel zapcore.Level `mapstructure:"log_level"`
+	// Interval defines how often metrics are collected and logs are sent.
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+type hostMetadataConfig struct {
+	// Enabled defines whether to send OS, kernel, architecture, collector
+	// version, IP addresses, cloud and Kubernetes identity and enabled
+	// components upon registration.
+	// By default this is false.
+	Enabled bool `mapstructure:"enabled"`
+	// CloudMetadataEndpoint is the URL of the cloud instance metadata service.
+	// Empty value disables cloud identity discovery.
+	CloudMetadataEndpoint string `mapstructure:"cloud_metadata_endpoint"`
+	// CloudMetadataTimeout is the timeout of cloud identity discovery.
+	CloudMetadataTimeout time.Duration `mapstructure:"cloud_metadata_timeout"`
+}
+
+type opampConfig struct {
+	// Enabled defines whether to connect to the OpAMP server, report
+	// the effective configuration and health and accept remote configuration.
+	// By default this is false.
+	Enabled bool `mapstructure:"enabled"`
+	// Endpoint is the URL of the OpAMP server, ws:// and wss:// URLs use
+	// WebSocket transport, http:// and https:// plain HTTP transport.
+	Endpoint string `mapstructure:"endpoint"`
+	// ConfigurationGlob is the pattern of the configuration files, the same
+	// as passed to the collector with --config=glob:<pattern>.
+	ConfigurationGlob string `mapstructure:"configuration_glob"`
+	// RemoteConfigurationFile is the file the remote configuration is written to.
+	// It has to match configuration_glob.
+	RemoteConfigurationFile string `mapstructure:"remote_configuration_file"`
+	// ReloadTimeout is the time the collector has to reload the written
+	// remote configuration, after which the previous one is restored.
+	ReloadTimeout time.Duration `mapstructure:"reload_timeout"`
+}
+
+type statusConfig struct {
+	// Enabled defines whether to serve the status endpoint.
+	// By default this is false.
+	Enabled bool `mapstructure:"enabled"`
+	// Endpoint is the address the status endpoint listens on.
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// Validate checks that the configuration is valid.
+func (cfg *Config) Validate() error {
+	var sources int
+	for _, set := range []bool{
+		cfg.CredentialsEncryption.KeyFile != "",
+		cfg.CredentialsEncryption.KeyEnv != "",
+		cfg.CredentialsEncryption.KeyringKey != "",
+		cfg.CredentialsEncryption.MachineID,
+	} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("only one of key_file, key_env, keyring_key and machine_id can be set in credentials_encryption")
+	}
+
+	if cfg.Credentials.InstallToken != "" && cfg.Credentials.InstallTokenFile != "" {
+		return errors.New("only one of install_token and install_token_file can be set")
+	}
+
+	if cfg.OpAMP.Enabled {
+		if err := cfg.OpAMP.validate(); err != nil {
+			return fmt.Errorf("invalid opamp configuration: %w", err)
+		}
+	}
+
+	if cfg.CredentialsRotationInterval < 0 {
+		return errors.New("credentials_rotation_interval cannot be negative")
+	}
+
+	if cfg.Status.Enabled && cfg.Status.Endpoint == "" {
+		return errors.New("status endpoint has to be set")
+	}
+
+	if cfg.HeartBeatJitter < 0 || cfg.HeartBeatJitter >= 1 {
+		return errors.New("heartbeat_jitter has to be in range [0, 1)")
+	}
+
+	return nil
+}
+
+func (cfg opampConfig) validate() error {
+	if cfg.Endpoint == "" {
+		return errors.New("endpoint has to be set")
+	}
+	if cfg.ConfigurationGlob == "" || cfg.RemoteConfigurationFile == "" {
+		return errors.New("configuration_glob and remote_configuration_file have to be set")
+	}
+	matched, err := filepath.Match(cfg.ConfigurationGlob, cfg.RemoteConfigurationFile)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return fmt.Errorf("remote_configuration_file %s doesn't match configuration_glob %s",
+			cfg.RemoteConfigurationFile, cfg.ConfigurationGlob,
+		)
+	}
+	if cfg.ReloadTimeout <= 0 {
+		return errors.New("reload_timeout has to be greater than 0")
+	}
+	return nil
+}
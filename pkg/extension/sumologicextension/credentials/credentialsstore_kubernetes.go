@@ -0,0 +1,280 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	DefaultKubernetesSecretName = "sumologic-otel-collector-credentials"
+
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// KubernetesSecretStore implements Store interface and can be used to store
+// and retrieve collector credentials from a Kubernetes Secret, so that
+// the credentials survive pod restarts.
+//
+// Every set of credentials is stored encrypted under a separate key
+// in the secret data, the same way LocalFsStore stores them in separate files.
+type KubernetesSecretStore struct {
+	client     kubernetes.Interface
+	namespace  string
+	name       string
+	logger     *zap.Logger
+	encryption encryption
+}
+
+type KubernetesSecretStoreOpt func(*KubernetesSecretStore)
+
+// WithKubernetesClient sets the client used to access the API.
+// By default the in-cluster client is created.
+func WithKubernetesClient(c kubernetes.Interface) KubernetesSecretStoreOpt {
+	return func(s *KubernetesSecretStore) {
+		s.client = c
+	}
+}
+
+// WithSecretNamespace sets the namespace of the secret.
+// By default it's the namespace of the pod.
+func WithSecretNamespace(namespace string) KubernetesSecretStoreOpt {
+	return func(s *KubernetesSecretStore) {
+		s.namespace = namespace
+	}
+}
+
+// WithSecretName sets the name of the secret.
+func WithSecretName(name string) KubernetesSecretStoreOpt {
+	return func(s *KubernetesSecretStore) {
+		s.name = name
+	}
+}
+
+func WithKubernetesLogger(l *zap.Logger) KubernetesSecretStoreOpt {
+	return func(s *KubernetesSecretStore) {
+		s.logger = l
+	}
+}
+
+// WithSecretEncryptionKeyMaterial sets the additional key material used for
+// deriving the credentials encryption key.
+// Credentials encrypted without it are re-encrypted when read.
+func WithSecretEncryptionKeyMaterial(material []byte) KubernetesSecretStoreOpt {
+	return func(s *KubernetesSecretStore) {
+		s.encryption.material = material
+	}
+}
+
+func NewKubernetesSecretStore(opts ...KubernetesSecretStoreOpt) (Store, error) {
+	store := KubernetesSecretStore{
+		name:   DefaultKubernetesSecretName,
+		logger: zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(&store)
+	}
+
+	if store.client == nil {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create in-cluster Kubernetes config: %w", err)
+		}
+		if store.client, err = kubernetes.NewForConfig(config); err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+		}
+	}
+
+	if store.namespace == "" {
+		namespace, err := os.ReadFile(serviceAccountNamespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("secret namespace not provided and cannot be read from service account: %w", err)
+		}
+		store.namespace = strings.TrimSpace(string(namespace))
+	}
+
+	if store.name == "" {
+		return nil, errors.New("secret name cannot be empty")
+	}
+
+	return store, nil
+}
+
+// Check checks if collector credentials can be found in the secret
+// under a hash of provided key.
+func (s KubernetesSecretStore) Check(key string) bool {
+	dataKey, err := HashKeyToFilename(key)
+	if err != nil {
+		return false
+	}
+
+	secret, err := s.getSecret()
+	if err != nil {
+		return false
+	}
+	_, ok := secret.Data[dataKey]
+	return ok
+}
+
+// Get retrieves collector credentials stored in the secret and then
+// decrypts it using a hash of provided key.
+func (s KubernetesSecretStore) Get(key string) (CollectorCredentials, error) {
+	dataKey, err := HashKeyToFilename(key)
+	if err != nil {
+		return CollectorCredentials{}, err
+	}
+
+	secret, err := s.getSecret()
+	if err != nil {
+		return CollectorCredentials{}, err
+	}
+	encryptedCreds, ok := secret.Data[dataKey]
+	if !ok {
+		return CollectorCredentials{}, fmt.Errorf("collector credentials not found in secret %s/%s", s.namespace, s.name)
+	}
+
+	credentialsInfo, legacy, err := s.encryption.decryptCredentials(key, encryptedCreds)
+	if err != nil {
+		return CollectorCredentials{}, err
+	}
+
+	s.logger.Info("Collector registration credentials retrieved from Kubernetes secret",
+		zap.String("namespace", s.namespace),
+		zap.String("secret", s.name),
+	)
+
+	if legacy {
+		if err := s.Store(key, credentialsInfo); err != nil {
+			s.logger.Warn("Unable to re-encrypt collector credentials", zap.Error(err))
+		}
+	}
+
+	return credentialsInfo, nil
+}
+
+// Store stores collector credentials in the secret, creating the secret
+// if it doesn't exist yet.
+// The credentials are encrypted using the provided key.
+func (s KubernetesSecretStore) Store(key string, creds CollectorCredentials) error {
+	dataKey, err := HashKeyToFilename(key)
+	if err != nil {
+		return err
+	}
+
+	encryptedCreds, err := s.encryption.encryptCredentials(key, creds)
+	if err != nil {
+		return err
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := s.getSecret()
+		if k8serrors.IsNotFound(err) {
+			_, err = s.client.CoreV1().Secrets(s.namespace).Create(context.Background(), &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      s.name,
+					Namespace: s.namespace,
+				},
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{dataKey: encryptedCreds},
+			}, metav1.CreateOptions{})
+			if k8serrors.IsAlreadyExists(err) {
+				// Created in the meantime, retry with an update.
+				return k8serrors.NewConflict(corev1.Resource("secrets"), s.name, err)
+			}
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[dataKey] = encryptedCreds
+		_, err = s.client.CoreV1().Secrets(s.namespace).Update(context.Background(), secret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save credentials in secret %s/%s: %w", s.namespace, s.name, err)
+	}
+
+	s.logger.Info("Collector registration credentials stored in Kubernetes secret",
+		zap.String("namespace", s.namespace),
+		zap.String("secret", s.name),
+	)
+
+	return nil
+}
+
+// Delete removes collector credentials from the secret.
+// The secret itself is left in place.
+func (s KubernetesSecretStore) Delete(key string) error {
+	dataKey, err := HashKeyToFilename(key)
+	if err != nil {
+		return err
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := s.getSecret()
+		if k8serrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if _, ok := secret.Data[dataKey]; !ok {
+			return nil
+		}
+		delete(secret.Data, dataKey)
+		_, err = s.client.CoreV1().Secrets(s.namespace).Update(context.Background(), secret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove credentials from secret %s/%s: %w", s.namespace, s.name, err)
+	}
+
+	s.logger.Debug("Collector registration credentials removed",
+		zap.String("namespace", s.namespace),
+		zap.String("secret", s.name),
+	)
+
+	return nil
+}
+
+func (s KubernetesSecretStore) getSecret() (*corev1.Secret, error) {
+	return s.client.CoreV1().Secrets(s.namespace).Get(context.Background(), s.name, metav1.GetOptions{})
+}
+
+// Location returns the namespace, name and data key of the secret
+// the credentials are stored in.
+func (s KubernetesSecretStore) Location(key string) string {
+	location := fmt.Sprintf("secret/%s/%s", s.namespace, s.name)
+	dataKey, err := HashKeyToFilename(key)
+	if err != nil {
+		return location
+	}
+	return location + "/" + dataKey
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/api"
+)
+
+func TestCredentialsStoreKubernetesSecret(t *testing.T) {
+	const (
+		key       = "my_storage_key"
+		otherKey  = "my_other_storage_key"
+		namespace = "sumologic"
+	)
+
+	creds := CollectorCredentials{
+		CollectorName: "name",
+		Credentials: api.OpenRegisterResponsePayload{
+			CollectorCredentialId:  "credentialId",
+			CollectorCredentialKey: "credentialKey",
+			CollectorId:            "id",
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	sut, err := NewKubernetesSecretStore(
+		WithKubernetesClient(client),
+		WithSecretNamespace(namespace),
+	)
+	require.NoError(t, err)
+
+	require.False(t, sut.Check(key))
+	_, err = sut.Get(key)
+	require.Error(t, err)
+
+	// The secret gets created by the first Store and updated by the next one.
+	require.NoError(t, sut.Store(key, creds))
+	require.NoError(t, sut.Store(otherKey, creds))
+
+	require.True(t, sut.Check(key))
+	require.True(t, sut.Check(otherKey))
+
+	actual, err := sut.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, creds, actual)
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(context.Background(), DefaultKubernetesSecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, secret.Data, 2)
+	for _, v := range secret.Data {
+		// Make sure the credentials aren't stored in plain text.
+		assert.NotContains(t, string(v), "credentialKey")
+	}
+
+	require.NoError(t, sut.Delete(key))
+	require.False(t, sut.Check(key))
+	require.True(t, sut.Check(otherKey))
+
+	// Deleting not existing credentials is not an error.
+	require.NoError(t, sut.Delete(key))
+}
+
+func TestCredentialsStoreKubernetesSecretDeleteWithoutSecret(t *testing.T) {
+	sut, err := NewKubernetesSecretStore(
+		WithKubernetesClient(fake.NewSimpleClientset()),
+		WithSecretNamespace("default"),
+	)
+	require.NoError(t, err)
+	require.NoError(t, sut.Delete("key"))
+}
+
+func TestCredentialsStoreKubernetesSecretEmptyName(t *testing.T) {
+	_, err := NewKubernetesSecretStore(
+		WithKubernetesClient(fake.NewSimpleClientset()),
+		WithSecretNamespace("default"),
+		WithSecretName(""),
+	)
+	require.Error(t, err)
+}
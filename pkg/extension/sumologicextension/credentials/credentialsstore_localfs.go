@@ -15,9 +15,7 @@
 package credentials
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path"
 
@@ -45,6 +43,7 @@ func GetDefaultCollectorCredentialsDirectory() (string, error) {
 type LocalFsStore struct {
 	collectorCredentialsDirectory string
 	logger                        *zap.Logger
+	encryption                    encryption
 }
 
 type LocalFsStoreOpt func(*LocalFsStore)
@@ -61,6 +60,15 @@ func WithCredentialsDirectory(dir string) LocalFsStoreOpt {
 	}
 }
 
+// WithEncryptionKeyMaterial sets the additional key material used for
+// deriving the credentials encryption key.
+// Credentials encrypted without it are re-encrypted when read.
+func WithEncryptionKeyMaterial(material []byte) LocalFsStoreOpt {
+	return func(s *LocalFsStore) {
+		s.encryption.material = material
+	}
+}
+
 func NewLocalFsStore(opts ...LocalFsStoreOpt) (Store, error) {
 	dir, err := GetDefaultCollectorCredentialsDirectory()
 	if err != nil {
@@ -85,23 +93,14 @@ func NewLocalFsStore(opts ...LocalFsStoreOpt) (Store, error) {
 // Check checks if collector credentials can be found under a name being a hash
 // of provided key inside collectorCredentialsDirectory.
 func (cr LocalFsStore) Check(key string) bool {
-	f := func(hasher Hasher, key string) bool {
-		filenameHash, err := HashKeyToFilename(key)
+	for _, hasher := range []Hasher{_getHasher(), _getDeprecatedHasher()} {
+		path, err := cr.credentialsPath(hasher, key)
 		if err != nil {
-			return false
+			continue
 		}
-		path := path.Join(cr.collectorCredentialsDirectory, filenameHash)
-		if _, err := os.Stat(path); err != nil {
-			return false
+		if _, err := os.Stat(path); err == nil {
+			return true
 		}
-		return true
-	}
-
-	if f(_getHasher(), key) {
-		return true
-	}
-	if f(_getDeprecatedHasher(), key) {
-		return true
 	}
 
 	return false
@@ -109,56 +108,83 @@ func (cr LocalFsStore) Check(key string) bool {
 
 // Get retrieves collector credentials stored in local file system and then
 // decrypts it using a hash of provided key.
+//
+// Credentials stored using a deprecated scheme, i.e. in a file named with
+// the MD5 hash or encrypted without the configured key material, are
+// re-encrypted with the current scheme.
 func (cr LocalFsStore) Get(key string) (CollectorCredentials, error) {
-	f := func(hasher Hasher, key string) (CollectorCredentials, error) {
-		filenameHash, err := HashKeyToFilename(key)
-		if err != nil {
-			return CollectorCredentials{}, err
-		}
+	path, err := cr.credentialsPath(_getHasher(), key)
+	if err != nil {
+		return CollectorCredentials{}, err
+	}
 
-		path := path.Join(cr.collectorCredentialsDirectory, filenameHash)
-		creds, err := os.Open(path)
-		if err != nil {
-			return CollectorCredentials{}, err
-		}
-		defer creds.Close()
+	encryptedCreds, err := os.ReadFile(path)
+	if err != nil {
+		return cr.getDeprecated(key, err)
+	}
 
-		encryptedCreds, err := io.ReadAll(creds)
-		if err != nil {
-			return CollectorCredentials{}, err
-		}
+	credentialsInfo, legacy, err := cr.encryption.decryptCredentials(key, encryptedCreds)
+	if err != nil {
+		return CollectorCredentials{}, err
+	}
 
-		encKey, err := HashKeyToEncryptionKey(key)
-		if err != nil {
-			return CollectorCredentials{}, err
-		}
+	cr.logger.Info("Collector registration credentials retrieved from local fs",
+		zap.String("path", path),
+	)
 
-		collectorCreds, err := decrypt(encryptedCreds, encKey)
-		if err != nil {
-			return CollectorCredentials{}, err
-		}
+	if legacy {
+		cr.reencrypt(key, credentialsInfo, "")
+	}
 
-		var credentialsInfo CollectorCredentials
-		if err = json.Unmarshal(collectorCreds, &credentialsInfo); err != nil {
-			return CollectorCredentials{}, err
-		}
+	return credentialsInfo, nil
+}
 
-		cr.logger.Info("Collector registration credentials retrieved from local fs",
-			zap.String("path", path),
-		)
+// getDeprecated retrieves collector credentials stored in a file named
+// with the deprecated MD5 hash and migrates them to the current scheme.
+// If there's no such file then errNotFound is returned.
+func (cr LocalFsStore) getDeprecated(key string, errNotFound error) (CollectorCredentials, error) {
+	path, err := cr.credentialsPath(_getDeprecatedHasher(), key)
+	if err != nil {
+		return CollectorCredentials{}, err
+	}
 
-		return credentialsInfo, nil
+	encryptedCreds, err := os.ReadFile(path)
+	if err != nil {
+		return CollectorCredentials{}, errNotFound
 	}
 
-	if creds, err := f(_getHasher(), key); err == nil {
-		return creds, nil
+	credentialsInfo, err := decryptDeprecatedCredentials(key, encryptedCreds)
+	if err != nil {
+		return CollectorCredentials{}, err
 	}
 
-	creds, err := f(_getDeprecatedHasher(), key)
-	if err == nil {
-		return creds, nil
+	cr.logger.Info("Collector registration credentials retrieved from local fs",
+		zap.String("path", path),
+	)
+
+	cr.reencrypt(key, credentialsInfo, path)
+
+	return credentialsInfo, nil
+}
+
+// reencrypt stores the credentials using the current scheme and removes
+// the deprecated file, if provided.
+// Failures are only logged as the credentials can still be used.
+func (cr LocalFsStore) reencrypt(key string, creds CollectorCredentials, deprecatedPath string) {
+	if err := cr.Store(key, creds); err != nil {
+		cr.logger.Warn("Unable to re-encrypt collector credentials", zap.Error(err))
+		return
 	}
-	return CollectorCredentials{}, err
+
+	if deprecatedPath != "" {
+		if err := os.Remove(deprecatedPath); err != nil {
+			cr.logger.Warn("Unable to remove deprecated credentials file",
+				zap.String("path", deprecatedPath), zap.Error(err),
+			)
+		}
+	}
+
+	cr.logger.Info("Collector registration credentials re-encrypted")
 }
 
 // Store stores collector credentials in a file in directory as specified
@@ -169,60 +195,44 @@ func (cr LocalFsStore) Store(key string, creds CollectorCredentials) error {
 		return err
 	}
 
-	f := func(hasher Hasher, key string, creds CollectorCredentials) error {
-		filenameHash, err := HashKeyToFilename(key)
-		if err != nil {
-			return err
-		}
-		path := path.Join(cr.collectorCredentialsDirectory, filenameHash)
-		collectorCreds, err := json.Marshal(creds)
-		if err != nil {
-			return fmt.Errorf("failed marshalling collector credentials: %w", err)
-		}
-
-		encKey, err := HashKeyToEncryptionKey(key)
-		if err != nil {
-			return err
-		}
-
-		encryptedCreds, err := encrypt(collectorCreds, encKey)
-		if err != nil {
-			return err
-		}
+	path, err := cr.credentialsPath(_getHasher(), key)
+	if err != nil {
+		return err
+	}
 
-		if err = os.WriteFile(path, encryptedCreds, 0600); err != nil {
-			return fmt.Errorf("failed to save credentials file '%s': %w",
-				path, err,
-			)
-		}
+	encryptedCreds, err := cr.encryption.encryptCredentials(key, creds)
+	if err != nil {
+		return err
+	}
 
-		cr.logger.Info("Collector registration credentials stored locally",
-			zap.String("path", path),
+	if err = os.WriteFile(path, encryptedCreds, 0600); err != nil {
+		return fmt.Errorf("failed to save credentials file '%s': %w",
+			path, err,
 		)
-
-		return nil
 	}
 
-	if err := f(_getHasher(), key, creds); err == nil {
-		return nil
-	}
+	cr.logger.Info("Collector registration credentials stored locally",
+		zap.String("path", path),
+	)
 
-	err := f(_getDeprecatedHasher(), key, creds)
-	if err == nil {
-		return nil
+	return nil
+}
+
+func (cr LocalFsStore) credentialsPath(hasher Hasher, key string) (string, error) {
+	filenameHash, err := HashKeyToFilenameWith(hasher, key)
+	if err != nil {
+		return "", err
 	}
-	return err
+	return path.Join(cr.collectorCredentialsDirectory, filenameHash), nil
 }
 
 func (cr LocalFsStore) Delete(key string) error {
 	f := func(hasher Hasher, key string) error {
-		filenameHash, err := HashKeyToFilenameWith(hasher, key)
+		path, err := cr.credentialsPath(hasher, key)
 		if err != nil {
 			return err
 		}
 
-		path := path.Join(cr.collectorCredentialsDirectory, filenameHash)
-
 		if _, err := os.Stat(path); err != nil {
 			return nil
 		}
@@ -271,3 +281,12 @@ func ensureDirExists(path string) error {
 
 	return nil
 }
+
+// Location returns the path of the file the credentials are stored in.
+func (cr LocalFsStore) Location(key string) string {
+	path, err := cr.credentialsPath(_getHasher(), key)
+	if err != nil {
+		return cr.collectorCredentialsDirectory
+	}
+	return path
+}
@@ -15,6 +15,8 @@
 package credentials
 
 import (
+	"crypto/md5"
+	"encoding/json"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -53,6 +55,7 @@ func TestCredentialsStoreLocalFs(t *testing.T) {
 	require.NoError(t, sut.Store(key, creds))
 
 	require.True(t, sut.Check(key))
+	assert.FileExists(t, sut.Location(key))
 
 	actual, err := sut.Get(key)
 	require.NoError(t, err)
@@ -74,3 +77,93 @@ func TestCredentialsStoreLocalFs(t *testing.T) {
 	)
 	require.EqualValues(t, fileCounter, 0)
 }
+
+func TestCredentialsStoreLocalFsWithKeyMaterial(t *testing.T) {
+	dir := t.TempDir()
+
+	const key = "my_storage_key"
+
+	creds := CollectorCredentials{
+		CollectorName: "name",
+		Credentials: api.OpenRegisterResponsePayload{
+			CollectorCredentialId:  "credentialId",
+			CollectorCredentialKey: "credentialKey",
+			CollectorId:            "id",
+		},
+	}
+
+	withoutMaterial := LocalFsStore{
+		collectorCredentialsDirectory: dir,
+		logger:                        zap.NewNop(),
+	}
+	withMaterial := LocalFsStore{
+		collectorCredentialsDirectory: dir,
+		logger:                        zap.NewNop(),
+		encryption:                    encryption{material: []byte("secret key material")},
+	}
+
+	// Credentials stored without the key material are re-encrypted on read.
+	require.NoError(t, withoutMaterial.Store(key, creds))
+	actual, err := withMaterial.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, creds, actual)
+
+	// Now they can't be decrypted without the key material anymore.
+	_, err = withoutMaterial.Get(key)
+	require.Error(t, err)
+
+	actual, err = withMaterial.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, creds, actual)
+
+	// Different key material can't decrypt the credentials.
+	otherMaterial := withMaterial
+	otherMaterial.encryption = encryption{material: []byte("other key material")}
+	_, err = otherMaterial.Get(key)
+	require.Error(t, err)
+}
+
+func TestCredentialsStoreLocalFsMigratesDeprecatedMd5(t *testing.T) {
+	dir := t.TempDir()
+
+	const key = "my_storage_key"
+
+	creds := CollectorCredentials{
+		CollectorName: "name",
+		Credentials: api.OpenRegisterResponsePayload{
+			CollectorCredentialId:  "credentialId",
+			CollectorCredentialKey: "credentialKey",
+			CollectorId:            "id",
+		},
+	}
+
+	// Prepare the credentials file the way older versions created it.
+	plaintext, err := json.Marshal(creds)
+	require.NoError(t, err)
+	encKey, err := HashKeyToEncryptionKeyWith(md5.New(), key)
+	require.NoError(t, err)
+	encrypted, err := encrypt(plaintext, encKey)
+	require.NoError(t, err)
+	filenameMd5, err := HashKeyToFilenameWith(md5.New(), key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, filenameMd5), encrypted, 0600))
+
+	sut := LocalFsStore{
+		collectorCredentialsDirectory: dir,
+		logger:                        zap.NewNop(),
+	}
+	require.True(t, sut.Check(key))
+
+	actual, err := sut.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, creds, actual)
+
+	filename, err := HashKeyToFilename(key)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, filename))
+	assert.NoFileExists(t, filepath.Join(dir, filenameMd5))
+
+	actual, err = sut.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, creds, actual)
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryStore implements Store interface and keeps collector credentials
+// in memory only. The credentials are lost when the collector exits,
+// so it's mostly useful for tests.
+type MemoryStore struct {
+	mu    *sync.RWMutex
+	creds map[string]CollectorCredentials
+}
+
+func NewMemoryStore() Store {
+	return MemoryStore{
+		mu:    &sync.RWMutex{},
+		creds: map[string]CollectorCredentials{},
+	}
+}
+
+// Check checks if collector credentials are stored under the provided key.
+func (s MemoryStore) Check(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.creds[key]
+	return ok
+}
+
+// Get returns collector credentials stored under the provided key.
+func (s MemoryStore) Get(key string) (CollectorCredentials, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	creds, ok := s.creds[key]
+	if !ok {
+		return CollectorCredentials{}, fmt.Errorf("collector credentials not found")
+	}
+	return creds, nil
+}
+
+// Store stores collector credentials under the provided key.
+func (s MemoryStore) Store(key string, creds CollectorCredentials) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creds[key] = creds
+	return nil
+}
+
+// Delete deletes collector credentials stored under the provided key.
+func (s MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.creds, key)
+	return nil
+}
+
+// Location returns "memory" as the credentials are not persisted.
+func (s MemoryStore) Location(key string) string {
+	return "memory"
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/api"
+)
+
+func TestCredentialsStoreMemory(t *testing.T) {
+	const key = "my_storage_key"
+
+	creds := CollectorCredentials{
+		CollectorName: "name",
+		Credentials: api.OpenRegisterResponsePayload{
+			CollectorCredentialId:  "credentialId",
+			CollectorCredentialKey: "credentialKey",
+			CollectorId:            "id",
+		},
+	}
+
+	sut := NewMemoryStore()
+
+	require.False(t, sut.Check(key))
+	_, err := sut.Get(key)
+	require.Error(t, err)
+
+	require.NoError(t, sut.Store(key, creds))
+	require.True(t, sut.Check(key))
+
+	actual, err := sut.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, creds, actual)
+
+	require.NoError(t, sut.Delete(key))
+	require.False(t, sut.Check(key))
+}
@@ -17,10 +17,12 @@ package credentials
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 )
@@ -79,66 +81,129 @@ func HashKeyToEncryptionKeyWith(hasher Hasher, key string) ([]byte, error) {
 	return b[:32], nil
 }
 
-// encrypt encrypts provided byte slice with AES using the encryption key.
-func encrypt(data []byte, encryptionKey []byte) ([]byte, error) {
-	f := func(hasher Hasher, data []byte, encryptionKey []byte) ([]byte, error) {
-		block, err := aes.NewCipher(encryptionKey)
-		if err != nil {
-			return nil, err
-		}
-		gcm, err := cipher.NewGCM(block)
-		if err != nil {
-			return nil, err
-		}
-		nonce := make([]byte, gcm.NonceSize())
-		if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-			return nil, err
-		}
-		ciphertext := gcm.Seal(nonce, nonce, data, nil)
-		return ciphertext, nil
+// HashKeyToEncryptionKeyWithMaterial creates a 32 bytes long key from
+// the provided key and the user provided key material, using HMAC-SHA256 with
+// the key material as the HMAC key.
+// Without the key material it falls back to HashKeyToEncryptionKey.
+func HashKeyToEncryptionKeyWithMaterial(key string, material []byte) ([]byte, error) {
+	if len(material) == 0 {
+		return HashKeyToEncryptionKey(key)
+	}
+
+	mac := hmac.New(sha256.New, material)
+	if _, err := mac.Write([]byte(encryptionKeyPrefix + key)); err != nil {
+		return nil, err
 	}
+	return mac.Sum(nil), nil
+}
+
+// encryption encrypts and decrypts collector credentials with keys derived
+// from the store key and the optional key material.
+type encryption struct {
+	material []byte
+}
 
-	if ret, err := f(_getHasher(), data, encryptionKey); err == nil {
-		return ret, nil
+// encryptCredentials marshals and encrypts the credentials with
+// the current encryption scheme.
+func (e encryption) encryptCredentials(key string, creds CollectorCredentials) ([]byte, error) {
+	collectorCreds, err := json.Marshal(creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed marshalling collector credentials: %w", err)
 	}
 
-	ret, err := f(_getDeprecatedHasher(), data, encryptionKey)
-	if err == nil {
-		return ret, nil
+	encKey, err := HashKeyToEncryptionKeyWithMaterial(key, e.material)
+	if err != nil {
+		return nil, err
 	}
-	return nil, err
+
+	return encrypt(collectorCreds, encKey)
 }
 
-// decrypt decrypts provided byte slice with AES using the encryptionKey.
-func decrypt(data []byte, encryptionKey []byte) ([]byte, error) {
-	f := func(hasher Hasher, data []byte, encryptionKey []byte) ([]byte, error) {
-		block, err := aes.NewCipher(encryptionKey)
-		if err != nil {
-			return nil, fmt.Errorf("unable tocreate new aes cipher: %w", err)
-		}
-		gcm, err := cipher.NewGCM(block)
-		if err != nil {
-			return nil, fmt.Errorf("unable to create new cipher gcm: %w", err)
-		}
-		nonceSize := gcm.NonceSize()
-		if nonceSize > len(data) {
-			return nil, fmt.Errorf("unable to decrypt credentials")
+// decryptCredentials decrypts the credentials trying the current encryption
+// scheme first. When key material is set, the key derived without it is
+// tried as well, in which case legacy is set to true so that the caller can
+// re-encrypt the credentials.
+func (e encryption) decryptCredentials(key string, data []byte) (creds CollectorCredentials, legacy bool, err error) {
+	encKey, err := HashKeyToEncryptionKeyWithMaterial(key, e.material)
+	if err != nil {
+		return CollectorCredentials{}, false, err
+	}
+
+	plaintext, err := decrypt(data, encKey)
+	if err != nil && len(e.material) > 0 {
+		legacyKey, errKey := HashKeyToEncryptionKey(key)
+		if errKey != nil {
+			return CollectorCredentials{}, false, errKey
 		}
-		nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-		plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-		if err != nil {
-			return nil, fmt.Errorf("unable to decrypt: %w", err)
+		if plaintext, err = decrypt(data, legacyKey); err == nil {
+			legacy = true
 		}
-		return plaintext, nil
+	}
+	if err != nil {
+		return CollectorCredentials{}, false, err
+	}
+
+	if err = json.Unmarshal(plaintext, &creds); err != nil {
+		return CollectorCredentials{}, false, err
+	}
+	return creds, legacy, nil
+}
+
+// decryptDeprecatedCredentials decrypts the credentials which were encrypted
+// with the key derived using the deprecated MD5 hasher.
+func decryptDeprecatedCredentials(key string, data []byte) (CollectorCredentials, error) {
+	encKey, err := HashKeyToEncryptionKeyWith(_getDeprecatedHasher(), key)
+	if err != nil {
+		return CollectorCredentials{}, err
 	}
 
-	if ret, err := f(_getHasher(), data, encryptionKey); err == nil {
-		return ret, nil
+	plaintext, err := decrypt(data, encKey)
+	if err != nil {
+		return CollectorCredentials{}, err
 	}
 
-	ret, err := f(_getDeprecatedHasher(), data, encryptionKey)
-	if err == nil {
-		return ret, nil
+	var creds CollectorCredentials
+	if err = json.Unmarshal(plaintext, &creds); err != nil {
+		return CollectorCredentials{}, err
+	}
+	return creds, nil
+}
+
+// encrypt encrypts provided byte slice with AES using the encryption key.
+func encrypt(data []byte, encryptionKey []byte) ([]byte, error) {
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decrypt decrypts provided byte slice with AES using the encryptionKey.
+func decrypt(data []byte, encryptionKey []byte) ([]byte, error) {
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new aes cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new cipher gcm: %w", err)
+	}
+	nonceSize := gcm.NonceSize()
+	if nonceSize > len(data) {
+		return nil, fmt.Errorf("unable to decrypt credentials")
+	}
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt: %w", err)
 	}
-	return nil, err
+	return plaintext, nil
 }
@@ -0,0 +1,70 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// machineIDFiles are the locations of the machine ID, in the order of preference.
+var machineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// KeyMaterialFromFile returns the key material stored in the file.
+// Leading and trailing white space is ignored.
+func KeyMaterialFromFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key material file: %w", err)
+	}
+	return nonEmptyKeyMaterial(bytes.TrimSpace(content), path)
+}
+
+// KeyMaterialFromEnv returns the key material stored in the environment variable.
+func KeyMaterialFromEnv(name string) ([]byte, error) {
+	return nonEmptyKeyMaterial([]byte(os.Getenv(name)), "environment variable "+name)
+}
+
+// KeyMaterialFromMachineID returns the machine ID as the key material,
+// binding the stored credentials to the machine.
+func KeyMaterialFromMachineID() ([]byte, error) {
+	var err error
+	for _, path := range machineIDFiles {
+		var material []byte
+		if material, err = KeyMaterialFromFile(path); err == nil {
+			return material, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to read machine ID: %w", err)
+}
+
+// KeyMaterialFromKeyring returns the payload of the `user` type key with
+// the provided description from the Linux kernel keyring.
+// The session keyring is searched first, then the user keyring.
+func KeyMaterialFromKeyring(description string) ([]byte, error) {
+	material, err := readKeyring(description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %q from kernel keyring: %w", description, err)
+	}
+	return nonEmptyKeyMaterial(material, "keyring key "+description)
+}
+
+func nonEmptyKeyMaterial(material []byte, source string) ([]byte, error) {
+	if len(material) == 0 {
+		return nil, fmt.Errorf("key material from %s is empty", source)
+	}
+	return material, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyMaterialFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte("  key material\n"), 0600))
+
+	material, err := KeyMaterialFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key material"), material)
+
+	require.NoError(t, os.WriteFile(path, []byte("\n"), 0600))
+	_, err = KeyMaterialFromFile(path)
+	require.Error(t, err)
+
+	_, err = KeyMaterialFromFile(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func TestKeyMaterialFromEnv(t *testing.T) {
+	t.Setenv("SUMOLOGIC_TEST_KEY_MATERIAL", "key material")
+
+	material, err := KeyMaterialFromEnv("SUMOLOGIC_TEST_KEY_MATERIAL")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key material"), material)
+
+	_, err = KeyMaterialFromEnv("SUMOLOGIC_TEST_KEY_MATERIAL_MISSING")
+	require.Error(t, err)
+}
+
+func TestHashKeyToEncryptionKeyWithMaterial(t *testing.T) {
+	legacy, err := HashKeyToEncryptionKey("key")
+	require.NoError(t, err)
+
+	withoutMaterial, err := HashKeyToEncryptionKeyWithMaterial("key", nil)
+	require.NoError(t, err)
+	assert.Equal(t, legacy, withoutMaterial)
+
+	withMaterial, err := HashKeyToEncryptionKeyWithMaterial("key", []byte("material"))
+	require.NoError(t, err)
+	assert.Len(t, withMaterial, 32)
+	assert.NotEqual(t, legacy, withMaterial)
+}
@@ -15,6 +15,8 @@
 package credentials
 
 import (
+	"time"
+
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/api"
 )
 
@@ -33,6 +35,14 @@ type CollectorCredentials struct {
 	// API base URL so that when the collector starts up again it can use this
 	// API base URL for communication with the backend.
 	ApiBaseUrl string `json:"apiBaseUrl"`
+	// Metadata holds the collector metadata which was last sent to the API.
+	// It's used to detect changes in the configuration, which then can be
+	// sent to the API without re-registering the collector.
+	Metadata *api.OpenCollectorUpdateRequestPayload `json:"metadata,omitempty"`
+	// IssuedAt is the time the credentials were obtained, it's used to
+	// schedule the credentials rotation. It's zero for credentials stored
+	// by older versions.
+	IssuedAt time.Time `json:"issuedAt"`
 }
 
 // Store is an interface to get collector authentication data
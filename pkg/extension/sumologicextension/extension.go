@@ -35,11 +35,13 @@ import (
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/config/configauth"
 	"go.opentelemetry.io/collector/config/confighttp"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	grpccredentials "google.golang.org/grpc/credentials"
 
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/api"
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/credentials"
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/observability"
 )
 
 type SumologicExtension struct {
@@ -56,17 +58,55 @@ type SumologicExtension struct {
 	logger           *zap.Logger
 	credentialsStore credentials.Store
 	hashKey          string
-	httpClient       *http.Client
-	registrationInfo api.OpenRegisterResponsePayload
+	buildInfo        component.BuildInfo
+
+	// The lock around the collector credentials and the HTTP client using
+	// them is needed because they are replaced when the credentials are
+	// rotated while being used by exporters and other goroutines.
+	credentialsLock     sync.RWMutex
+	httpClient          *http.Client
+	registrationInfo    api.OpenRegisterResponsePayload
+	credentialsIssuedAt time.Time
+
+	// credentialsListeners are notified when the collector credentials change.
+	credentialsListenersLock sync.Mutex
+	credentialsListeners     map[int]func()
+	credentialsListenerId    int
 
 	closeChan chan struct{}
 	closeOnce sync.Once
 	backOff   *backoff.ExponentialBackOff
+
+	// healthLock guards the heartbeat state: heartbeatFailingSince is the time
+	// of the first failed heartbeat since the last successful one, zero if
+	// the last heartbeat succeeded, and heartbeatErr is the last error.
+	healthLock            sync.RWMutex
+	heartbeatFailingSince time.Time
+	heartbeatErr          error
+
+	// logBuffer collects the collector's own logs when self observability
+	// logs forwarding is enabled.
+	logBuffer *logBuffer
+	// selfObservabilityDone is closed after the last self observability
+	// data has been sent.
+	selfObservabilityDone chan struct{}
+
+	// opamp manages the remote configuration when OpAMP is enabled.
+	opamp *opampAgent
+
+	// status is the state reported by the status endpoint served by
+	// statusServer on statusAddr when it's enabled.
+	status       collectorStatus
+	statusServer *http.Server
+	statusAddr   string
 }
 
 const (
-	heartbeatUrl = "/api/v1/collector/heartbeat"
-	registerUrl  = "/api/v1/collector/register"
+	heartbeatUrl       = "/api/v1/collector/heartbeat"
+	registerUrl        = "/api/v1/collector/register"
+	collectorUpdateUrl = "/api/v1/collector/update"
+	deregisterUrl      = "/api/v1/collector/deregister"
+	rotateUrl          = "/api/v1/collector/credentials/rotate"
 
 	collectorIdField           = "collector_id"
 	collectorNameField         = "collector_name"
@@ -74,27 +114,26 @@ const (
 )
 
 const (
-	DefaultHeartbeatInterval = 15 * time.Second
+	DefaultHeartbeatInterval       = 15 * time.Second
+	DefaultHeartbeatJitter         = 0.1
+	DefaultHeartbeatUnhealthyAfter = 5 * time.Minute
+	DefaultDeregisterTimeout       = 10 * time.Second
 )
 
-var errGRPCNotSupported = fmt.Errorf("gRPC is not supported by sumologicextension")
-
-// SumologicExtension implements ClientAuthenticator
+// SumologicExtension implements ClientAuthenticator and PipelineWatcher
 var _ configauth.ClientAuthenticator = (*SumologicExtension)(nil)
+var _ component.PipelineWatcher = (*SumologicExtension)(nil)
 
 func newSumologicExtension(conf *Config, logger *zap.Logger) (*SumologicExtension, error) {
-	if conf.Credentials.InstallToken == "" {
-		return nil, errors.New("access credentials not provided: need install_token")
+	if conf.Credentials.InstallToken == "" && conf.Credentials.InstallTokenFile == "" {
+		return nil, errors.New("access credentials not provided: need install_token or install_token_file")
 	}
 	hostname, err := os.Hostname()
 	if err != nil {
 		return nil, err
 	}
 
-	credentialsStore, err := credentials.NewLocalFsStore(
-		credentials.WithCredentialsDirectory(conf.CollectorCredentialsDirectory),
-		credentials.WithLogger(logger),
-	)
+	credentialsStore, err := newCredentialsStore(conf, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize credentials store: %w", err)
 	}
@@ -120,6 +159,18 @@ func newSumologicExtension(conf *Config, logger *zap.Logger) (*SumologicExtensio
 		conf.HeartBeatInterval = DefaultHeartbeatInterval
 	}
 
+	if conf.HeartBeatUnhealthyAfter <= 0 {
+		conf.HeartBeatUnhealthyAfter = DefaultHeartbeatUnhealthyAfter
+	}
+
+	if conf.DeregisterTimeout <= 0 {
+		conf.DeregisterTimeout = DefaultDeregisterTimeout
+	}
+
+	if conf.SelfObservability.Interval <= 0 {
+		conf.SelfObservability.Interval = DefaultSelfObservabilityInterval
+	}
+
 	// Prepare ExponentialBackoff
 	backOff := backoff.NewExponentialBackOff()
 	backOff.InitialInterval = conf.BackOff.InitialInterval
@@ -136,20 +187,108 @@ func newSumologicExtension(conf *Config, logger *zap.Logger) (*SumologicExtensio
 		credentialsStore: credentialsStore,
 		closeChan:        make(chan struct{}),
 		backOff:          backOff,
+		status:           collectorStatus{collectorName: collectorName},
+
+		credentialsListeners: map[int]func(){},
 	}, nil
 }
 
+// newCredentialsStore creates the credentials store selected in the configuration.
+func newCredentialsStore(conf *Config, logger *zap.Logger) (credentials.Store, error) {
+	material, err := getKeyMaterial(conf.CredentialsEncryption)
+	if err != nil {
+		return nil, err
+	}
+
+	switch conf.CredentialsStore.Type {
+	case "", credentialsStoreLocalFs:
+		return credentials.NewLocalFsStore(
+			credentials.WithCredentialsDirectory(conf.CollectorCredentialsDirectory),
+			credentials.WithLogger(logger),
+			credentials.WithEncryptionKeyMaterial(material),
+		)
+	case credentialsStoreKubernetesSecret:
+		return credentials.NewKubernetesSecretStore(
+			credentials.WithSecretName(conf.CredentialsStore.KubernetesSecret.Name),
+			credentials.WithSecretNamespace(conf.CredentialsStore.KubernetesSecret.Namespace),
+			credentials.WithKubernetesLogger(logger),
+			credentials.WithSecretEncryptionKeyMaterial(material),
+		)
+	case credentialsStoreMemory:
+		return credentials.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown credentials store type: %q", conf.CredentialsStore.Type)
+	}
+}
+
+// getKeyMaterial returns the credentials encryption key material from
+// the configured source or nil if none is configured.
+func getKeyMaterial(conf credentialsEncryptionConfig) ([]byte, error) {
+	switch {
+	case conf.KeyFile != "":
+		return credentials.KeyMaterialFromFile(conf.KeyFile)
+	case conf.KeyEnv != "":
+		return credentials.KeyMaterialFromEnv(conf.KeyEnv)
+	case conf.KeyringKey != "":
+		return credentials.KeyMaterialFromKeyring(conf.KeyringKey)
+	case conf.MachineID:
+		return credentials.KeyMaterialFromMachineID()
+	default:
+		return nil, nil
+	}
+}
+
 func createHashKey(conf *Config) string {
+	installToken := conf.Credentials.InstallToken
+	if conf.Credentials.InstallTokenFile != "" {
+		// The token in the file can be rotated, use the path so that
+		// the stored credentials are still found afterwards.
+		installToken = conf.Credentials.InstallTokenFile
+	}
+
 	return fmt.Sprintf("%s%s%s",
 		conf.CollectorName,
-		conf.Credentials.InstallToken,
+		installToken,
 		strings.TrimSuffix(conf.ApiBaseUrl, "/"),
 	)
 }
 
-func (se *SumologicExtension) Start(ctx context.Context, host component.Host) error {
+// getAccessCredentials returns the credentials used for registration,
+// reading the install token from install_token_file if it's set.
+func (se *SumologicExtension) getAccessCredentials() (accessCredentials, error) {
+	if se.conf.Credentials.InstallTokenFile == "" {
+		return se.conf.Credentials, nil
+	}
+
+	token, err := os.ReadFile(se.conf.Credentials.InstallTokenFile)
+	if err != nil {
+		return accessCredentials{}, fmt.Errorf("cannot read install token file: %w", err)
+	}
+	installToken := strings.TrimSpace(string(token))
+	if installToken == "" {
+		return accessCredentials{}, fmt.Errorf("install token file %s is empty", se.conf.Credentials.InstallTokenFile)
+	}
+
+	return accessCredentials{
+		InstallToken:     installToken,
+		InstallTokenFile: se.conf.Credentials.InstallTokenFile,
+	}, nil
+}
+
+func (se *SumologicExtension) Start(ctx context.Context, host component.Host) (err error) {
 	se.host = host
 
+	if se.conf.Status.Enabled {
+		if err := se.startStatusServer(); err != nil {
+			return fmt.Errorf("cannot start status endpoint: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				se.statusServer.Close()
+			}
+		}()
+	}
+
 	colCreds, err := se.getCredentials(ctx)
 	if err != nil {
 		return err
@@ -165,20 +304,142 @@ func (se *SumologicExtension) Start(ctx context.Context, host component.Host) er
 		zap.String(collectorIdField, colCreds.Credentials.CollectorId),
 	)
 
+	se.updateCollectorMetadata(ctx, colCreds)
+
+	if se.conf.OpAMP.Enabled {
+		se.opamp = newOpAMPAgent(se)
+		if err := se.opamp.Start(ctx); err != nil {
+			return err
+		}
+	}
+
 	go se.heartbeatLoop()
 
+	if so := se.conf.SelfObservability; so.Metrics || so.Logs {
+		if so.Logs {
+			se.logBuffer = newLogBuffer(so.LogLevel)
+			registerLogBuffer(se.logBuffer)
+		}
+		se.selfObservabilityDone = make(chan struct{})
+		go func() {
+			defer close(se.selfObservabilityDone)
+			se.selfObservabilityLoop()
+		}()
+	}
+
 	return nil
 }
 
 // Shutdown is invoked during service shutdown.
 func (se *SumologicExtension) Shutdown(ctx context.Context) error {
 	se.closeOnce.Do(func() { close(se.closeChan) })
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
+
+	var errs error
+	if se.opamp != nil {
+		if err := se.opamp.Shutdown(ctx); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("unable to stop OpAMP client: %w", err))
+		}
+	}
+
+	if se.logBuffer != nil {
+		unregisterLogBuffer(se.logBuffer)
+	}
+	if se.selfObservabilityDone != nil {
+		// Wait for the remaining self observability data to be sent, but don't
+		// let it hold up the deregistration and the status endpoint shutdown.
+		timer := time.NewTimer(selfObservabilityFlushTimeout)
+		select {
+		case <-se.selfObservabilityDone:
+		case <-timer.C:
+			se.logger.Warn("Timed out sending the remaining self observability data")
+		case <-ctx.Done():
+		}
+		timer.Stop()
+	}
+
+	if se.conf.DeregisterOnShutdown && se.collectorHTTPClient() != nil {
+		se.deregisterCollector(ctx)
+	}
+
+	if se.statusServer != nil {
+		if err := se.statusServer.Shutdown(ctx); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("unable to stop status endpoint: %w", err))
+		}
+	}
+
+	return multierr.Append(errs, ctx.Err())
+}
+
+// deregisterCollector deregisters the collector and deletes its stored
+// credentials. Failures are only logged, the collector is then removed
+// after the ephemeral timeout.
+func (se *SumologicExtension) deregisterCollector(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, se.conf.DeregisterTimeout)
+	defer cancel()
+
+	backOff := backoff.NewExponentialBackOff()
+	backOff.InitialInterval = se.conf.BackOff.InitialInterval
+	backOff.MaxInterval = se.conf.BackOff.MaxInterval
+	backOff.MaxElapsedTime = se.conf.DeregisterTimeout
+
+	err := backoff.Retry(func() error {
+		return se.sendDeregistration(ctx)
+	}, backoff.WithContext(backOff, ctx))
+	if err != nil {
+		se.logger.Warn("Collector deregistration failed", zap.Error(err))
+		return
+	}
+	se.logger.Info("Collector deregistered")
+
+	if err := se.credentialsStore.Delete(se.hashKey); err != nil {
+		se.logger.Error("Unable to delete collector credentials", zap.Error(err))
+	}
+}
+
+func (se *SumologicExtension) sendDeregistration(ctx context.Context) error {
+	u, err := url.Parse(se.BaseUrl() + deregisterUrl)
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("unable to parse deregistration URL %w", err))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("unable to create HTTP request %w", err))
+	}
+
+	addJSONHeaders(req)
+	res, err := se.collectorHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return nil
+	case res.StatusCode == http.StatusNotFound:
+		// The collector doesn't exist anymore.
 		return nil
 	}
+
+	var buff bytes.Buffer
+	if _, err := io.Copy(&buff, res.Body); err != nil {
+		return fmt.Errorf(
+			"failed to copy collector deregistration response body, status code: %d, err: %w",
+			res.StatusCode, err,
+		)
+	}
+	err = fmt.Errorf("collector deregistration request failed: %w",
+		ErrorAPI{
+			status: res.StatusCode,
+			body:   buff.String(),
+		},
+	)
+
+	// Retrying doesn't help for 4xx status codes except 429.
+	if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+		return backoff.Permanent(err)
+	}
+	return err
 }
 
 func (se *SumologicExtension) validateCredentials(
@@ -194,7 +455,7 @@ func (se *SumologicExtension) validateCredentials(
 		return err
 	}
 
-	return se.sendHeartbeatWithHTTPClient(ctx, se.httpClient)
+	return se.sendHeartbeatWithHTTPClient(ctx, se.collectorHTTPClient())
 }
 
 // injectCredentials injects the collector credentials:
@@ -202,19 +463,85 @@ func (se *SumologicExtension) validateCredentials(
 //   - into http client and its transport so that each request is using collector
 //     credentials as authentication keys
 func (se *SumologicExtension) injectCredentials(colCreds credentials.CollectorCredentials) error {
-	// Set the registration info so that it can be used in RoundTripper.
-	se.registrationInfo = colCreds.Credentials
-
 	httpClient, err := se.getHTTPClient(se.conf.HTTPClientSettings, colCreds.Credentials)
 	if err != nil {
 		return err
 	}
 
-	se.httpClient = httpClient
+	se.setCredentials(colCreds, httpClient)
 
 	return nil
 }
 
+func (se *SumologicExtension) setCredentials(colCreds credentials.CollectorCredentials, httpClient *http.Client) {
+	issuedAt := colCreds.IssuedAt
+	if issuedAt.IsZero() {
+		// Credentials stored by older versions don't contain the time
+		// they were issued at, count the rotation interval from now.
+		issuedAt = time.Now()
+	}
+
+	se.credentialsLock.Lock()
+	// Set the registration info so that it can be used in RoundTripper.
+	se.registrationInfo = colCreds.Credentials
+	se.httpClient = httpClient
+	se.credentialsIssuedAt = issuedAt
+	se.credentialsLock.Unlock()
+
+	se.status.setCredentials(colCreds)
+}
+
+func (se *SumologicExtension) getRegistrationInfo() api.OpenRegisterResponsePayload {
+	se.credentialsLock.RLock()
+	defer se.credentialsLock.RUnlock()
+	return se.registrationInfo
+}
+
+// collectorHTTPClient returns the HTTP client authenticated with
+// the collector credentials.
+func (se *SumologicExtension) collectorHTTPClient() *http.Client {
+	se.credentialsLock.RLock()
+	defer se.credentialsLock.RUnlock()
+	return se.httpClient
+}
+
+func (se *SumologicExtension) getCredentialsIssuedAt() time.Time {
+	se.credentialsLock.RLock()
+	defer se.credentialsLock.RUnlock()
+	return se.credentialsIssuedAt
+}
+
+// OnCredentialsChange registers the listener to be called whenever
+// the collector credentials change, i.e. after they are rotated or
+// the collector is registered again, so that the components using them
+// can reconfigure. The returned function unregisters the listener.
+func (se *SumologicExtension) OnCredentialsChange(listener func()) (unregister func()) {
+	se.credentialsListenersLock.Lock()
+	defer se.credentialsListenersLock.Unlock()
+	id := se.credentialsListenerId
+	se.credentialsListenerId++
+	se.credentialsListeners[id] = listener
+
+	return func() {
+		se.credentialsListenersLock.Lock()
+		defer se.credentialsListenersLock.Unlock()
+		delete(se.credentialsListeners, id)
+	}
+}
+
+func (se *SumologicExtension) notifyCredentialsListeners() {
+	se.credentialsListenersLock.Lock()
+	listeners := make([]func(), 0, len(se.credentialsListeners))
+	for _, listener := range se.credentialsListeners {
+		listeners = append(listeners, listener)
+	}
+	se.credentialsListenersLock.Unlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
 func (se *SumologicExtension) getHTTPClient(
 	httpClientSettings confighttp.HTTPClientSettings,
 	regInfo api.OpenRegisterResponsePayload,
@@ -229,9 +556,10 @@ func (se *SumologicExtension) getHTTPClient(
 
 	// Set the transport so that all requests from httpClient will contain
 	// the collector credentials.
-	httpClient.Transport, err = se.RoundTripper(httpClient.Transport)
-	if err != nil {
-		return nil, fmt.Errorf("couldn't create HTTP client transport: %w", err)
+	httpClient.Transport = roundTripper{
+		collectorCredentialId:  regInfo.CollectorCredentialId,
+		collectorCredentialKey: regInfo.CollectorCredentialKey,
+		base:                   httpClient.Transport,
 	}
 
 	return httpClient, nil
@@ -340,8 +668,7 @@ func (se *SumologicExtension) registerCollector(ctx context.Context, collectorNa
 		return credentials.CollectorCredentials{}, fmt.Errorf("cannot get hostname: %w", err)
 	}
 
-	var buff bytes.Buffer
-	if err = json.NewEncoder(&buff).Encode(api.OpenRegisterRequestPayload{
+	payload := api.OpenRegisterRequestPayload{
 		CollectorName: collectorName,
 		Description:   se.conf.CollectorDescription,
 		Category:      se.conf.CollectorCategory,
@@ -350,7 +677,13 @@ func (se *SumologicExtension) registerCollector(ctx context.Context, collectorNa
 		Ephemeral:     se.conf.Ephemeral,
 		Clobber:       se.conf.Clobber,
 		TimeZone:      se.conf.TimeZone,
-	}); err != nil {
+	}
+	if se.conf.HostMetadata.Enabled {
+		payload.HostMetadata = se.discoverHostMetadata(ctx)
+	}
+
+	var buff bytes.Buffer
+	if err = json.NewEncoder(&buff).Encode(payload); err != nil {
 		return credentials.CollectorCredentials{}, err
 	}
 
@@ -359,8 +692,12 @@ func (se *SumologicExtension) registerCollector(ctx context.Context, collectorNa
 		return credentials.CollectorCredentials{}, err
 	}
 
+	accessCreds, err := se.getAccessCredentials()
+	if err != nil {
+		return credentials.CollectorCredentials{}, err
+	}
 	addClientCredentials(req,
-		se.conf.Credentials,
+		accessCreds,
 	)
 	addJSONHeaders(req)
 
@@ -395,13 +732,115 @@ func (se *SumologicExtension) registerCollector(ctx context.Context, collectorNa
 		return credentials.CollectorCredentials{}, err
 	}
 
+	metadata := se.collectorMetadata()
 	return credentials.CollectorCredentials{
 		CollectorName: collectorName,
 		Credentials:   resp,
 		ApiBaseUrl:    se.BaseUrl(),
+		Metadata:      &metadata,
+		IssuedAt:      time.Now(),
 	}, nil
 }
 
+// collectorMetadata returns the collector metadata from the configuration.
+func (se *SumologicExtension) collectorMetadata() api.OpenCollectorUpdateRequestPayload {
+	return api.OpenCollectorUpdateRequestPayload{
+		Description: se.conf.CollectorDescription,
+		Category:    se.conf.CollectorCategory,
+		TimeZone:    se.conf.TimeZone,
+		Fields:      se.conf.CollectorFields,
+	}
+}
+
+// updateCollectorMetadata sends the collector metadata to the API if it has
+// changed in the configuration since it was last sent, so that the collector
+// doesn't need to be re-registered.
+// Credentials stored by older versions don't contain the metadata, in which
+// case the current metadata is only stored to be compared against on
+// subsequent starts.
+// Failures are only logged as the collector can work with outdated metadata.
+func (se *SumologicExtension) updateCollectorMetadata(ctx context.Context, colCreds credentials.CollectorCredentials) {
+	metadata := se.collectorMetadata()
+	if colCreds.Metadata != nil {
+		changed, err := metadataChanged(*colCreds.Metadata, metadata)
+		if err != nil {
+			se.logger.Warn("Unable to compare collector metadata", zap.Error(err))
+			return
+		}
+		if !changed {
+			return
+		}
+
+		se.logger.Info("Collector metadata changed in the configuration, updating")
+		if err := se.sendCollectorUpdate(ctx, metadata); err != nil {
+			se.logger.Warn("Unable to update collector metadata", zap.Error(err))
+			return
+		}
+	}
+
+	colCreds.Metadata = &metadata
+	if err := se.credentialsStore.Store(se.hashKey, colCreds); err != nil {
+		se.logger.Error("Unable to store updated collector metadata", zap.Error(err))
+	}
+}
+
+// metadataChanged compares the metadata using their JSON representation
+// because the stored fields are decoded with different types than the ones
+// coming from the configuration, e.g. numbers.
+func metadataChanged(stored api.OpenCollectorUpdateRequestPayload, current api.OpenCollectorUpdateRequestPayload) (bool, error) {
+	storedJSON, err := json.Marshal(stored)
+	if err != nil {
+		return false, err
+	}
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(storedJSON, currentJSON), nil
+}
+
+func (se *SumologicExtension) sendCollectorUpdate(ctx context.Context, metadata api.OpenCollectorUpdateRequestPayload) error {
+	u, err := url.Parse(se.BaseUrl() + collectorUpdateUrl)
+	if err != nil {
+		return fmt.Errorf("unable to parse collector update URL %w", err)
+	}
+
+	var buff bytes.Buffer
+	if err = json.NewEncoder(&buff).Encode(metadata); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &buff)
+	if err != nil {
+		return fmt.Errorf("unable to create HTTP request %w", err)
+	}
+
+	addJSONHeaders(req)
+	res, err := se.collectorHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var body bytes.Buffer
+		if _, err := io.Copy(&body, res.Body); err != nil {
+			return fmt.Errorf(
+				"failed to copy collector update response body, status code: %d, err: %w",
+				res.StatusCode, err,
+			)
+		}
+		return fmt.Errorf("collector update request failed: %w",
+			ErrorAPI{
+				status: res.StatusCode,
+				body:   body.String(),
+			},
+		)
+	}
+
+	return nil
+}
+
 // handleRegistrationError handles the collector registration errors and returns
 // appropriate error for backoff handling and logging purposes.
 func (se *SumologicExtension) handleRegistrationError(res *http.Response) (credentials.CollectorCredentials, error) {
@@ -445,6 +884,7 @@ func (se *SumologicExtension) registerCollectorWithBackoff(ctx context.Context,
 	se.backOff.Reset()
 	for {
 		creds, err := se.registerCollector(ctx, collectorName)
+		se.status.setRegistration(err)
 		if err == nil {
 			se.logger = se.origLogger.With(
 				zap.String(collectorNameField, creds.Credentials.CollectorName),
@@ -473,7 +913,7 @@ func (se *SumologicExtension) registerCollectorWithBackoff(ctx context.Context,
 }
 
 func (se *SumologicExtension) heartbeatLoop() {
-	if se.registrationInfo.CollectorCredentialId == "" || se.registrationInfo.CollectorCredentialKey == "" {
+	if regInfo := se.getRegistrationInfo(); regInfo.CollectorCredentialId == "" || regInfo.CollectorCredentialKey == "" {
 		se.logger.Error("Collector not registered, cannot send heartbeat")
 		return
 	}
@@ -487,54 +927,238 @@ func (se *SumologicExtension) heartbeatLoop() {
 		cancel()
 	}()
 
+	// The backoff is reset after every successful heartbeat, so that it
+	// yields the jittered heartbeat interval until heartbeats start failing.
+	backOff := backoff.NewExponentialBackOff()
+	backOff.InitialInterval = se.conf.HeartBeatInterval
+	backOff.RandomizationFactor = se.conf.HeartBeatJitter
+	backOff.MaxInterval = se.conf.BackOff.MaxInterval
+	if backOff.MaxInterval < se.conf.HeartBeatInterval {
+		backOff.MaxInterval = se.conf.HeartBeatInterval
+	}
+	backOff.MaxElapsedTime = 0
+	backOff.Reset()
+
+	// Credentials are rotated from the heartbeat loop, so that heartbeats
+	// are not sent while the credentials are being verified and swapped.
+	var (
+		rotationTimer   *time.Timer
+		rotationC       <-chan time.Time
+		rotationBackOff *backoff.ExponentialBackOff
+	)
+	if se.conf.CredentialsRotationInterval > 0 {
+		rotationTimer = time.NewTimer(time.Until(se.getCredentialsIssuedAt().Add(se.conf.CredentialsRotationInterval)))
+		defer rotationTimer.Stop()
+		rotationC = rotationTimer.C
+
+		rotationBackOff = backoff.NewExponentialBackOff()
+		rotationBackOff.InitialInterval = se.conf.BackOff.InitialInterval
+		rotationBackOff.MaxInterval = se.conf.BackOff.MaxInterval
+		rotationBackOff.MaxElapsedTime = 0
+		rotationBackOff.Reset()
+	}
+
 	se.logger.Info("Heartbeat loop initialized. Starting to send hearbeat requests")
-	timer := time.NewTimer(se.conf.HeartBeatInterval)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 	for {
 		select {
 		case <-se.closeChan:
 			se.logger.Info("Heartbeat sender turned off")
 			return
 
-		default:
-			err := se.sendHeartbeatWithHTTPClient(ctx, se.httpClient)
+		case <-rotationC:
+			// The credentials might have been replaced since the timer was set,
+			// e.g. when the collector registered again.
+			if next := time.Until(se.getCredentialsIssuedAt().Add(se.conf.CredentialsRotationInterval)); next > 0 {
+				rotationTimer.Reset(next)
+				continue
+			}
+			if err := se.rotateCredentials(ctx); err != nil {
+				se.logger.Error("Collector credentials rotation failed, keeping the current credentials", zap.Error(err))
+				rotationTimer.Reset(rotationBackOff.NextBackOff())
+				continue
+			}
+			rotationBackOff.Reset()
+			rotationTimer.Reset(se.conf.CredentialsRotationInterval)
 
-			if err != nil {
-				if errors.Is(err, errUnauthorizedHeartbeat) {
-					se.logger.Warn("Heartbeat request unauthorized, re-registering the collector")
-					colCreds, err := se.getCredentialsByRegistering(ctx)
-					if err != nil {
-						se.logger.Error("Heartbeat error, cannot register the collector", zap.Error(err))
-						continue
-					}
-
-					// Inject newly received credentials into extension's configuration.
-					if err = se.injectCredentials(colCreds); err != nil {
-						se.logger.Error("Heartbeat error, cannot inject new collector credentials", zap.Error(err))
-						continue
-					}
-
-					// Overwrite old logger fields with new collector name and ID.
-					se.logger = se.origLogger.With(
-						zap.String(collectorNameField, colCreds.Credentials.CollectorName),
-						zap.String(collectorIdField, colCreds.Credentials.CollectorId),
-					)
-
-				} else {
-					se.logger.Error("Heartbeat error", zap.Error(err))
-				}
+		case <-timer.C:
+			if err := se.heartbeat(ctx); err != nil {
+				se.logger.Error("Heartbeat error", zap.Error(err))
+				se.setHeartbeatFailed(err)
 			} else {
 				se.logger.Debug("Heartbeat sent")
+				se.setHeartbeatSucceeded()
+				backOff.Reset()
 			}
-
-			select {
-			case <-timer.C:
-				timer.Stop()
-				timer.Reset(se.conf.HeartBeatInterval)
-			case <-se.closeChan:
+			if err := observability.RecordHealth(se.ComponentID().String(), se.Health() == nil); err != nil {
+				se.logger.Debug("Unable to record health metrics", zap.Error(err))
 			}
+			timer.Reset(backOff.NextBackOff())
+		}
+	}
+}
 
+// heartbeat sends a heartbeat and records its outcome. When the heartbeat is
+// unauthorized, the collector is registered again.
+func (se *SumologicExtension) heartbeat(ctx context.Context) error {
+	start := time.Now()
+	err := se.sendHeartbeatWithHTTPClient(ctx, se.collectorHTTPClient())
+	if err == nil {
+		if err := observability.RecordHeartbeatSuccess(se.ComponentID().String(), time.Since(start)); err != nil {
+			se.logger.Debug("Unable to record heartbeat metrics", zap.Error(err))
 		}
+		return nil
 	}
+	if err := observability.RecordHeartbeatFailure(se.ComponentID().String(), time.Since(start)); err != nil {
+		se.logger.Debug("Unable to record heartbeat metrics", zap.Error(err))
+	}
+
+	if !errors.Is(err, errUnauthorizedHeartbeat) {
+		return err
+	}
+
+	se.logger.Warn("Heartbeat request unauthorized, re-registering the collector")
+	colCreds, err := se.getCredentialsByRegistering(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot register the collector: %w", err)
+	}
+
+	// Inject newly received credentials into extension's configuration.
+	if err = se.injectCredentials(colCreds); err != nil {
+		return fmt.Errorf("cannot inject new collector credentials: %w", err)
+	}
+
+	// Overwrite old logger fields with new collector name and ID.
+	se.logger = se.origLogger.With(
+		zap.String(collectorNameField, colCreds.Credentials.CollectorName),
+		zap.String(collectorIdField, colCreds.Credentials.CollectorId),
+	)
+	se.notifyCredentialsListeners()
+	return nil
+}
+
+// rotateCredentials obtains new collector credentials from the API and
+// switches to them once a heartbeat sent with them succeeds. The stored
+// credentials are replaced only then, so that the current ones are kept
+// when anything fails.
+func (se *SumologicExtension) rotateCredentials(ctx context.Context) error {
+	se.logger.Info("Rotating collector credentials")
+
+	regInfo, err := se.sendCredentialsRotation(ctx)
+	if err != nil {
+		return err
+	}
+
+	httpClient, err := se.getHTTPClient(se.conf.HTTPClientSettings, regInfo)
+	if err != nil {
+		return err
+	}
+	if err := se.sendHeartbeatWithHTTPClient(ctx, httpClient); err != nil {
+		return fmt.Errorf("new collector credentials cannot be used: %w", err)
+	}
+
+	colCreds, err := se.credentialsStore.Get(se.hashKey)
+	if err != nil {
+		colCreds = credentials.CollectorCredentials{
+			CollectorName: se.collectorName,
+			ApiBaseUrl:    se.BaseUrl(),
+		}
+	}
+	colCreds.Credentials = regInfo
+	colCreds.IssuedAt = time.Now()
+	if err := se.credentialsStore.Store(se.hashKey, colCreds); err != nil {
+		se.logger.Error(
+			"Unable to store rotated collector credentials, they will be used now but won't be re-used on next run",
+			zap.Error(err),
+		)
+	}
+
+	se.setCredentials(colCreds, httpClient)
+	se.notifyCredentialsListeners()
+
+	se.logger.Info("Collector credentials rotated",
+		zap.String(collectorCredentialIdField, regInfo.CollectorCredentialId),
+	)
+	return nil
+}
+
+func (se *SumologicExtension) sendCredentialsRotation(ctx context.Context) (api.OpenRegisterResponsePayload, error) {
+	u, err := url.Parse(se.BaseUrl() + rotateUrl)
+	if err != nil {
+		return api.OpenRegisterResponsePayload{}, fmt.Errorf("unable to parse credentials rotation URL %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return api.OpenRegisterResponsePayload{}, fmt.Errorf("unable to create HTTP request %w", err)
+	}
+
+	addJSONHeaders(req)
+	res, err := se.collectorHTTPClient().Do(req)
+	if err != nil {
+		return api.OpenRegisterResponsePayload{}, fmt.Errorf("unable to send HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var body bytes.Buffer
+		if _, err := io.Copy(&body, res.Body); err != nil {
+			return api.OpenRegisterResponsePayload{}, fmt.Errorf(
+				"failed to copy credentials rotation response body, status code: %d, err: %w",
+				res.StatusCode, err,
+			)
+		}
+		return api.OpenRegisterResponsePayload{}, fmt.Errorf("credentials rotation request failed: %w",
+			ErrorAPI{
+				status: res.StatusCode,
+				body:   body.String(),
+			},
+		)
+	}
+
+	var regInfo api.OpenRegisterResponsePayload
+	if err := json.NewDecoder(res.Body).Decode(&regInfo); err != nil {
+		return api.OpenRegisterResponsePayload{}, fmt.Errorf("failed to decode credentials rotation response: %w", err)
+	}
+	if regInfo.CollectorCredentialId == "" || regInfo.CollectorCredentialKey == "" {
+		return api.OpenRegisterResponsePayload{}, errors.New("credentials rotation response doesn't contain the credentials")
+	}
+	if regInfo.CollectorId == "" {
+		regInfo.CollectorId = se.CollectorID()
+	}
+	return regInfo, nil
+}
+
+func (se *SumologicExtension) setHeartbeatFailed(err error) {
+	se.healthLock.Lock()
+	defer se.healthLock.Unlock()
+	if se.heartbeatFailingSince.IsZero() {
+		se.heartbeatFailingSince = time.Now()
+	}
+	se.heartbeatErr = err
+	se.status.setHeartbeat(err)
+}
+
+func (se *SumologicExtension) setHeartbeatSucceeded() {
+	se.healthLock.Lock()
+	defer se.healthLock.Unlock()
+	se.heartbeatFailingSince = time.Time{}
+	se.heartbeatErr = nil
+	se.status.setHeartbeat(nil)
+}
+
+// Health returns an error when heartbeats have been failing for longer
+// than heartbeat_unhealthy_after, nil otherwise.
+func (se *SumologicExtension) Health() error {
+	se.healthLock.RLock()
+	defer se.healthLock.RUnlock()
+	if se.heartbeatFailingSince.IsZero() || time.Since(se.heartbeatFailingSince) < se.conf.HeartBeatUnhealthyAfter {
+		return nil
+	}
+	return fmt.Errorf("heartbeats failing since %s: %w",
+		se.heartbeatFailingSince.Format(time.RFC3339), se.heartbeatErr,
+	)
 }
 
 var errUnauthorizedHeartbeat = errors.New("heartbeat unauthorized")
@@ -591,12 +1215,25 @@ func (se *SumologicExtension) sendHeartbeatWithHTTPClient(ctx context.Context, h
 	return nil
 }
 
+// Ready is invoked when the collector pipelines are started.
+func (se *SumologicExtension) Ready() error {
+	if se.opamp != nil {
+		se.opamp.Ready(context.Background())
+	}
+	return nil
+}
+
+// NotReady is invoked before the collector pipelines are shut down.
+func (se *SumologicExtension) NotReady() error {
+	return nil
+}
+
 func (se *SumologicExtension) ComponentID() config.ComponentID {
 	return se.conf.ExtensionSettings.ID()
 }
 
 func (se *SumologicExtension) CollectorID() string {
-	return se.registrationInfo.CollectorId
+	return se.getRegistrationInfo().CollectorId
 }
 
 func (se *SumologicExtension) BaseUrl() string {
@@ -616,15 +1253,40 @@ func (se *SumologicExtension) SetBaseUrl(baseUrl string) {
 //
 // [1]: https://github.com/open-telemetry/opentelemetry-collector/blob/2e84285efc665798d76773b9901727e8836e9d8f/config/configauth/clientauth.go#L34-L39
 func (se *SumologicExtension) RoundTripper(base http.RoundTripper) (http.RoundTripper, error) {
+	regInfo := se.getRegistrationInfo()
 	return roundTripper{
-		collectorCredentialId:  se.registrationInfo.CollectorCredentialId,
-		collectorCredentialKey: se.registrationInfo.CollectorCredentialKey,
+		collectorCredentialId:  regInfo.CollectorCredentialId,
+		collectorCredentialKey: regInfo.CollectorCredentialKey,
 		base:                   base,
 	}, nil
 }
 
 func (se *SumologicExtension) PerRPCCredentials() (grpccredentials.PerRPCCredentials, error) {
-	return nil, errGRPCNotSupported
+	return perRPCCredentials{se: se}, nil
+}
+
+// perRPCCredentials adds the collector credentials to gRPC requests.
+// The credentials are read for every request, so that rotated credentials
+// are used without reconnecting.
+type perRPCCredentials struct {
+	se *SumologicExtension
+}
+
+func (c perRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	regInfo := c.se.getRegistrationInfo()
+	if regInfo.CollectorCredentialId == "" || regInfo.CollectorCredentialKey == "" {
+		return nil, errors.New("collector not registered, no credentials available")
+	}
+	token := base64.StdEncoding.EncodeToString(
+		[]byte(regInfo.CollectorCredentialId + ":" + regInfo.CollectorCredentialKey),
+	)
+	return map[string]string{"authorization": "Basic " + token}, nil
+}
+
+// RequireTransportSecurity returns false, the same as for HTTP requests
+// the transport security follows the API base URL scheme.
+func (c perRPCCredentials) RequireTransportSecurity() bool {
+	return false
 }
 
 type roundTripper struct {
@@ -21,6 +21,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -75,6 +76,38 @@ func TestBasicExtensionConstruction(t *testing.T) {
 				return cfg
 			}(),
 		},
+		{
+			Name: "memory_credentials_store",
+			Config: func() *Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.CollectorName = "collector_name"
+				cfg.Credentials.InstallToken = "install_token_123456"
+				cfg.CredentialsStore.Type = credentialsStoreMemory
+				return cfg
+			}(),
+		},
+		{
+			Name: "unknown_credentials_store_causes_error",
+			Config: func() *Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.CollectorName = "collector_name"
+				cfg.Credentials.InstallToken = "install_token_123456"
+				cfg.CredentialsStore.Type = "unknown"
+				return cfg
+			}(),
+			WantErr: true,
+		},
+		{
+			Name: "empty_key_material_causes_error",
+			Config: func() *Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.CollectorName = "collector_name"
+				cfg.Credentials.InstallToken = "install_token_123456"
+				cfg.CredentialsEncryption.KeyEnv = "SUMOLOGIC_TEST_NOT_EXISTING_KEY_MATERIAL"
+				return cfg
+			}(),
+			WantErr: true,
+		},
 	}
 
 	for _, tc := range testcases {
@@ -1272,3 +1305,535 @@ func TestRegistrationRequestPayload(t *testing.T) {
 
 	require.NoError(t, se.Shutdown(context.Background()))
 }
+
+func TestCollectorMetadataUpdate(t *testing.T) {
+	t.Parallel()
+
+	var (
+		registerCount int32
+		updates       = make(chan api.OpenCollectorUpdateRequestPayload, 10)
+		failUpdate    int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case registerUrl:
+			atomic.AddInt32(&registerCount, 1)
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+
+		case heartbeatUrl:
+			w.WriteHeader(http.StatusNoContent)
+
+		case collectorUpdateUrl:
+			assert.Equal(t, http.MethodPost, req.Method)
+			assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("collectorId:collectorKey")),
+				req.Header.Get("Authorization"))
+
+			var payload api.OpenCollectorUpdateRequestPayload
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			updates <- payload
+
+			if atomic.LoadInt32(&failUpdate) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	dir := t.TempDir()
+	getConfig := func(category string) *Config {
+		cfg := createDefaultConfig().(*Config)
+		cfg.CollectorName = "collector_name"
+		cfg.ExtensionSettings = config.ExtensionSettings{}
+		cfg.ApiBaseUrl = srv.URL
+		cfg.Credentials.InstallToken = "dummy_install_token"
+		cfg.CollectorCredentialsDirectory = dir
+		cfg.CollectorDescription = "my description"
+		cfg.CollectorCategory = category
+		cfg.CollectorFields = map[string]interface{}{
+			"field1": "value1",
+			// Numbers are decoded as float64 from the stored credentials,
+			// which shouldn't be reported as a change.
+			"field2": 2,
+		}
+		return cfg
+	}
+	start := func(cfg *Config) {
+		se, err := newSumologicExtension(cfg, zap.NewNop())
+		require.NoError(t, err)
+		require.NoError(t, se.Start(context.Background(), componenttest.NewNopHost()))
+		require.NoError(t, se.Shutdown(context.Background()))
+	}
+
+	// Registration stores the metadata, unchanged metadata is not sent.
+	start(getConfig("category"))
+	start(getConfig("category"))
+	require.EqualValues(t, 1, atomic.LoadInt32(&registerCount))
+	require.Len(t, updates, 0)
+
+	// Failed update is retried on next start.
+	atomic.StoreInt32(&failUpdate, 1)
+	start(getConfig("new category"))
+	require.Len(t, updates, 1)
+	<-updates
+
+	atomic.StoreInt32(&failUpdate, 0)
+	start(getConfig("new category"))
+	require.Len(t, updates, 1)
+	payload := <-updates
+	assert.Equal(t, api.OpenCollectorUpdateRequestPayload{
+		Description: "my description",
+		Category:    "new category",
+		Fields: map[string]interface{}{
+			"field1": "value1",
+			"field2": float64(2),
+		},
+	}, payload)
+
+	// The updated metadata is stored so it's not sent again.
+	start(getConfig("new category"))
+	require.Len(t, updates, 0)
+	require.EqualValues(t, 1, atomic.LoadInt32(&registerCount))
+}
+
+func TestDeregisterOnShutdown(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name                string
+		deregisterStatuses  []int
+		expectedDeregisters int32
+		expectedCredsStored bool
+	}{
+		{
+			name:                "deregistered after retry",
+			deregisterStatuses:  []int{http.StatusServiceUnavailable, http.StatusNoContent},
+			expectedDeregisters: 2,
+			expectedCredsStored: false,
+		},
+		{
+			name:                "collector already removed",
+			deregisterStatuses:  []int{http.StatusNotFound},
+			expectedDeregisters: 1,
+			expectedCredsStored: false,
+		},
+		{
+			name:                "not retried on client error",
+			deregisterStatuses:  []int{http.StatusForbidden},
+			expectedDeregisters: 1,
+			expectedCredsStored: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var deregisterCount int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case registerUrl:
+					_, err := w.Write([]byte(`{
+						"collectorCredentialId": "collectorId",
+						"collectorCredentialKey": "collectorKey",
+						"collectorId": "id"
+					}`))
+					require.NoError(t, err)
+
+				case heartbeatUrl:
+					w.WriteHeader(http.StatusNoContent)
+
+				case deregisterUrl:
+					assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("collectorId:collectorKey")),
+						req.Header.Get("Authorization"))
+					n := atomic.AddInt32(&deregisterCount, 1)
+					w.WriteHeader(tc.deregisterStatuses[n-1])
+
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(func() { srv.Close() })
+
+			cfg := createDefaultConfig().(*Config)
+			cfg.CollectorName = "collector_name"
+			cfg.ExtensionSettings = config.ExtensionSettings{}
+			cfg.ApiBaseUrl = srv.URL
+			cfg.Credentials.InstallToken = "dummy_install_token"
+			cfg.CollectorCredentialsDirectory = t.TempDir()
+			cfg.Ephemeral = true
+			cfg.DeregisterOnShutdown = true
+			cfg.BackOff.InitialInterval = time.Millisecond
+
+			se, err := newSumologicExtension(cfg, zap.NewNop())
+			require.NoError(t, err)
+			require.NoError(t, se.Start(context.Background(), componenttest.NewNopHost()))
+			require.True(t, se.credentialsStore.Check(se.hashKey))
+
+			require.NoError(t, se.Shutdown(context.Background()))
+			assert.Equal(t, tc.expectedDeregisters, atomic.LoadInt32(&deregisterCount))
+			assert.Equal(t, tc.expectedCredsStored, se.credentialsStore.Check(se.hashKey))
+		})
+	}
+}
+
+func TestDeregisterOnShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	var deregisterCount int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case registerUrl:
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+
+		case heartbeatUrl:
+			w.WriteHeader(http.StatusNoContent)
+
+		case deregisterUrl:
+			atomic.AddInt32(&deregisterCount, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+	cfg.DeregisterOnShutdown = true
+	cfg.DeregisterTimeout = 200 * time.Millisecond
+	cfg.BackOff.InitialInterval = 10 * time.Millisecond
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	require.NoError(t, se.Shutdown(context.Background()))
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Greater(t, atomic.LoadInt32(&deregisterCount), int32(1))
+
+	// Credentials are kept, so that the collector can be reused on restart.
+	assert.True(t, se.credentialsStore.Check(se.hashKey))
+}
+
+func TestShutdownSelfObservabilityTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case registerUrl:
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+
+		case heartbeatUrl:
+			w.WriteHeader(http.StatusNoContent)
+
+		case selfObservabilityMetricsUrl:
+			// The remaining data can't be sent on shutdown.
+			select {
+			case <-release:
+			case <-req.Context().Done():
+			}
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+	t.Cleanup(func() { close(release) })
+
+	// Reserve a port for the status endpoint.
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	statusEndpoint := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+	cfg.SelfObservability = selfObservabilityConfig{
+		Metrics:  true,
+		Interval: time.Hour,
+	}
+	cfg.Status = statusConfig{
+		Enabled:  true,
+		Endpoint: statusEndpoint,
+	}
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The expired context is reported, but the shutdown isn't cut short.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = se.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	_, err = net.Dial("tcp", statusEndpoint)
+	assert.Error(t, err, "status endpoint should be stopped")
+}
+
+func TestHeartbeatBackOffAndHealth(t *testing.T) {
+	t.Parallel()
+
+	var (
+		failHeartbeat int32 = 1
+		heartbeats          = make(chan time.Time, 100)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case registerUrl:
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+
+		case heartbeatUrl:
+			heartbeats <- time.Now()
+			if atomic.LoadInt32(&failHeartbeat) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+	cfg.HeartBeatInterval = 20 * time.Millisecond
+	cfg.HeartBeatJitter = 0
+	cfg.HeartBeatUnhealthyAfter = 100 * time.Millisecond
+	cfg.BackOff.MaxInterval = time.Second
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+	assert.NoError(t, se.Health())
+
+	// Intervals between failed heartbeats grow exponentially.
+	var times []time.Time
+	for i := 0; i < 5; i++ {
+		times = append(times, <-heartbeats)
+	}
+	assert.Greater(t, times[4].Sub(times[3]), times[1].Sub(times[0]))
+
+	assert.Eventually(t, func() bool {
+		return se.Health() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ErrorContains(t, se.Health(), "heartbeats failing since")
+
+	atomic.StoreInt32(&failHeartbeat, 0)
+	assert.Eventually(t, func() bool {
+		return se.Health() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInstallTokenFileRotation(t *testing.T) {
+	t.Parallel()
+
+	var (
+		registerTokens   = make(chan string, 10)
+		heartbeatFailing int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case registerUrl:
+			select {
+			case registerTokens <- req.Header.Get("Authorization"):
+			default:
+			}
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+
+		case heartbeatUrl:
+			if atomic.LoadInt32(&heartbeatFailing) == 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	tokenFile := path.Join(t.TempDir(), "install_token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("first_token\n"), 0600))
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallTokenFile = tokenFile
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+	cfg.HeartBeatInterval = 50 * time.Millisecond
+	require.NoError(t, cfg.Validate())
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, se.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+	assert.Equal(t, "Bearer first_token", <-registerTokens)
+
+	// The token is rotated and the collector removed, so the heartbeat
+	// is unauthorized and the collector registers with the new token.
+	require.NoError(t, os.WriteFile(tokenFile, []byte("second_token\n"), 0600))
+	atomic.StoreInt32(&heartbeatFailing, 1)
+	assert.Equal(t, "Bearer second_token", <-registerTokens)
+	atomic.StoreInt32(&heartbeatFailing, 0)
+
+	// The stored credentials are found using the file path,
+	// regardless of the token in the file.
+	assert.True(t, se.credentialsStore.Check(createHashKey(cfg)))
+}
+
+func TestCredentialsRotation(t *testing.T) {
+	t.Parallel()
+
+	var (
+		rejectRotated int32 = 1
+		rotations     int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, _, ok := req.BasicAuth()
+		switch req.URL.Path {
+		case registerUrl:
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+
+		case rotateUrl:
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			atomic.AddInt32(&rotations, 1)
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "rotatedId",
+				"collectorCredentialKey": "rotatedKey"
+			}`))
+			require.NoError(t, err)
+
+		case heartbeatUrl:
+			// The new credentials don't work until they're propagated.
+			if id == "rotatedId" && atomic.LoadInt32(&rejectRotated) == 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+	cfg.CredentialsRotationInterval = 100 * time.Millisecond
+	cfg.BackOff.InitialInterval = 10 * time.Millisecond
+	cfg.BackOff.MaxInterval = 50 * time.Millisecond
+	require.NoError(t, cfg.Validate())
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	var changes int32
+	unregister := se.OnCredentialsChange(func() { atomic.AddInt32(&changes, 1) })
+	t.Cleanup(unregister)
+
+	require.NoError(t, se.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+
+	// The current credentials are kept while the new ones don't work.
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&rotations) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "collectorId", se.getRegistrationInfo().CollectorCredentialId)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&changes))
+	stored, err := se.credentialsStore.Get(se.hashKey)
+	require.NoError(t, err)
+	assert.Equal(t, "collectorId", stored.Credentials.CollectorCredentialId)
+
+	// Once they work, they replace the current ones and the listeners are notified.
+	atomic.StoreInt32(&rejectRotated, 0)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&changes) >= 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	regInfo := se.getRegistrationInfo()
+	assert.Equal(t, "rotatedId", regInfo.CollectorCredentialId)
+	assert.Equal(t, "rotatedKey", regInfo.CollectorCredentialKey)
+	assert.Equal(t, "id", regInfo.CollectorId)
+
+	stored, err = se.credentialsStore.Get(se.hashKey)
+	require.NoError(t, err)
+	assert.Equal(t, "rotatedId", stored.Credentials.CollectorCredentialId)
+	assert.False(t, stored.IssuedAt.IsZero())
+
+	rt, err := se.RoundTripper(http.DefaultTransport)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, srv.URL+heartbeatUrl, nil)
+	req.RequestURI = ""
+	res, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	id, _, _ := req.BasicAuth()
+	assert.Equal(t, "rotatedId", id)
+}
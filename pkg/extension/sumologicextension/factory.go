@@ -20,6 +20,7 @@ import (
 	"github.com/cenkalti/backoff/v4"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/credentials"
 )
@@ -49,20 +50,48 @@ func createDefaultConfig() config.Extension {
 		ExtensionSettings:             config.NewExtensionSettings(config.NewComponentID(typeStr)),
 		ApiBaseUrl:                    DefaultApiBaseUrl,
 		HeartBeatInterval:             DefaultHeartbeatInterval,
+		HeartBeatJitter:               DefaultHeartbeatJitter,
+		HeartBeatUnhealthyAfter:       DefaultHeartbeatUnhealthyAfter,
 		CollectorCredentialsDirectory: defaultCredsPath,
-		Clobber:                       false,
-		ForceRegistration:             false,
-		Ephemeral:                     false,
-		TimeZone:                      "",
+		CredentialsStore: credentialsStoreConfig{
+			Type: credentialsStoreLocalFs,
+			KubernetesSecret: kubernetesSecretConfig{
+				Name: credentials.DefaultKubernetesSecretName,
+			},
+		},
+		Clobber:           false,
+		ForceRegistration: false,
+		Ephemeral:         false,
+		DeregisterTimeout: DefaultDeregisterTimeout,
+		TimeZone:          "",
 		BackOff: backOffConfig{
 			InitialInterval: backoff.DefaultInitialInterval,
 			MaxInterval:     backoff.DefaultMaxInterval,
 			MaxElapsedTime:  backoff.DefaultMaxElapsedTime,
 		},
+		SelfObservability: selfObservabilityConfig{
+			LogLevel: zapcore.InfoLevel,
+			Interval: DefaultSelfObservabilityInterval,
+		},
+		HostMetadata: hostMetadataConfig{
+			CloudMetadataEndpoint: DefaultCloudMetadataEndpoint,
+			CloudMetadataTimeout:  DefaultCloudMetadataTimeout,
+		},
+		Status: statusConfig{
+			Endpoint: DefaultStatusEndpoint,
+		},
+		OpAMP: opampConfig{
+			ReloadTimeout: DefaultOpAMPReloadTimeout,
+		},
 	}
 }
 
 func createExtension(_ context.Context, params component.ExtensionCreateSettings, cfg config.Extension) (component.Extension, error) {
 	config := cfg.(*Config)
-	return newSumologicExtension(config, params.Logger)
+	se, err := newSumologicExtension(config, params.Logger)
+	if err != nil {
+		return nil, err
+	}
+	se.buildInfo = params.BuildInfo
+	return se, nil
 }
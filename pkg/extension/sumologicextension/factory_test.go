@@ -26,6 +26,7 @@ import (
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/config"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/credentials"
 )
@@ -38,13 +39,36 @@ func TestFactory_CreateDefaultConfig(t *testing.T) {
 	assert.Equal(t, &Config{
 		ExtensionSettings:             config.NewExtensionSettings(config.NewComponentID(typeStr)),
 		HeartBeatInterval:             DefaultHeartbeatInterval,
+		HeartBeatJitter:               DefaultHeartbeatJitter,
+		HeartBeatUnhealthyAfter:       DefaultHeartbeatUnhealthyAfter,
 		ApiBaseUrl:                    DefaultApiBaseUrl,
 		CollectorCredentialsDirectory: defaultCredsPath,
+		CredentialsStore: credentialsStoreConfig{
+			Type: credentialsStoreLocalFs,
+			KubernetesSecret: kubernetesSecretConfig{
+				Name: credentials.DefaultKubernetesSecretName,
+			},
+		},
+		DeregisterTimeout: DefaultDeregisterTimeout,
 		BackOff: backOffConfig{
 			InitialInterval: backoff.DefaultInitialInterval,
 			MaxInterval:     backoff.DefaultMaxInterval,
 			MaxElapsedTime:  backoff.DefaultMaxElapsedTime,
 		},
+		SelfObservability: selfObservabilityConfig{
+			LogLevel: zapcore.InfoLevel,
+			Interval: DefaultSelfObservabilityInterval,
+		},
+		HostMetadata: hostMetadataConfig{
+			CloudMetadataEndpoint: DefaultCloudMetadataEndpoint,
+			CloudMetadataTimeout:  DefaultCloudMetadataTimeout,
+		},
+		Status: statusConfig{
+			Endpoint: DefaultStatusEndpoint,
+		},
+		OpAMP: opampConfig{
+			ReloadTimeout: DefaultOpAMPReloadTimeout,
+		},
 	}, cfg)
 
 	assert.NoError(t, cfg.Validate())
@@ -77,3 +101,30 @@ func TestFactory_CreateExtension(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, ext)
 }
+
+func TestConfigValidateCredentialsEncryption(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.CredentialsEncryption.KeyFile = "/etc/sumologic/key"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.CredentialsEncryption.MachineID = true
+	assert.Error(t, cfg.Validate())
+}
+
+func TestConfigValidateInstallToken(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Credentials.InstallTokenFile = "/etc/sumologic/install_token"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	assert.Error(t, cfg.Validate())
+}
+
+func TestConfigValidateStatus(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Status.Enabled = true
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Status.Endpoint = ""
+	assert.Error(t, cfg.Validate())
+}
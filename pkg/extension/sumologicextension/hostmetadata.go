@@ -0,0 +1,322 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicextension
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/api"
+)
+
+const (
+	DefaultCloudMetadataEndpoint = "http://169.254.169.254"
+	DefaultCloudMetadataTimeout  = time.Second
+
+	// Environment variables with the Kubernetes identity of the collector,
+	// typically set using the Downward API.
+	kubernetesServiceHostEnv = "KUBERNETES_SERVICE_HOST"
+	kubernetesClusterNameEnv = "CLUSTER_NAME"
+	kubernetesNamespaceEnv   = "POD_NAMESPACE"
+	kubernetesPodNameEnv     = "POD_NAME"
+	kubernetesNodeNameEnv    = "NODE_NAME"
+
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// discoverHostMetadata gathers the metadata describing the host and
+// the collector which is sent upon registration.
+// Discovery is best effort, the information which cannot be obtained is skipped.
+func (se *SumologicExtension) discoverHostMetadata(ctx context.Context) *api.HostMetadata {
+	md := &api.HostMetadata{
+		OS:               runtime.GOOS,
+		Architecture:     runtime.GOARCH,
+		CollectorVersion: se.buildInfo.Version,
+		Kubernetes:       discoverKubernetesMetadata(),
+	}
+
+	kernel, err := kernelVersion()
+	if err != nil {
+		se.logger.Debug("Unable to get kernel version", zap.Error(err))
+	}
+	md.Kernel = kernel
+
+	if md.IPAddresses, err = primaryIPAddresses(); err != nil {
+		se.logger.Debug("Unable to get IP addresses", zap.Error(err))
+	}
+
+	if endpoint := se.conf.HostMetadata.CloudMetadataEndpoint; endpoint != "" {
+		md.Cloud = discoverCloudMetadata(ctx, strings.TrimSuffix(endpoint, "/"), se.conf.HostMetadata.CloudMetadataTimeout)
+	}
+
+	if se.host != nil {
+		md.Signals, md.Components = hostComponents(se.host)
+	}
+
+	return md
+}
+
+// primaryIPAddresses returns the addresses of the interfaces which are up,
+// skipping loopback and link-local addresses.
+func primaryIPAddresses() ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			ips = append(ips, ipNet.IP.String())
+		}
+	}
+	return ips, nil
+}
+
+// discoverKubernetesMetadata returns the Kubernetes identity of the collector
+// or nil if it doesn't run in Kubernetes.
+func discoverKubernetesMetadata() *api.KubernetesMetadata {
+	if os.Getenv(kubernetesServiceHostEnv) == "" {
+		return nil
+	}
+
+	md := &api.KubernetesMetadata{
+		ClusterName: os.Getenv(kubernetesClusterNameEnv),
+		Namespace:   os.Getenv(kubernetesNamespaceEnv),
+		PodName:     os.Getenv(kubernetesPodNameEnv),
+		NodeName:    os.Getenv(kubernetesNodeNameEnv),
+	}
+	if md.Namespace == "" {
+		if namespace, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+			md.Namespace = strings.TrimSpace(string(namespace))
+		}
+	}
+	if md.PodName == "" {
+		// Pod hostname is the pod name unless set explicitly in the spec.
+		md.PodName, _ = os.Hostname()
+	}
+	return md
+}
+
+// cloudMetadataProvider queries the instance metadata service available
+// under endpoint and returns nil when it's not the provider's one.
+type cloudMetadataProvider func(ctx context.Context, client *http.Client, endpoint string) *api.CloudMetadata
+
+// discoverCloudMetadata queries the instance metadata services of all
+// supported cloud providers concurrently and returns the first identity found.
+func discoverCloudMetadata(ctx context.Context, endpoint string, timeout time.Duration) *api.CloudMetadata {
+	if timeout <= 0 {
+		timeout = DefaultCloudMetadataTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	providers := []cloudMetadataProvider{awsMetadata, gcpMetadata, azureMetadata}
+	results := make([]*api.CloudMetadata, len(providers))
+
+	// The metadata service is link-local, so proxies are never used.
+	client := &http.Client{Transport: &http.Transport{}}
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider cloudMetadataProvider) {
+			defer wg.Done()
+			results[i] = provider(ctx, client, endpoint)
+		}(i, provider)
+	}
+	wg.Wait()
+
+	for _, md := range results {
+		if md != nil {
+			return md
+		}
+	}
+	return nil
+}
+
+func awsMetadata(ctx context.Context, client *http.Client, endpoint string) *api.CloudMetadata {
+	// IMDSv2 requires a session token.
+	token, err := metadataRequest(ctx, client, http.MethodPut, endpoint+"/latest/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"},
+	)
+	if err != nil {
+		return nil
+	}
+
+	body, err := metadataRequest(ctx, client, http.MethodGet, endpoint+"/latest/dynamic/instance-identity/document",
+		map[string]string{"X-aws-ec2-metadata-token": string(token)},
+	)
+	if err != nil {
+		return nil
+	}
+
+	var doc struct {
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		AccountId        string `json:"accountId"`
+		InstanceId       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil || doc.InstanceId == "" {
+		return nil
+	}
+
+	return &api.CloudMetadata{
+		Provider:         "aws",
+		Region:           doc.Region,
+		AvailabilityZone: doc.AvailabilityZone,
+		AccountId:        doc.AccountId,
+		InstanceId:       doc.InstanceId,
+		InstanceType:     doc.InstanceType,
+	}
+}
+
+func gcpMetadata(ctx context.Context, client *http.Client, endpoint string) *api.CloudMetadata {
+	body, err := metadataRequest(ctx, client, http.MethodGet, endpoint+"/computeMetadata/v1/instance/?recursive=true",
+		map[string]string{"Metadata-Flavor": "Google"},
+	)
+	if err != nil {
+		return nil
+	}
+
+	var doc struct {
+		// Zone has the form of projects/<project number>/zones/<zone>.
+		Zone string `json:"zone"`
+		// MachineType has the form of projects/<project number>/machineTypes/<type>.
+		MachineType string      `json:"machineType"`
+		Id          json.Number `json:"id"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil || doc.Id == "" {
+		return nil
+	}
+
+	md := &api.CloudMetadata{
+		Provider:   "gcp",
+		InstanceId: doc.Id.String(),
+	}
+	if parts := strings.Split(doc.Zone, "/"); len(parts) == 4 {
+		md.AccountId = parts[1]
+		md.AvailabilityZone = parts[3]
+		if i := strings.LastIndex(md.AvailabilityZone, "-"); i > 0 {
+			md.Region = md.AvailabilityZone[:i]
+		}
+	}
+	if i := strings.LastIndex(doc.MachineType, "/"); i >= 0 {
+		md.InstanceType = doc.MachineType[i+1:]
+	}
+	return md
+}
+
+func azureMetadata(ctx context.Context, client *http.Client, endpoint string) *api.CloudMetadata {
+	body, err := metadataRequest(ctx, client, http.MethodGet, endpoint+"/metadata/instance/compute?api-version=2021-02-01",
+		map[string]string{"Metadata": "true"},
+	)
+	if err != nil {
+		return nil
+	}
+
+	var doc struct {
+		Location       string `json:"location"`
+		Zone           string `json:"zone"`
+		SubscriptionId string `json:"subscriptionId"`
+		VmId           string `json:"vmId"`
+		VmSize         string `json:"vmSize"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil || doc.VmId == "" {
+		return nil
+	}
+
+	return &api.CloudMetadata{
+		Provider:         "azure",
+		Region:           doc.Location,
+		AvailabilityZone: doc.Zone,
+		AccountId:        doc.SubscriptionId,
+		InstanceId:       doc.VmId,
+		InstanceType:     doc.VmSize,
+	}
+}
+
+func metadataRequest(ctx context.Context, client *http.Client, method string, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata request returned status %d", res.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+// hostComponents returns the signals with an exporter enabled and the IDs
+// of the components enabled in the collector.
+// Pipelines, receivers and processors are not exposed to extensions,
+// so only extensions and exporters are listed.
+func hostComponents(host component.Host) ([]string, []string) {
+	var (
+		signals    []string
+		components = map[string]struct{}{}
+	)
+	for dataType, exporters := range host.GetExporters() {
+		signals = append(signals, string(dataType))
+		for id := range exporters {
+			components["exporter/"+id.String()] = struct{}{}
+		}
+	}
+	for id := range host.GetExtensions() {
+		components["extension/"+id.String()] = struct{}{}
+	}
+
+	ids := make([]string, 0, len(components))
+	for id := range components {
+		ids = append(ids, id)
+	}
+	sort.Strings(signals)
+	sort.Strings(ids)
+	return signals, ids
+}
@@ -0,0 +1,285 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicextension
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/api"
+)
+
+type hostWithComponents struct {
+	component.Host
+	extensions map[config.ComponentID]component.Extension
+	exporters  map[config.DataType]map[config.ComponentID]component.Exporter
+}
+
+func (h hostWithComponents) GetExtensions() map[config.ComponentID]component.Extension {
+	return h.extensions
+}
+
+func (h hostWithComponents) GetExporters() map[config.DataType]map[config.ComponentID]component.Exporter {
+	return h.exporters
+}
+
+// cloudMetadataServer returns a stand-in for the instance metadata service
+// serving the provided paths.
+func cloudMetadataServer(t *testing.T, responses map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, ok := responses[req.Method+" "+req.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, err := w.Write([]byte(body))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func TestRegisterWithHostMetadata(t *testing.T) {
+	t.Setenv(kubernetesServiceHostEnv, "10.0.0.1")
+	t.Setenv(kubernetesClusterNameEnv, "cluster")
+	t.Setenv(kubernetesNamespaceEnv, "sumologic")
+	t.Setenv(kubernetesPodNameEnv, "collector-0")
+	t.Setenv(kubernetesNodeNameEnv, "node-1")
+
+	cloudSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method + " " + req.URL.Path {
+		case "PUT /latest/api/token":
+			assert.Equal(t, "60", req.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+			_, err := w.Write([]byte("token"))
+			require.NoError(t, err)
+		case "GET /latest/dynamic/instance-identity/document":
+			if req.Header.Get("X-aws-ec2-metadata-token") != "token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, err := w.Write([]byte(`{
+				"accountId": "123456789012",
+				"availabilityZone": "us-east-1a",
+				"instanceId": "i-0123456789",
+				"instanceType": "t3.small",
+				"region": "us-east-1"
+			}`))
+			require.NoError(t, err)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { cloudSrv.Close() })
+
+	payloads := make(chan api.OpenRegisterRequestPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case registerUrl:
+			var payload api.OpenRegisterRequestPayload
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			payloads <- payload
+
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+		case heartbeatUrl:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+	cfg.HostMetadata = hostMetadataConfig{
+		Enabled:               true,
+		CloudMetadataEndpoint: cloudSrv.URL,
+		CloudMetadataTimeout:  5 * time.Second,
+	}
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	se.buildInfo = component.BuildInfo{Version: "v0.57.2-sumo-0"}
+
+	host := hostWithComponents{
+		Host: componenttest.NewNopHost(),
+		extensions: map[config.ComponentID]component.Extension{
+			config.NewComponentID("sumologic"): se,
+		},
+		exporters: map[config.DataType]map[config.ComponentID]component.Exporter{
+			config.MetricsDataType: {config.NewComponentID("sumologic"): nil},
+			config.LogsDataType: {
+				config.NewComponentID("sumologic"):                 nil,
+				config.NewComponentIDWithName("sumologic", "logs"): nil,
+			},
+		},
+	}
+	require.NoError(t, se.Start(context.Background(), host))
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+
+	payload := <-payloads
+	require.NotNil(t, payload.HostMetadata)
+	md := payload.HostMetadata
+
+	assert.Equal(t, runtime.GOOS, md.OS)
+	assert.Equal(t, runtime.GOARCH, md.Architecture)
+	assert.Equal(t, "v0.57.2-sumo-0", md.CollectorVersion)
+	if runtime.GOOS == "linux" {
+		assert.NotEmpty(t, md.Kernel)
+	}
+	assert.Equal(t, &api.CloudMetadata{
+		Provider:         "aws",
+		Region:           "us-east-1",
+		AvailabilityZone: "us-east-1a",
+		AccountId:        "123456789012",
+		InstanceId:       "i-0123456789",
+		InstanceType:     "t3.small",
+	}, md.Cloud)
+	assert.Equal(t, &api.KubernetesMetadata{
+		ClusterName: "cluster",
+		Namespace:   "sumologic",
+		PodName:     "collector-0",
+		NodeName:    "node-1",
+	}, md.Kubernetes)
+	assert.Equal(t, []string{"logs", "metrics"}, md.Signals)
+	assert.Equal(t, []string{
+		"exporter/sumologic",
+		"exporter/sumologic/logs",
+		"extension/sumologic",
+	}, md.Components)
+}
+
+func TestRegisterWithoutHostMetadata(t *testing.T) {
+	t.Parallel()
+
+	payloads := make(chan map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case registerUrl:
+			var payload map[string]interface{}
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			payloads <- payload
+
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+		case heartbeatUrl:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+
+	payload := <-payloads
+	assert.NotContains(t, payload, "hostMetadata")
+}
+
+func TestDiscoverCloudMetadata(t *testing.T) {
+	testcases := []struct {
+		name      string
+		responses map[string]string
+		expected  *api.CloudMetadata
+	}{
+		{
+			name: "gcp",
+			responses: map[string]string{
+				"GET /computeMetadata/v1/instance/?recursive=true": `{
+					"id": 4520031799277581759,
+					"machineType": "projects/123456/machineTypes/e2-medium",
+					"zone": "projects/123456/zones/us-central1-a"
+				}`,
+			},
+			expected: &api.CloudMetadata{
+				Provider:         "gcp",
+				Region:           "us-central1",
+				AvailabilityZone: "us-central1-a",
+				AccountId:        "123456",
+				InstanceId:       "4520031799277581759",
+				InstanceType:     "e2-medium",
+			},
+		},
+		{
+			name: "azure",
+			responses: map[string]string{
+				"GET /metadata/instance/compute?api-version=2021-02-01": `{
+					"location": "westeurope",
+					"zone": "1",
+					"subscriptionId": "8d10da13-8125-4ba9-a717-bf7490507b3d",
+					"vmId": "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+					"vmSize": "Standard_A3"
+				}`,
+			},
+			expected: &api.CloudMetadata{
+				Provider:         "azure",
+				Region:           "westeurope",
+				AvailabilityZone: "1",
+				AccountId:        "8d10da13-8125-4ba9-a717-bf7490507b3d",
+				InstanceId:       "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+				InstanceType:     "Standard_A3",
+			},
+		},
+		{
+			name:      "none",
+			responses: map[string]string{},
+			expected:  nil,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := cloudMetadataServer(t, tc.responses)
+			assert.Equal(t, tc.expected, discoverCloudMetadata(context.Background(), srv.URL, 5*time.Second))
+		})
+	}
+}
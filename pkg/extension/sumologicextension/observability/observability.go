@@ -0,0 +1,113 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package observability
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func init() {
+	err := view.Register(
+		viewHeartbeatSuccess,
+		viewHeartbeatFailure,
+		viewHeartbeatLatency,
+		viewHealthy,
+	)
+	if err != nil {
+		fmt.Printf("Failed to register sumologicextension's views: %v\n", err)
+	}
+}
+
+var (
+	mHeartbeatSuccess = stats.Int64("sumologicextension/heartbeat_success", "Number of successful heartbeat requests", "1")
+	mHeartbeatFailure = stats.Int64("sumologicextension/heartbeat_failure", "Number of failed heartbeat requests", "1")
+	mHeartbeatLatency = stats.Int64("sumologicextension/heartbeat_latency", "Latency of heartbeat requests", "ms")
+	mHealthy          = stats.Int64("sumologicextension/healthy", "Whether the heartbeats succeed (1) or have been failing for longer than heartbeat_unhealthy_after (0)", "1")
+
+	extensionKey, _ = tag.NewKey("extension")
+)
+
+var viewHeartbeatSuccess = &view.View{
+	Name:        mHeartbeatSuccess.Name(),
+	Description: mHeartbeatSuccess.Description(),
+	Measure:     mHeartbeatSuccess,
+	TagKeys:     []tag.Key{extensionKey},
+	Aggregation: view.Sum(),
+}
+
+var viewHeartbeatFailure = &view.View{
+	Name:        mHeartbeatFailure.Name(),
+	Description: mHeartbeatFailure.Description(),
+	Measure:     mHeartbeatFailure,
+	TagKeys:     []tag.Key{extensionKey},
+	Aggregation: view.Sum(),
+}
+
+var viewHeartbeatLatency = &view.View{
+	Name:        mHeartbeatLatency.Name(),
+	Description: mHeartbeatLatency.Description(),
+	Measure:     mHeartbeatLatency,
+	TagKeys:     []tag.Key{extensionKey},
+	Aggregation: view.Distribution(10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000),
+}
+
+var viewHealthy = &view.View{
+	Name:        mHealthy.Name(),
+	Description: mHealthy.Description(),
+	Measure:     mHealthy,
+	TagKeys:     []tag.Key{extensionKey},
+	Aggregation: view.LastValue(),
+}
+
+// RecordHeartbeatSuccess increments the metric that records successful heartbeats
+// and records the heartbeat latency.
+func RecordHeartbeatSuccess(extension string, latency time.Duration) error {
+	return stats.RecordWithTags(
+		context.Background(),
+		[]tag.Mutator{tag.Upsert(extensionKey, extension)},
+		mHeartbeatSuccess.M(int64(1)),
+		mHeartbeatLatency.M(latency.Milliseconds()),
+	)
+}
+
+// RecordHeartbeatFailure increments the metric that records failed heartbeats
+// and records the heartbeat latency.
+func RecordHeartbeatFailure(extension string, latency time.Duration) error {
+	return stats.RecordWithTags(
+		context.Background(),
+		[]tag.Mutator{tag.Upsert(extensionKey, extension)},
+		mHeartbeatFailure.M(int64(1)),
+		mHeartbeatLatency.M(latency.Milliseconds()),
+	)
+}
+
+// RecordHealth records whether the extension is healthy.
+func RecordHealth(extension string, healthy bool) error {
+	value := int64(0)
+	if healthy {
+		value = 1
+	}
+	return stats.RecordWithTags(
+		context.Background(),
+		[]tag.Mutator{tag.Upsert(extensionKey, extension)},
+		mHealthy.M(value),
+	)
+}
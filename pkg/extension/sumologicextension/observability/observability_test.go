@@ -0,0 +1,73 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package observability
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+// retrieve returns the data of the view's row tagged with the extension.
+func retrieve(t *testing.T, v *view.View, extension string) view.AggregationData {
+	rows, err := view.RetrieveData(v.Name)
+	require.NoError(t, err)
+	for _, row := range rows {
+		if len(row.Tags) == 1 && row.Tags[0].Value == extension {
+			return row.Data
+		}
+	}
+	return nil
+}
+
+func TestRecordHeartbeat(t *testing.T) {
+	// The extension name is unique so that the data recorded by other
+	// runs of the test are not taken into account.
+	extension := "sumologic/" + t.Name() + time.Now().String()
+
+	require.Nil(t, retrieve(t, viewHeartbeatSuccess, extension))
+	require.NoError(t, RecordHeartbeatSuccess(extension, 20*time.Millisecond))
+	require.NoError(t, RecordHeartbeatSuccess(extension, 30*time.Millisecond))
+	require.NoError(t, RecordHeartbeatFailure(extension, 5*time.Second))
+
+	success := retrieve(t, viewHeartbeatSuccess, extension)
+	require.NotNil(t, success)
+	assert.Equal(t, float64(2), success.(*view.SumData).Value)
+
+	failure := retrieve(t, viewHeartbeatFailure, extension)
+	require.NotNil(t, failure)
+	assert.Equal(t, float64(1), failure.(*view.SumData).Value)
+
+	latency := retrieve(t, viewHeartbeatLatency, extension)
+	require.NotNil(t, latency)
+	assert.EqualValues(t, 3, latency.(*view.DistributionData).Count)
+}
+
+func TestRecordHealth(t *testing.T) {
+	extension := "sumologic/" + t.Name() + time.Now().String()
+
+	require.NoError(t, RecordHealth(extension, true))
+	healthy := retrieve(t, viewHealthy, extension)
+	require.NotNil(t, healthy)
+	assert.Equal(t, float64(1), healthy.(*view.LastValueData).Value)
+
+	require.NoError(t, RecordHealth(extension, false))
+	healthy = retrieve(t, viewHealthy, extension)
+	require.NotNil(t, healthy)
+	assert.Equal(t, float64(0), healthy.(*view.LastValueData).Value)
+}
@@ -0,0 +1,770 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicextension
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-telemetry/opamp-go/client"
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/confmap/converter/expandconverter"
+	"go.opentelemetry.io/collector/service"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultOpAMPReloadTimeout = time.Minute
+
+	opampConfigContentType = "text/yaml"
+
+	// opampRedactedValue replaces the values of the sensitive fields
+	// in the effective configuration reported to the OpAMP server.
+	opampRedactedValue = "<redacted>"
+
+	// opampValidationScheme is the scheme of the provider serving
+	// the configuration during validation.
+	opampValidationScheme = "opamp"
+
+	// opampClientStopTimeout is the time the client has to disconnect
+	// before it's restarted with new credentials.
+	opampClientStopTimeout = 5 * time.Second
+)
+
+// opampAgent reports the collector configuration and health to the OpAMP server
+// and applies the remote configuration received from it.
+//
+// The remote configuration is written to remote_configuration_file which is
+// matched by the glob config provider pattern, so that the collector reloads it.
+// It's reported as applied only once the extension created by the reload is ready.
+//
+// The client is restarted whenever the collector credentials change, as they
+// are used for the authentication and identify the agent.
+type opampAgent struct {
+	se     *SumologicExtension
+	conf   opampConfig
+	logger *zap.Logger
+
+	clientLock sync.RWMutex
+	client     client.OpAMPClient
+	// remoteConfigStatus is the last reported remote configuration status,
+	// the restarted client starts with it.
+	remoteConfigStatus *protobufs.RemoteConfigStatus
+
+	// applyLock serializes applying remote configurations.
+	applyLock sync.Mutex
+
+	unregisterCredentialsListener func()
+	credentialsChanged            chan struct{}
+
+	startTime time.Time
+	closeChan chan struct{}
+	done      chan struct{}
+}
+
+// pendingRemoteConfig is the remote configuration written to the remote
+// configuration file and not yet loaded by the collector.
+type pendingRemoteConfig struct {
+	hash []byte
+	// agent is the agent which has written the configuration.
+	agent *opampAgent
+	// previous is the content of the file before the configuration
+	// was written, restored if the collector doesn't reload it.
+	previous []byte
+	existed  bool
+	timer    *time.Timer
+}
+
+// pendingRemoteConfigs holds the pending remote configurations by the remote
+// configuration file. The collector creates new extensions on reload, so
+// the pending configuration has to outlive the agent which has written it.
+//
+// runningOpAMPAgents and remoteConfigStatuses hold the running agent and the last
+// reported remote configuration status by the remote configuration file, so that
+// the outcome of a pending configuration is reported even if the agent which
+// has written it was already shut down.
+var (
+	pendingRemoteConfigsLock sync.Mutex
+	pendingRemoteConfigs     = map[string]*pendingRemoteConfig{}
+	runningOpAMPAgents       = map[string]*opampAgent{}
+	remoteConfigStatuses     = map[string]*protobufs.RemoteConfigStatus{}
+)
+
+func newOpAMPAgent(se *SumologicExtension) *opampAgent {
+	return &opampAgent{
+		se:                 se,
+		conf:               se.conf.OpAMP,
+		logger:             se.logger.With(zap.String("component", "opamp")),
+		startTime:          time.Now(),
+		credentialsChanged: make(chan struct{}, 1),
+		closeChan:          make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+}
+
+// Start connects to the OpAMP server using the collector credentials.
+func (a *opampAgent) Start(ctx context.Context) error {
+	pendingRemoteConfigsLock.Lock()
+	a.remoteConfigStatus = remoteConfigStatuses[a.conf.RemoteConfigurationFile]
+	pendingRemoteConfigsLock.Unlock()
+
+	if err := a.startClient(ctx); err != nil {
+		return err
+	}
+
+	pendingRemoteConfigsLock.Lock()
+	runningOpAMPAgents[a.conf.RemoteConfigurationFile] = a
+	pendingRemoteConfigsLock.Unlock()
+
+	a.unregisterCredentialsListener = a.se.OnCredentialsChange(func() {
+		select {
+		case a.credentialsChanged <- struct{}{}:
+		default:
+		}
+	})
+
+	go func() {
+		defer close(a.done)
+		a.loop()
+	}()
+
+	return nil
+}
+
+func (a *opampAgent) Shutdown(ctx context.Context) error {
+	pendingRemoteConfigsLock.Lock()
+	if runningOpAMPAgents[a.conf.RemoteConfigurationFile] == a {
+		delete(runningOpAMPAgents, a.conf.RemoteConfigurationFile)
+	}
+	pendingRemoteConfigsLock.Unlock()
+
+	a.unregisterCredentialsListener()
+	close(a.closeChan)
+	<-a.done
+	return a.getClient().Stop(ctx)
+}
+
+// startClient creates the client and connects to the OpAMP server
+// using the current collector credentials.
+func (a *opampAgent) startClient(ctx context.Context) error {
+	// The client switches to a secure connection whenever TLS configuration is set.
+	var tlsConfig *tls.Config
+	if strings.HasPrefix(a.conf.Endpoint, "wss://") || strings.HasPrefix(a.conf.Endpoint, "https://") {
+		var err error
+		if tlsConfig, err = a.se.conf.TLSSetting.LoadTLSConfig(); err != nil {
+			return fmt.Errorf("cannot load OpAMP TLS configuration: %w", err)
+		}
+	}
+
+	var opampClient client.OpAMPClient
+	logger := opampLogger{a.logger.Sugar()}
+	if strings.HasPrefix(a.conf.Endpoint, "http://") || strings.HasPrefix(a.conf.Endpoint, "https://") {
+		opampClient = client.NewHTTP(logger)
+	} else {
+		opampClient = client.NewWebSocket(logger)
+	}
+
+	if err := opampClient.SetAgentDescription(a.agentDescription()); err != nil {
+		return err
+	}
+
+	regInfo := a.se.getRegistrationInfo()
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString(
+		[]byte(regInfo.CollectorCredentialId+":"+regInfo.CollectorCredentialKey),
+	))
+
+	// The callbacks can be invoked as soon as the client is started.
+	a.clientLock.Lock()
+	a.client = opampClient
+	remoteConfigStatus := a.remoteConfigStatus
+	a.clientLock.Unlock()
+
+	err := opampClient.Start(ctx, types.StartSettings{
+		OpAMPServerURL:     a.conf.Endpoint,
+		Header:             header,
+		TLSConfig:          tlsConfig,
+		InstanceUid:        regInfo.CollectorId,
+		RemoteConfigStatus: remoteConfigStatus,
+		Callbacks: types.CallbacksStruct{
+			OnConnectFunc: func() {
+				a.logger.Info("Connected to the OpAMP server")
+			},
+			OnConnectFailedFunc: func(err error) {
+				a.logger.Warn("Unable to connect to the OpAMP server", zap.Error(err))
+			},
+			OnErrorFunc: func(err *protobufs.ServerErrorResponse) {
+				a.logger.Error("OpAMP server returned an error", zap.String("error", err.ErrorMessage))
+			},
+			OnMessageFunc:          a.onMessage,
+			GetEffectiveConfigFunc: a.effectiveConfig,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot start OpAMP client: %w", err)
+	}
+	return nil
+}
+
+// restartClient reconnects to the OpAMP server with the new collector credentials.
+func (a *opampAgent) restartClient() {
+	a.logger.Info("Collector credentials changed, reconnecting to the OpAMP server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), opampClientStopTimeout)
+	defer cancel()
+	if err := a.getClient().Stop(ctx); err != nil {
+		a.logger.Warn("Unable to stop OpAMP client", zap.Error(err))
+	}
+
+	if err := a.startClient(context.Background()); err != nil {
+		a.logger.Error("Unable to restart OpAMP client", zap.Error(err))
+	}
+}
+
+func (a *opampAgent) getClient() client.OpAMPClient {
+	a.clientLock.RLock()
+	defer a.clientLock.RUnlock()
+	return a.client
+}
+
+// Ready is invoked once the collector pipelines are started. If the agent was
+// created by the reload of a pending remote configuration, the configuration
+// is reported as applied.
+func (a *opampAgent) Ready(ctx context.Context) {
+	pendingRemoteConfigsLock.Lock()
+	pending, ok := pendingRemoteConfigs[a.conf.RemoteConfigurationFile]
+	if !ok || pending.agent == a {
+		pendingRemoteConfigsLock.Unlock()
+		return
+	}
+	pending.timer.Stop()
+	delete(pendingRemoteConfigs, a.conf.RemoteConfigurationFile)
+	pendingRemoteConfigsLock.Unlock()
+
+	a.logger.Info("Remote configuration applied",
+		zap.String("file", a.conf.RemoteConfigurationFile),
+	)
+	a.reportRemoteConfigStatus(ctx, &protobufs.RemoteConfigStatus{
+		LastRemoteConfigHash: pending.hash,
+		Status:               protobufs.RemoteConfigStatus_APPLIED,
+	})
+}
+
+func (a *opampAgent) agentDescription() *protobufs.AgentDescription {
+	hostname, _ := os.Hostname()
+	stringAttr := func(key string, value string) *protobufs.KeyValue {
+		return &protobufs.KeyValue{
+			Key:   key,
+			Value: &protobufs.AnyValue{Value: &protobufs.AnyValue_StringValue{StringValue: value}},
+		}
+	}
+
+	return &protobufs.AgentDescription{
+		IdentifyingAttributes: []*protobufs.KeyValue{
+			stringAttr("service.name", a.se.buildInfo.Command),
+			stringAttr("service.version", a.se.buildInfo.Version),
+			stringAttr("service.instance.id", a.se.CollectorID()),
+		},
+		NonIdentifyingAttributes: []*protobufs.KeyValue{
+			stringAttr(collectorNameField, a.se.collectorName),
+			stringAttr("host.name", hostname),
+			stringAttr("os.type", runtime.GOOS),
+			stringAttr("host.arch", runtime.GOARCH),
+		},
+	}
+}
+
+// loop reports the extension health whenever it changes and restarts
+// the client when the collector credentials change.
+func (a *opampAgent) loop() {
+	var last *protobufs.AgentHealth
+
+	ticker := time.NewTicker(a.se.conf.HeartBeatInterval)
+	defer ticker.Stop()
+	for {
+		health := &protobufs.AgentHealth{
+			Up:                true,
+			StartTimeUnixNano: uint64(a.startTime.UnixNano()),
+		}
+		if err := a.se.Health(); err != nil {
+			health.Up = false
+			health.LastError = err.Error()
+		}
+
+		if last == nil || last.Up != health.Up || last.LastError != health.LastError {
+			if err := a.getClient().SetHealth(health); err != nil {
+				a.logger.Warn("Unable to report health to the OpAMP server", zap.Error(err))
+			}
+			last = health
+		}
+
+		select {
+		case <-a.closeChan:
+			return
+		case <-a.credentialsChanged:
+			a.restartClient()
+			// The health is reported to the new client as well.
+			last = nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// effectiveConfig returns the contents of the configuration files matching
+// the configuration glob.
+func (a *opampAgent) effectiveConfig(context.Context) (*protobufs.EffectiveConfig, error) {
+	files, err := readConfigurationFiles(a.conf.ConfigurationGlob)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap := make(map[string]*protobufs.AgentConfigFile, len(files))
+	for path, content := range files {
+		body, err := redactConfiguration(content)
+		if err != nil {
+			// Don't risk sending secrets from a file we can't parse,
+			// report only its hash so the server can still notice changes.
+			a.logger.Warn("Cannot redact configuration file, reporting its hash only",
+				zap.String("path", path), zap.Error(err),
+			)
+			body = []byte(fmt.Sprintf("# sha256: %x\n", sha256.Sum256(content)))
+		}
+		configMap[path] = &protobufs.AgentConfigFile{
+			Body:        body,
+			ContentType: opampConfigContentType,
+		}
+	}
+	return &protobufs.EffectiveConfig{
+		ConfigMap: &protobufs.AgentConfigMap{ConfigMap: configMap},
+	}, nil
+}
+
+func (a *opampAgent) onMessage(ctx context.Context, msg *types.MessageData) {
+	if msg.RemoteConfig == nil {
+		return
+	}
+
+	a.reportRemoteConfigStatus(ctx, a.applyRemoteConfig(ctx, msg.RemoteConfig))
+}
+
+func (a *opampAgent) reportRemoteConfigStatus(ctx context.Context, status *protobufs.RemoteConfigStatus) {
+	if status.Status == protobufs.RemoteConfigStatus_FAILED {
+		a.logger.Error("Unable to apply remote configuration", zap.String("error", status.ErrorMessage))
+	}
+	pendingRemoteConfigsLock.Lock()
+	remoteConfigStatuses[a.conf.RemoteConfigurationFile] = status
+	pendingRemoteConfigsLock.Unlock()
+
+	a.clientLock.Lock()
+	a.remoteConfigStatus = status
+	a.clientLock.Unlock()
+
+	opampClient := a.getClient()
+	if err := opampClient.SetRemoteConfigStatus(status); err != nil {
+		a.logger.Warn("Unable to report remote configuration status", zap.Error(err))
+	}
+	if err := opampClient.UpdateEffectiveConfig(ctx); err != nil {
+		a.logger.Warn("Unable to report effective configuration", zap.Error(err))
+	}
+}
+
+// applyRemoteConfig validates the remote configuration together with the local
+// configuration files and writes it to the remote configuration file.
+// The written configuration stays pending until the collector reloads it,
+// see Ready. The validation is static: building or starting the components
+// can still fail on reload.
+func (a *opampAgent) applyRemoteConfig(ctx context.Context, remoteConfig *protobufs.AgentRemoteConfig) *protobufs.RemoteConfigStatus {
+	a.applyLock.Lock()
+	defer a.applyLock.Unlock()
+
+	failed := func(err error) *protobufs.RemoteConfigStatus {
+		return &protobufs.RemoteConfigStatus{
+			LastRemoteConfigHash: remoteConfig.ConfigHash,
+			Status:               protobufs.RemoteConfigStatus_FAILED,
+			ErrorMessage:         err.Error(),
+		}
+	}
+
+	body, err := remoteConfigBody(remoteConfig)
+	if err != nil {
+		return failed(err)
+	}
+
+	files, err := readConfigurationFiles(a.conf.ConfigurationGlob)
+	if err != nil {
+		return failed(err)
+	}
+	previous, existed := files[a.conf.RemoteConfigurationFile]
+
+	pendingRemoteConfigsLock.Lock()
+	defer pendingRemoteConfigsLock.Unlock()
+	pending, isPending := pendingRemoteConfigs[a.conf.RemoteConfigurationFile]
+
+	if existed && bytes.Equal(previous, body) {
+		if !isPending {
+			return &protobufs.RemoteConfigStatus{
+				LastRemoteConfigHash: remoteConfig.ConfigHash,
+				Status:               protobufs.RemoteConfigStatus_APPLIED,
+			}
+		}
+		pending.hash = remoteConfig.ConfigHash
+		return &protobufs.RemoteConfigStatus{
+			LastRemoteConfigHash: remoteConfig.ConfigHash,
+			Status:               protobufs.RemoteConfigStatus_APPLYING,
+		}
+	}
+
+	files[a.conf.RemoteConfigurationFile] = body
+	if err := validateConfiguration(ctx, a.se.host, files); err != nil {
+		return failed(fmt.Errorf("invalid remote configuration: %w", err))
+	}
+
+	// The configuration replacing a pending one is rolled back to the last
+	// configuration loaded by the collector.
+	if isPending {
+		pending.timer.Stop()
+		previous, existed = pending.previous, pending.existed
+	}
+	pending = &pendingRemoteConfig{
+		hash:     remoteConfig.ConfigHash,
+		agent:    a,
+		previous: previous,
+		existed:  existed,
+	}
+	pending.timer = time.AfterFunc(a.conf.ReloadTimeout, func() { a.reloadTimedOut(pending) })
+	// The configuration is pending before it's written, as the collector
+	// might reload it before the write returns.
+	pendingRemoteConfigs[a.conf.RemoteConfigurationFile] = pending
+
+	if err := writeFileAtomically(a.conf.RemoteConfigurationFile, body); err != nil {
+		pending.timer.Stop()
+		delete(pendingRemoteConfigs, a.conf.RemoteConfigurationFile)
+		return failed(fmt.Errorf("cannot write remote configuration: %w", err))
+	}
+
+	a.logger.Info("Remote configuration written, waiting for the collector to reload it",
+		zap.String("file", a.conf.RemoteConfigurationFile),
+	)
+	return &protobufs.RemoteConfigStatus{
+		LastRemoteConfigHash: remoteConfig.ConfigHash,
+		Status:               protobufs.RemoteConfigStatus_APPLYING,
+	}
+}
+
+// reloadTimedOut restores the previous remote configuration file if the collector
+// hasn't reloaded the pending configuration within the reload timeout.
+//
+// The agent may have been already shut down by a reload whose new agent never
+// got ready, e.g. because the configuration failed to start. The file is restored
+// regardless and the failure is reported by the agent running at the moment,
+// or by the next one to start if there's none.
+func (a *opampAgent) reloadTimedOut(pending *pendingRemoteConfig) {
+	a.applyLock.Lock()
+	defer a.applyLock.Unlock()
+
+	pendingRemoteConfigsLock.Lock()
+	if pendingRemoteConfigs[a.conf.RemoteConfigurationFile] != pending {
+		pendingRemoteConfigsLock.Unlock()
+		return
+	}
+	delete(pendingRemoteConfigs, a.conf.RemoteConfigurationFile)
+
+	status := &protobufs.RemoteConfigStatus{
+		LastRemoteConfigHash: pending.hash,
+		Status:               protobufs.RemoteConfigStatus_FAILED,
+	}
+	if err := a.rollback(pending.previous, pending.existed); err != nil {
+		status.ErrorMessage = fmt.Sprintf("remote configuration wasn't reloaded within %s, rollback failed: %v", a.conf.ReloadTimeout, err)
+	} else {
+		status.ErrorMessage = fmt.Sprintf("remote configuration wasn't reloaded within %s, rolled back", a.conf.ReloadTimeout)
+	}
+
+	reporter := runningOpAMPAgents[a.conf.RemoteConfigurationFile]
+	if reporter == nil {
+		a.logger.Error("Unable to apply remote configuration", zap.String("error", status.ErrorMessage))
+		remoteConfigStatuses[a.conf.RemoteConfigurationFile] = status
+	}
+	pendingRemoteConfigsLock.Unlock()
+
+	if reporter != nil {
+		reporter.reportRemoteConfigStatus(context.Background(), status)
+	}
+}
+
+func (a *opampAgent) rollback(previous []byte, existed bool) error {
+	if !existed {
+		return os.Remove(a.conf.RemoteConfigurationFile)
+	}
+	return writeFileAtomically(a.conf.RemoteConfigurationFile, previous)
+}
+
+// remoteConfigBody returns the only configuration file of the remote configuration.
+func remoteConfigBody(remoteConfig *protobufs.AgentRemoteConfig) ([]byte, error) {
+	if remoteConfig.Config == nil || len(remoteConfig.Config.ConfigMap) != 1 {
+		return nil, errors.New("remote configuration has to consist of exactly one file")
+	}
+	for _, file := range remoteConfig.Config.ConfigMap {
+		return file.Body, nil
+	}
+	return nil, nil
+}
+
+// readConfigurationFiles returns the contents of the files matching the pattern.
+func readConfigurationFiles(globPattern string) (map[string][]byte, error) {
+	paths, err := filepath.Glob(globPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files[path] = content
+	}
+	return files, nil
+}
+
+// redactConfiguration replaces the values of the sensitive fields, like
+// install_token or credentials, in the configuration file content so that
+// they are not sent to the OpAMP server.
+func redactConfiguration(content []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		return content, nil
+	}
+	redactNode(&doc, false)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// redactNode redacts the scalar values of the sensitive keys in the node
+// and all of its children. When redactAll is set, all scalar values are
+// redacted, which is used e.g. for HTTP headers.
+func redactNode(node *yaml.Node, redactAll bool) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if redactAll && node.Value != "" {
+			redactScalar(node)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			redactNode(value, redactAll || isSensitiveConfigurationKey(key.Value))
+		}
+	default:
+		for _, child := range node.Content {
+			redactNode(child, redactAll)
+		}
+	}
+}
+
+func redactScalar(node *yaml.Node) {
+	node.Value = opampRedactedValue
+	node.Tag = "!!str"
+	node.Style = 0
+}
+
+// isSensitiveConfigurationKey reports whether the value of the configuration
+// key with the given name can contain secrets.
+func isSensitiveConfigurationKey(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "headers", "authorization", "credentials":
+		return true
+	}
+	for _, suffix := range []string{"token", "password", "secret", "_key", "apikey"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateConfiguration merges the configuration files the same way the glob
+// config provider does and validates the result like the collector does on start.
+func validateConfiguration(ctx context.Context, host component.Host, files map[string][]byte) error {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	conf := confmap.New()
+	for _, path := range paths {
+		var rawConf map[string]interface{}
+		if err := yaml.Unmarshal(files[path], &rawConf); err != nil {
+			return fmt.Errorf("cannot parse %s: %w", path, err)
+		}
+		if err := conf.Merge(confmap.NewFromStringMap(rawConf)); err != nil {
+			return err
+		}
+	}
+
+	factories, err := configurationFactories(host, conf)
+	if err != nil {
+		return err
+	}
+
+	provider, err := service.NewConfigProvider(service.ConfigProviderSettings{
+		Locations: []string{opampValidationScheme + ":"},
+		MapProviders: map[string]confmap.Provider{
+			opampValidationScheme: staticProvider{conf: conf.ToStringMap()},
+		},
+		MapConverters: []confmap.Converter{expandconverter.New()},
+	})
+	if err != nil {
+		return err
+	}
+	defer provider.Shutdown(ctx)
+
+	_, err = provider.Get(ctx, factories)
+	return err
+}
+
+// configurationFactories returns the factories of all components used
+// in the configuration, as provided by the host.
+func configurationFactories(host component.Host, conf *confmap.Conf) (component.Factories, error) {
+	factories := component.Factories{
+		Receivers:  map[config.Type]component.ReceiverFactory{},
+		Processors: map[config.Type]component.ProcessorFactory{},
+		Exporters:  map[config.Type]component.ExporterFactory{},
+		Extensions: map[config.Type]component.ExtensionFactory{},
+	}
+
+	for _, section := range []struct {
+		name string
+		kind component.Kind
+	}{
+		{"receivers", component.KindReceiver},
+		{"processors", component.KindProcessor},
+		{"exporters", component.KindExporter},
+		{"extensions", component.KindExtension},
+	} {
+		components, ok := conf.Get(section.name).(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for key := range components {
+			id, err := config.NewComponentIDFromString(key)
+			if err != nil {
+				return component.Factories{}, err
+			}
+
+			factory := host.GetFactory(section.kind, id.Type())
+			if factory == nil {
+				return component.Factories{}, fmt.Errorf("unknown type %q in %s", id.Type(), section.name)
+			}
+
+			switch f := factory.(type) {
+			case component.ReceiverFactory:
+				factories.Receivers[id.Type()] = f
+			case component.ProcessorFactory:
+				factories.Processors[id.Type()] = f
+			case component.ExporterFactory:
+				factories.Exporters[id.Type()] = f
+			case component.ExtensionFactory:
+				factories.Extensions[id.Type()] = f
+			}
+		}
+	}
+
+	return factories, nil
+}
+
+// writeFileAtomically writes the file using a temporary file in the same
+// directory, so that the config provider never reads a partially written file.
+func writeFileAtomically(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// staticProvider serves the configuration being validated.
+type staticProvider struct {
+	conf map[string]interface{}
+}
+
+func (p staticProvider) Retrieve(context.Context, string, confmap.WatcherFunc) (confmap.Retrieved, error) {
+	return confmap.NewRetrieved(p.conf)
+}
+
+func (p staticProvider) Scheme() string {
+	return opampValidationScheme
+}
+
+func (p staticProvider) Shutdown(context.Context) error {
+	return nil
+}
+
+// opampLogger adapts zap logger to the OpAMP client logger.
+type opampLogger struct {
+	logger *zap.SugaredLogger
+}
+
+func (l opampLogger) Debugf(format string, v ...interface{}) {
+	l.logger.Debugf(format, v...)
+}
+
+func (l opampLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Errorf(format, v...)
+}
@@ -0,0 +1,426 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicextension
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"github.com/open-telemetry/opamp-go/server"
+	servertypes "github.com/open-telemetry/opamp-go/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/exporter/loggingexporter"
+	"go.opentelemetry.io/collector/processor/batchprocessor"
+	"go.opentelemetry.io/collector/receiver/otlpreceiver"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/api"
+)
+
+const (
+	localTestConfig = `receivers:
+  otlp:
+    protocols:
+      grpc:
+exporters:
+  logging:
+service:
+  pipelines:
+    traces:
+      receivers: [otlp]
+      exporters: [logging]
+`
+	remoteTestConfig = `processors:
+  batch:
+service:
+  pipelines:
+    traces:
+      receivers: [otlp]
+      processors: [batch]
+      exporters: [logging]
+`
+)
+
+// hostWithFactories is a host providing the factories of the components
+// used in the test configurations.
+type hostWithFactories struct {
+	component.Host
+}
+
+func (hostWithFactories) GetFactory(kind component.Kind, componentType config.Type) component.Factory {
+	var factories []component.Factory
+	switch kind {
+	case component.KindReceiver:
+		factories = []component.Factory{otlpreceiver.NewFactory()}
+	case component.KindProcessor:
+		factories = []component.Factory{batchprocessor.NewFactory()}
+	case component.KindExporter:
+		factories = []component.Factory{loggingexporter.NewFactory()}
+	}
+	for _, f := range factories {
+		if f.Type() == componentType {
+			return f
+		}
+	}
+	return nil
+}
+
+// opampServer is a stand-in for the OpAMP server recording the agent messages.
+// Remote configurations are sent in the responses to the agent messages, as
+// the server connection doesn't allow sending concurrently with the responses.
+type opampServer struct {
+	mu            sync.Mutex
+	messages      []*protobufs.AgentToServer
+	headers       http.Header
+	remoteConfigs []*protobufs.AgentRemoteConfig
+}
+
+func (s *opampServer) OnConnecting(request *http.Request) servertypes.ConnectionResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = request.Header
+	return servertypes.ConnectionResponse{Accept: true}
+}
+
+func (s *opampServer) OnConnected(servertypes.Connection) {}
+
+func (s *opampServer) OnMessage(_ servertypes.Connection, message *protobufs.AgentToServer) *protobufs.ServerToAgent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	response := &protobufs.ServerToAgent{InstanceUid: message.InstanceUid}
+	if len(s.remoteConfigs) > 0 {
+		response.RemoteConfig = s.remoteConfigs[0]
+		s.remoteConfigs = s.remoteConfigs[1:]
+	}
+	return response
+}
+
+func (s *opampServer) OnConnectionClose(servertypes.Connection) {}
+
+// queueRemoteConfig queues the remote configuration to be sent in the response
+// to the next agent message.
+func (s *opampServer) queueRemoteConfig(body string, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remoteConfigs = append(s.remoteConfigs, &protobufs.AgentRemoteConfig{
+		Config: &protobufs.AgentConfigMap{
+			ConfigMap: map[string]*protobufs.AgentConfigFile{
+				"": {Body: []byte(body), ContentType: opampConfigContentType},
+			},
+		},
+		ConfigHash: []byte(hash),
+	})
+}
+
+// lastMessage returns the last message matching the predicate.
+func (s *opampServer) lastMessage(matches func(*protobufs.AgentToServer) bool) *protobufs.AgentToServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if matches(s.messages[i]) {
+			return s.messages[i]
+		}
+	}
+	return nil
+}
+
+func remoteConfigStatus(hash string) func(*protobufs.AgentToServer) bool {
+	return func(msg *protobufs.AgentToServer) bool {
+		return msg.RemoteConfigStatus != nil && string(msg.RemoteConfigStatus.LastRemoteConfigHash) == hash &&
+			msg.RemoteConfigStatus.Status != protobufs.RemoteConfigStatus_UNSET
+	}
+}
+
+// newOpAMPTestConfig returns the extension configuration using the test
+// API server and the OpAMP server, with the local configuration file written
+// to a temporary directory.
+func newOpAMPTestConfig(t *testing.T, opamp *opampServer) *Config {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case registerUrl:
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+		case heartbeatUrl:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	handler, err := server.New(nil).Attach(server.Settings{Callbacks: opamp})
+	require.NoError(t, err)
+	opampSrv := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(func() { opampSrv.Close() })
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "local.yaml"), []byte(localTestConfig), 0600))
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+	cfg.OpAMP.Enabled = true
+	cfg.OpAMP.Endpoint = "ws" + strings.TrimPrefix(opampSrv.URL, "http") + "/v1/opamp"
+	cfg.OpAMP.ConfigurationGlob = filepath.Join(dir, "*.yaml")
+	cfg.OpAMP.RemoteConfigurationFile = filepath.Join(dir, "remote.yaml")
+	require.NoError(t, cfg.Validate())
+	return cfg
+}
+
+// waitForRemoteConfigStatus waits for the remote configuration status
+// with the hash and returns the last one reported.
+func waitForRemoteConfigStatus(t *testing.T, opamp *opampServer, hash string, status protobufs.RemoteConfigStatus_Status) *protobufs.RemoteConfigStatus {
+	require.Eventually(t, func() bool {
+		msg := opamp.lastMessage(remoteConfigStatus(hash))
+		return msg != nil && msg.RemoteConfigStatus.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return opamp.lastMessage(remoteConfigStatus(hash)).RemoteConfigStatus
+}
+
+func TestOpAMPRemoteConfiguration(t *testing.T) {
+	t.Parallel()
+
+	opamp := &opampServer{}
+	// The valid configuration is sent in the response to the first agent
+	// message and the invalid one in the response to the status report.
+	opamp.queueRemoteConfig(remoteTestConfig, "valid")
+	opamp.queueRemoteConfig(strings.ReplaceAll(remoteTestConfig, "batch", "unknown"), "invalid")
+	cfg := newOpAMPTestConfig(t, opamp)
+	localFile := filepath.Join(filepath.Dir(cfg.OpAMP.RemoteConfigurationFile), "local.yaml")
+	remoteFile := cfg.OpAMP.RemoteConfigurationFile
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), hostWithFactories{componenttest.NewNopHost()}))
+
+	// The agent connects using the collector credentials and reports
+	// its health and effective configuration.
+	require.Eventually(t, func() bool {
+		return opamp.lastMessage(func(msg *protobufs.AgentToServer) bool {
+			return msg.Health != nil && msg.EffectiveConfig != nil
+		}) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	msg := opamp.lastMessage(func(msg *protobufs.AgentToServer) bool { return msg.Health != nil && msg.EffectiveConfig != nil })
+	assert.Equal(t, "id", msg.InstanceUid)
+	assert.True(t, msg.Health.Up)
+	assert.Equal(t, localTestConfig, string(msg.EffectiveConfig.ConfigMap.ConfigMap[localFile].Body))
+	opamp.mu.Lock()
+	assert.Equal(t, "Basic Y29sbGVjdG9ySWQ6Y29sbGVjdG9yS2V5", opamp.headers.Get("Authorization"))
+	opamp.mu.Unlock()
+
+	// Valid configuration is written to the remote configuration file
+	// and waits for the collector to reload it.
+	waitForRemoteConfigStatus(t, opamp, "valid", protobufs.RemoteConfigStatus_APPLYING)
+
+	content, err := os.ReadFile(remoteFile)
+	require.NoError(t, err)
+	assert.Equal(t, remoteTestConfig, string(content))
+
+	// Invalid configuration is rejected and the file is left intact.
+	status := waitForRemoteConfigStatus(t, opamp, "invalid", protobufs.RemoteConfigStatus_FAILED)
+	assert.Contains(t, status.ErrorMessage, `unknown type "unknown" in processors`)
+
+	content, err = os.ReadFile(remoteFile)
+	require.NoError(t, err)
+	assert.Equal(t, remoteTestConfig, string(content))
+
+	// The reload replaces the extension, the new one reports
+	// the configuration as applied once the pipelines are started.
+	require.NoError(t, se.Shutdown(context.Background()))
+	se, err = newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), hostWithFactories{componenttest.NewNopHost()}))
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+	require.NoError(t, se.Ready())
+
+	waitForRemoteConfigStatus(t, opamp, "valid", protobufs.RemoteConfigStatus_APPLIED)
+}
+
+func TestOpAMPRemoteConfigurationRollback(t *testing.T) {
+	t.Parallel()
+
+	opamp := &opampServer{}
+	opamp.queueRemoteConfig(remoteTestConfig, "valid")
+	cfg := newOpAMPTestConfig(t, opamp)
+	cfg.OpAMP.ReloadTimeout = 100 * time.Millisecond
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), hostWithFactories{componenttest.NewNopHost()}))
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+
+	waitForRemoteConfigStatus(t, opamp, "valid", protobufs.RemoteConfigStatus_APPLYING)
+	assert.FileExists(t, cfg.OpAMP.RemoteConfigurationFile)
+
+	// The extension which has written the configuration doesn't confirm it.
+	require.NoError(t, se.Ready())
+
+	// The collector doesn't reload the configuration, so it's rolled back.
+	status := waitForRemoteConfigStatus(t, opamp, "valid", protobufs.RemoteConfigStatus_FAILED)
+	assert.Contains(t, status.ErrorMessage, "wasn't reloaded within 100ms, rolled back")
+	assert.NoFileExists(t, cfg.OpAMP.RemoteConfigurationFile)
+}
+
+func TestOpAMPRemoteConfigurationRollbackAfterShutdown(t *testing.T) {
+	t.Parallel()
+
+	opamp := &opampServer{}
+	opamp.queueRemoteConfig(remoteTestConfig, "valid")
+	cfg := newOpAMPTestConfig(t, opamp)
+	cfg.OpAMP.ReloadTimeout = 100 * time.Millisecond
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), hostWithFactories{componenttest.NewNopHost()}))
+
+	waitForRemoteConfigStatus(t, opamp, "valid", protobufs.RemoteConfigStatus_APPLYING)
+	assert.FileExists(t, cfg.OpAMP.RemoteConfigurationFile)
+
+	// The reload shuts the extension down, but the collector fails
+	// to start with the new configuration.
+	require.NoError(t, se.Shutdown(context.Background()))
+
+	// The configuration is rolled back anyway.
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(cfg.OpAMP.RemoteConfigurationFile)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The failure is reported by the extension created by the next reload.
+	se, err = newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), hostWithFactories{componenttest.NewNopHost()}))
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+
+	status := waitForRemoteConfigStatus(t, opamp, "valid", protobufs.RemoteConfigStatus_FAILED)
+	assert.Contains(t, status.ErrorMessage, "wasn't reloaded within 100ms, rolled back")
+}
+
+func TestOpAMPCredentialsChange(t *testing.T) {
+	t.Parallel()
+
+	opamp := &opampServer{}
+	cfg := newOpAMPTestConfig(t, opamp)
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), hostWithFactories{componenttest.NewNopHost()}))
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+
+	require.Eventually(t, func() bool {
+		return opamp.lastMessage(func(msg *protobufs.AgentToServer) bool { return msg.InstanceUid == "id" }) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The collector is registered again with new credentials.
+	se.credentialsLock.Lock()
+	se.registrationInfo = api.OpenRegisterResponsePayload{
+		CollectorCredentialId:  "newCollectorId",
+		CollectorCredentialKey: "newCollectorKey",
+		CollectorId:            "newId",
+	}
+	se.credentialsLock.Unlock()
+	se.notifyCredentialsListeners()
+
+	// The agent reconnects with them and reports its health again.
+	require.Eventually(t, func() bool {
+		return opamp.lastMessage(func(msg *protobufs.AgentToServer) bool {
+			return msg.InstanceUid == "newId" && msg.Health != nil
+		}) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	opamp.mu.Lock()
+	assert.Equal(t, "Basic bmV3Q29sbGVjdG9ySWQ6bmV3Q29sbGVjdG9yS2V5", opamp.headers.Get("Authorization"))
+	opamp.mu.Unlock()
+}
+
+func TestOpAMPRedactConfiguration(t *testing.T) {
+	content := `extensions:
+  sumologic:
+    install_token: dummy_install_token
+    install_token_file: /etc/otelcol/token
+    credentials_encryption:
+      keyring_key: otelcol
+exporters:
+  otlphttp:
+    endpoint: https://example.com
+    headers:
+      Authorization: Bearer dummy
+      X-Tenant: tenant
+  elasticsearch:
+    password: dummy_password
+    api_key: dummy_api_key
+processors:
+  cascading_filter:
+    trace_reject_filters:
+      - name: healthcheck
+        attributes:
+          - key: http.target
+            values: [/health]
+`
+	expected := `extensions:
+  sumologic:
+    install_token: <redacted>
+    install_token_file: /etc/otelcol/token
+    credentials_encryption:
+      keyring_key: <redacted>
+exporters:
+  otlphttp:
+    endpoint: https://example.com
+    headers:
+      Authorization: <redacted>
+      X-Tenant: <redacted>
+  elasticsearch:
+    password: <redacted>
+    api_key: <redacted>
+processors:
+  cascading_filter:
+    trace_reject_filters:
+      - name: healthcheck
+        attributes:
+          - key: http.target
+            values: [/health]
+`
+
+	redacted, err := redactConfiguration([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(redacted))
+
+	redacted, err = redactConfiguration([]byte(localTestConfig))
+	require.NoError(t, err)
+	assert.Equal(t, localTestConfig, string(redacted))
+
+	_, err = redactConfiguration([]byte("receivers: [otlp"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,368 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicextension
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricexport"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	selfObservabilityLogsUrl    = "/api/v1/collector/logs"
+	selfObservabilityMetricsUrl = "/api/v1/collector/metrics"
+
+	// selfObservabilityMetricsPrefix is the same namespace the collector uses
+	// when exposing its own metrics on the Prometheus endpoint.
+	selfObservabilityMetricsPrefix = "otelcol_"
+
+	// maxBufferedLogs limits the number of log entries buffered between flushes.
+	// The oldest entries are dropped when the limit is reached.
+	maxBufferedLogs = 10000
+
+	// selfObservabilityFlushTimeout is the time the remaining data
+	// has to be sent on shutdown.
+	selfObservabilityFlushTimeout = 5 * time.Second
+
+	DefaultSelfObservabilityInterval = time.Minute
+)
+
+var (
+	invalidMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	labelValueEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// WrapCoreWithSelfObservability tees the collector logger into the log buffers
+// of all the Sumo Logic extensions with self observability logs enabled.
+// It's meant to be used as zap.WrapCore option when creating the collector logger,
+// otherwise only metrics can be forwarded.
+func WrapCoreWithSelfObservability(core zapcore.Core) zapcore.Core {
+	return zapcore.NewTee(core, selfObservabilityCore{})
+}
+
+// logBuffers holds the log buffers of the running extensions.
+var (
+	logBuffersLock sync.RWMutex
+	logBuffers     = map[*logBuffer]struct{}{}
+)
+
+func registerLogBuffer(b *logBuffer) {
+	logBuffersLock.Lock()
+	logBuffers[b] = struct{}{}
+	logBuffersLock.Unlock()
+}
+
+func unregisterLogBuffer(b *logBuffer) {
+	logBuffersLock.Lock()
+	delete(logBuffers, b)
+	logBuffersLock.Unlock()
+}
+
+// logBuffer stores encoded log lines until they are forwarded.
+type logBuffer struct {
+	level zapcore.Level
+
+	mu      sync.Mutex
+	lines   [][]byte
+	dropped int
+}
+
+func newLogBuffer(level zapcore.Level) *logBuffer {
+	return &logBuffer{level: level}
+}
+
+func (b *logBuffer) add(line []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.lines) >= maxBufferedLogs {
+		b.lines = b.lines[1:]
+		b.dropped++
+	}
+	b.lines = append(b.lines, line)
+}
+
+// drain returns the buffered lines and the number of lines dropped since
+// the last call.
+func (b *logBuffer) drain() ([][]byte, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines, dropped := b.lines, b.dropped
+	b.lines, b.dropped = nil, 0
+	return lines, dropped
+}
+
+// selfObservabilityCore encodes log entries as JSON and writes them
+// into every registered log buffer accepting the entry level.
+type selfObservabilityCore struct {
+	fields []zapcore.Field
+}
+
+var _ zapcore.Core = selfObservabilityCore{}
+
+func (c selfObservabilityCore) Enabled(level zapcore.Level) bool {
+	logBuffersLock.RLock()
+	defer logBuffersLock.RUnlock()
+
+	for b := range logBuffers {
+		if b.level.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c selfObservabilityCore) With(fields []zapcore.Field) zapcore.Core {
+	return selfObservabilityCore{
+		fields: append(append([]zapcore.Field{}, c.fields...), fields...),
+	}
+}
+
+func (c selfObservabilityCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c selfObservabilityCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	buf, err := enc.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	line := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	line = append([]byte{}, line...)
+	buf.Free()
+
+	logBuffersLock.RLock()
+	defer logBuffersLock.RUnlock()
+	for b := range logBuffers {
+		if b.level.Enabled(entry.Level) {
+			b.add(line)
+		}
+	}
+	return nil
+}
+
+func (c selfObservabilityCore) Sync() error {
+	return nil
+}
+
+// selfObservabilityLoop periodically forwards the collector's own metrics and
+// logs to the registered collector's ingest endpoints.
+func (se *SumologicExtension) selfObservabilityLoop() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-se.closeChan
+		cancel()
+	}()
+
+	ticker := time.NewTicker(se.conf.SelfObservability.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-se.closeChan:
+			// Flush what has been collected so far before exiting.
+			flushCtx, flushCancel := context.WithTimeout(context.Background(), selfObservabilityFlushTimeout)
+			se.sendSelfObservabilityData(flushCtx)
+			flushCancel()
+			return
+		case <-ticker.C:
+			se.sendSelfObservabilityData(ctx)
+		}
+	}
+}
+
+func (se *SumologicExtension) sendSelfObservabilityData(ctx context.Context) {
+	// Errors are logged on debug level so that they aren't forwarded
+	// themselves with the default log level.
+	if se.conf.SelfObservability.Metrics {
+		if err := se.sendSelfObservabilityMetrics(ctx); err != nil {
+			se.logger.Debug("Failed to send collector metrics", zap.Error(err))
+		}
+	}
+	if se.logBuffer != nil {
+		if err := se.sendSelfObservabilityLogs(ctx); err != nil {
+			se.logger.Debug("Failed to send collector logs", zap.Error(err))
+		}
+	}
+}
+
+func (se *SumologicExtension) sendSelfObservabilityMetrics(ctx context.Context) error {
+	exporter := &prometheusExporter{
+		labels: map[string]string{
+			collectorIdField:   se.CollectorID(),
+			collectorNameField: se.collectorName,
+		},
+	}
+	metricexport.NewReader().ReadAndExport(exporter)
+	if exporter.buf.Len() == 0 {
+		return nil
+	}
+
+	return se.sendSelfObservabilityRequest(ctx, selfObservabilityMetricsUrl, "application/vnd.sumologic.prometheus", &exporter.buf)
+}
+
+func (se *SumologicExtension) sendSelfObservabilityLogs(ctx context.Context) error {
+	lines, dropped := se.logBuffer.drain()
+	if dropped > 0 {
+		se.logger.Debug("Dropped collector logs exceeding the buffer size", zap.Int("dropped", dropped))
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return se.sendSelfObservabilityRequest(ctx, selfObservabilityLogsUrl, "application/x-www-form-urlencoded",
+		bytes.NewReader(bytes.Join(lines, []byte("\n"))),
+	)
+}
+
+func (se *SumologicExtension) sendSelfObservabilityRequest(ctx context.Context, path string, contentType string, body io.Reader) error {
+	u, err := url.Parse(se.BaseUrl() + path)
+	if err != nil {
+		return fmt.Errorf("unable to parse URL %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body)
+	if err != nil {
+		return fmt.Errorf("unable to create HTTP request %w", err)
+	}
+
+	req.Header.Add("Content-Type", contentType)
+	req.Header.Add("X-Sumo-Fields", fmt.Sprintf("%s=%s", collectorIdField, se.CollectorID()))
+	res, err := se.collectorHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var buff bytes.Buffer
+		if _, err := io.Copy(&buff, res.Body); err != nil {
+			return fmt.Errorf("failed to copy response body, status code: %d, err: %w", res.StatusCode, err)
+		}
+		return ErrorAPI{
+			status: res.StatusCode,
+			body:   buff.String(),
+		}
+	}
+
+	return nil
+}
+
+// prometheusExporter implements metricexport.Exporter and writes OpenCensus
+// metrics in Prometheus text format, adding the provided labels to every sample.
+type prometheusExporter struct {
+	labels map[string]string
+	buf    bytes.Buffer
+}
+
+func (e *prometheusExporter) ExportMetrics(_ context.Context, metrics []*metricdata.Metric) error {
+	for _, m := range metrics {
+		name := selfObservabilityMetricsPrefix + invalidMetricNameChars.ReplaceAllString(m.Descriptor.Name, "_")
+		for _, ts := range m.TimeSeries {
+			labels := make(map[string]string, len(e.labels)+len(ts.LabelValues))
+			for k, v := range e.labels {
+				labels[k] = v
+			}
+			for i, lv := range ts.LabelValues {
+				if lv.Present && i < len(m.Descriptor.LabelKeys) {
+					labels[m.Descriptor.LabelKeys[i].Key] = lv.Value
+				}
+			}
+
+			for _, p := range ts.Points {
+				e.writePoint(name, labels, p)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *prometheusExporter) writePoint(name string, labels map[string]string, p metricdata.Point) {
+	ts := p.Time.UnixMilli()
+	switch v := p.Value.(type) {
+	case int64:
+		e.writeSample(name, labels, float64(v), ts)
+	case float64:
+		e.writeSample(name, labels, v, ts)
+	case *metricdata.Distribution:
+		e.writeSample(name+"_count", labels, float64(v.Count), ts)
+		e.writeSample(name+"_sum", labels, v.Sum, ts)
+		if v.BucketOptions == nil {
+			return
+		}
+		var cumulative int64
+		for i, b := range v.Buckets {
+			cumulative += b.Count
+			le := math.Inf(1)
+			if i < len(v.BucketOptions.Bounds) {
+				le = v.BucketOptions.Bounds[i]
+			}
+			e.writeSample(name+"_bucket", withLabel(labels, "le", strconv.FormatFloat(le, 'g', -1, 64)), float64(cumulative), ts)
+		}
+	case *metricdata.Summary:
+		e.writeSample(name+"_count", labels, float64(v.Count), ts)
+		e.writeSample(name+"_sum", labels, v.Sum, ts)
+	}
+}
+
+func (e *prometheusExporter) writeSample(name string, labels map[string]string, value float64, ts int64) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	e.buf.WriteString(name)
+	e.buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		fmt.Fprintf(&e.buf, `%s="%s"`, invalidMetricNameChars.ReplaceAllString(k, "_"), labelValueEscaper.Replace(labels[k]))
+	}
+	fmt.Fprintf(&e.buf, "} %s %d\n", strconv.FormatFloat(value, 'g', -1, 64), ts)
+}
+
+func withLabel(labels map[string]string, key string, value string) map[string]string {
+	ret := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		ret[k] = v
+	}
+	ret[key] = value
+	return ret
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicextension
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSelfObservabilityForwarding(t *testing.T) {
+	measure := stats.Int64("sumologicextension/test/requests", "Test requests", stats.UnitDimensionless)
+	v := &view.View{
+		Name:        measure.Name(),
+		Measure:     measure,
+		Description: measure.Description(),
+		Aggregation: view.Sum(),
+	}
+	require.NoError(t, view.Register(v))
+	t.Cleanup(func() { view.Unregister(v) })
+	stats.Record(context.Background(), measure.M(5))
+
+	var (
+		mu      sync.Mutex
+		metrics []string
+		logs    []string
+		fields  []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch req.URL.Path {
+		case registerUrl:
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+		case heartbeatUrl:
+			w.WriteHeader(http.StatusNoContent)
+		case selfObservabilityMetricsUrl:
+			assert.Equal(t, "application/vnd.sumologic.prometheus", req.Header.Get("Content-Type"))
+			metrics = append(metrics, string(body))
+			fields = append(fields, req.Header.Get("X-Sumo-Fields"))
+		case selfObservabilityLogsUrl:
+			logs = append(logs, string(body))
+			fields = append(fields, req.Header.Get("X-Sumo-Fields"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+	cfg.SelfObservability = selfObservabilityConfig{
+		Metrics:  true,
+		Logs:     true,
+		LogLevel: zapcore.WarnLevel,
+		Interval: 50 * time.Millisecond,
+	}
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Start(context.Background(), componenttest.NewNopHost()))
+
+	logger := zap.New(WrapCoreWithSelfObservability(zapcore.NewNopCore()))
+	logger.Info("info message")
+	logger.With(zap.String("component", "test")).Warn("warn message")
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(metrics) > 0 && len(logs) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, se.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Contains(t, metrics[0], `otelcol_sumologicextension_test_requests{collector_id="id",collector_name="collector_name"} 5 `)
+
+	allLogs := strings.Join(logs, "\n")
+	assert.Contains(t, allLogs, `"msg":"warn message","component":"test"`)
+	assert.NotContains(t, allLogs, "info message")
+
+	for _, f := range fields {
+		assert.Equal(t, "collector_id=id", f)
+	}
+
+	// The buffer is not registered anymore after shutdown.
+	logger.Warn("after shutdown")
+	lines, _ := se.logBuffer.drain()
+	assert.Empty(t, lines)
+}
+
+func TestPrometheusExporter(t *testing.T) {
+	ts := time.UnixMilli(1609459200000)
+	e := &prometheusExporter{labels: map[string]string{"collector_id": "id"}}
+
+	require.NoError(t, e.ExportMetrics(context.Background(), []*metricdata.Metric{
+		{
+			Descriptor: metricdata.Descriptor{
+				Name:      "exporter/requests/sent",
+				LabelKeys: []metricdata.LabelKey{{Key: "exporter"}, {Key: "missing"}},
+			},
+			TimeSeries: []*metricdata.TimeSeries{
+				{
+					LabelValues: []metricdata.LabelValue{metricdata.NewLabelValue(`sumo"logic`), {}},
+					Points:      []metricdata.Point{metricdata.NewInt64Point(ts, 3)},
+				},
+			},
+		},
+		{
+			Descriptor: metricdata.Descriptor{
+				Name: "exporter/request/duration",
+			},
+			TimeSeries: []*metricdata.TimeSeries{
+				{
+					Points: []metricdata.Point{metricdata.NewDistributionPoint(ts, &metricdata.Distribution{
+						Count:         3,
+						Sum:           12.5,
+						BucketOptions: &metricdata.BucketOptions{Bounds: []float64{1, 10}},
+						Buckets:       []metricdata.Bucket{{Count: 1}, {Count: 1}, {Count: 1}},
+					})},
+				},
+			},
+		},
+	}))
+
+	assert.Equal(t, `otelcol_exporter_requests_sent{collector_id="id",exporter="sumo\"logic"} 3 1609459200000
+otelcol_exporter_request_duration_count{collector_id="id"} 3 1609459200000
+otelcol_exporter_request_duration_sum{collector_id="id"} 12.5 1609459200000
+otelcol_exporter_request_duration_bucket{collector_id="id",le="1"} 1 1609459200000
+otelcol_exporter_request_duration_bucket{collector_id="id",le="10"} 2 1609459200000
+otelcol_exporter_request_duration_bucket{collector_id="id",le="+Inf"} 3 1609459200000
+`, e.buf.String())
+}
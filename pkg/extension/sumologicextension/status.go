@@ -0,0 +1,197 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicextension
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/credentials"
+)
+
+const (
+	DefaultStatusEndpoint = "localhost:13134"
+
+	statusUrl = "/status"
+)
+
+// collectorStatus is the state of the collector registration and heartbeats
+// reported by the status endpoint.
+type collectorStatus struct {
+	mu sync.RWMutex
+
+	collectorId   string
+	collectorName string
+
+	lastRegistration    time.Time
+	lastRegistrationErr error
+	lastHeartbeat       time.Time
+	lastHeartbeatErr    error
+}
+
+func (s *collectorStatus) setCredentials(colCreds credentials.CollectorCredentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collectorId = colCreds.Credentials.CollectorId
+	s.collectorName = colCreds.CollectorName
+}
+
+func (s *collectorStatus) setRegistration(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRegistration = time.Now()
+	s.lastRegistrationErr = err
+}
+
+func (s *collectorStatus) setHeartbeat(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastHeartbeat = time.Now()
+	s.lastHeartbeatErr = err
+}
+
+// statusResponse is the JSON document returned by the status endpoint.
+type statusResponse struct {
+	CollectorId      string                 `json:"collectorId,omitempty"`
+	CollectorName    string                 `json:"collectorName"`
+	ApiBaseUrl       string                 `json:"apiBaseUrl"`
+	Healthy          bool                   `json:"healthy"`
+	Registration     operationStatus        `json:"registration"`
+	Heartbeat        operationStatus        `json:"heartbeat"`
+	CredentialsStore credentialsStoreStatus `json:"credentialsStore"`
+}
+
+// operationStatus is the result of the last registration or heartbeat,
+// LastAttempt is nil if there was none.
+type operationStatus struct {
+	LastAttempt *time.Time `json:"lastAttempt,omitempty"`
+	Success     bool       `json:"success"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type credentialsStoreStatus struct {
+	Type     credentialsStoreType `json:"type"`
+	Location string               `json:"location,omitempty"`
+}
+
+// credentialsStoreLocator is implemented by the credentials stores which can tell
+// where the credentials are stored. It's not a part of credentials.Store, so that
+// the existing store implementations don't have to implement it.
+type credentialsStoreLocator interface {
+	// Location returns a human readable location of the collector credentials
+	// stored under the specified key.
+	Location(key string) string
+}
+
+var (
+	_ credentialsStoreLocator = credentials.LocalFsStore{}
+	_ credentialsStoreLocator = credentials.KubernetesSecretStore{}
+	_ credentialsStoreLocator = credentials.MemoryStore{}
+)
+
+func newOperationStatus(last time.Time, err error) operationStatus {
+	if last.IsZero() {
+		return operationStatus{}
+	}
+	status := operationStatus{
+		LastAttempt: &last,
+		Success:     err == nil,
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// getStatus returns the current state of the extension.
+func (se *SumologicExtension) getStatus() statusResponse {
+	// Health and base URL are guarded by their own locks, get them before
+	// locking the status to keep the locks order.
+	healthy := se.Health() == nil
+	apiBaseUrl := se.BaseUrl()
+
+	se.status.mu.RLock()
+	defer se.status.mu.RUnlock()
+
+	storeType := se.conf.CredentialsStore.Type
+	if storeType == "" {
+		storeType = credentialsStoreLocalFs
+	}
+
+	var location string
+	if locator, ok := se.credentialsStore.(credentialsStoreLocator); ok {
+		location = locator.Location(se.hashKey)
+	}
+
+	return statusResponse{
+		CollectorId:   se.status.collectorId,
+		CollectorName: se.status.collectorName,
+		ApiBaseUrl:    apiBaseUrl,
+		Healthy:       healthy,
+		Registration:  newOperationStatus(se.status.lastRegistration, se.status.lastRegistrationErr),
+		Heartbeat:     newOperationStatus(se.status.lastHeartbeat, se.status.lastHeartbeatErr),
+		CredentialsStore: credentialsStoreStatus{
+			Type:     storeType,
+			Location: location,
+		},
+	}
+}
+
+func (se *SumologicExtension) handleStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(se.getStatus()); err != nil {
+		se.logger.Debug("Unable to write status response", zap.Error(err))
+	}
+}
+
+// startStatusServer starts serving the status endpoint. It's started before
+// the collector registers, so that registration errors can be inspected
+// while it's being retried.
+func (se *SumologicExtension) startStatusServer() error {
+	listener, err := net.Listen("tcp", se.conf.Status.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(statusUrl, se.handleStatus)
+	se.statusServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	se.statusAddr = listener.Addr().String()
+
+	go func() {
+		if err := se.statusServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			se.logger.Error("Status server failed", zap.Error(err))
+		}
+	}()
+
+	se.logger.Info("Status endpoint started",
+		zap.String("url", "http://"+se.statusAddr+statusUrl),
+	)
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologicextension
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/extension/sumologicextension/credentials"
+)
+
+func TestStatusEndpoint(t *testing.T) {
+	t.Parallel()
+
+	var (
+		failRegistration int32 = 1
+		failHeartbeat    int32 = 1
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case registerUrl:
+			if atomic.LoadInt32(&failRegistration) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, err := w.Write([]byte(`{}`))
+				require.NoError(t, err)
+				return
+			}
+			_, err := w.Write([]byte(`{
+				"collectorCredentialId": "collectorId",
+				"collectorCredentialKey": "collectorKey",
+				"collectorId": "id"
+			}`))
+			require.NoError(t, err)
+
+		case heartbeatUrl:
+			if atomic.LoadInt32(&failHeartbeat) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(func() { srv.Close() })
+
+	// Reserve a port for the status endpoint.
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	statusEndpoint := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.CollectorName = "collector_name"
+	cfg.ExtensionSettings = config.ExtensionSettings{}
+	cfg.ApiBaseUrl = srv.URL
+	cfg.Credentials.InstallToken = "dummy_install_token"
+	cfg.CollectorCredentialsDirectory = t.TempDir()
+	cfg.HeartBeatInterval = 20 * time.Millisecond
+	cfg.BackOff.InitialInterval = 10 * time.Millisecond
+	cfg.BackOff.MaxInterval = 50 * time.Millisecond
+	cfg.Status = statusConfig{
+		Enabled:  true,
+		Endpoint: statusEndpoint,
+	}
+	require.NoError(t, cfg.Validate())
+
+	se, err := newSumologicExtension(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	getStatus := func() (statusResponse, error) {
+		var status statusResponse
+		res, err := http.Get("http://" + statusEndpoint + statusUrl)
+		if err != nil {
+			return status, err
+		}
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		err = json.NewDecoder(res.Body).Decode(&status)
+		return status, err
+	}
+
+	started := make(chan error, 1)
+	go func() {
+		started <- se.Start(context.Background(), componenttest.NewNopHost())
+	}()
+
+	// Registration errors are reported while the registration is retried.
+	var status statusResponse
+	require.Eventually(t, func() bool {
+		status, err = getStatus()
+		return err == nil && status.Registration.LastAttempt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, status.CollectorId)
+	assert.Equal(t, "collector_name", status.CollectorName)
+	assert.False(t, status.Registration.Success)
+	assert.Contains(t, status.Registration.Error, "got HTTP status code: 503")
+	assert.Nil(t, status.Heartbeat.LastAttempt)
+
+	atomic.StoreInt32(&failRegistration, 0)
+	require.NoError(t, <-started)
+	t.Cleanup(func() { require.NoError(t, se.Shutdown(context.Background())) })
+
+	status, err = getStatus()
+	require.NoError(t, err)
+	assert.Equal(t, "id", status.CollectorId)
+	assert.Equal(t, srv.URL, status.ApiBaseUrl)
+	assert.True(t, status.Registration.Success)
+	assert.Empty(t, status.Registration.Error)
+	assert.Equal(t, credentialsStoreLocalFs, status.CredentialsStore.Type)
+	assert.True(t, strings.HasPrefix(status.CredentialsStore.Location, cfg.CollectorCredentialsDirectory))
+
+	// The last heartbeat result is reported.
+	require.Eventually(t, func() bool {
+		status, err = getStatus()
+		return err == nil && status.Heartbeat.LastAttempt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, status.Heartbeat.Success)
+	assert.Contains(t, status.Heartbeat.Error, "status code: 500")
+	assert.True(t, status.Healthy)
+
+	atomic.StoreInt32(&failHeartbeat, 0)
+	require.Eventually(t, func() bool {
+		status, err = getStatus()
+		return err == nil && status.Heartbeat.Success
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, status.Heartbeat.Error)
+}
+
+// storeWithoutLocation is a credentials store which doesn't report the location.
+type storeWithoutLocation struct {
+	store credentials.Store
+}
+
+func (s storeWithoutLocation) Check(key string) bool {
+	return s.store.Check(key)
+}
+
+func (s storeWithoutLocation) Get(key string) (credentials.CollectorCredentials, error) {
+	return s.store.Get(key)
+}
+
+func (s storeWithoutLocation) Store(key string, creds credentials.CollectorCredentials) error {
+	return s.store.Store(key, creds)
+}
+
+func (s storeWithoutLocation) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+func TestStatusCredentialsStoreWithoutLocation(t *testing.T) {
+	se := &SumologicExtension{
+		conf:             &Config{},
+		credentialsStore: storeWithoutLocation{credentials.NewMemoryStore()},
+	}
+
+	status := se.getStatus()
+	assert.Equal(t, credentialsStoreLocalFs, status.CredentialsStore.Type)
+	assert.Empty(t, status.CredentialsStore.Location)
+
+	se.credentialsStore = credentials.NewMemoryStore()
+	assert.Equal(t, "memory", se.getStatus().CredentialsStore.Location)
+}
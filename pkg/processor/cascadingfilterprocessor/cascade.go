@@ -16,6 +16,7 @@ package cascadingfilterprocessor
 
 import (
 	"math"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -51,7 +52,7 @@ func (c *cascade) decideOnBatch(batch *idbatcher.Batch) {
 	startTime := time.Now()
 	batchLen := len(*batch)
 
-	currSecond := time.Now().Unix()
+	now := time.Now()
 
 	// There are really three steps for making a decision:
 	// 1. Provisional decision - in which we also check for rate for each policy/filter/evaluator (i.e. if a given
@@ -77,10 +78,11 @@ func (c *cascade) decideOnBatch(batch *idbatcher.Batch) {
 			c.totalSpans += int64(trace.SpanCount)
 			// Iterate over evaluators and verify within rate for each of them
 			provisionalDecision, _ = c.makeProvisionalDecision(id, trace)
+			c.evaluateShadowPolicies(id, trace)
 		}
 
 		// Select only traces that fit within the global limit
-		c.firstPass(currSecond, trace, provisionalDecision)
+		c.firstPass(now, trace, provisionalDecision)
 	}
 
 	// The second run executes the decisions and makes "SecondChance" decisions in the meantime
@@ -92,14 +94,14 @@ func (c *cascade) decideOnBatch(batch *idbatcher.Batch) {
 		trace := d.(*sampling.TraceData)
 
 		// If there's anything left, fill-up with "second chance" traces
-		c.secondPass(currSecond, trace)
+		finalDecision := c.secondPass(now, trace)
 
 		c.cfsp.decisionHistory.Add(traceKey(id.Bytes()), decisionHistoryInfo{
-			finalDecision:       trace.FinalDecision,
+			finalDecision:       finalDecision,
 			filterName:          trace.ProvisionalDecisionFilterName,
 			probabilisticFilter: trace.SelectedByProbabilisticFilter})
 
-		c.cleanup(trace)
+		c.cleanup(trace, finalDecision)
 
 		// Actually, we don'c need to wait since decision history is now used and we can delete the trace pretty much right away
 		c.cfsp.dropTrace(id.Bytes())
@@ -123,45 +125,60 @@ func (c *cascade) decideOnBatch(batch *idbatcher.Batch) {
 
 }
 
-func (c *cascade) firstPass(currSecond int64, trace *sampling.TraceData, provisionalDecision sampling.Decision) {
+// firstPass sets the final decision of the traces which fit within the global limit. The final decision
+// is set under the trace lock, as the trace can be evicted concurrently, in which case it stays dropped.
+func (c *cascade) firstPass(now time.Time, trace *sampling.TraceData, provisionalDecision sampling.Decision) {
+	trace.Lock()
+	defer trace.Unlock()
+
+	if trace.FinalDecision == sampling.Dropped {
+		return
+	}
+
 	if provisionalDecision == sampling.Sampled {
-		trace.FinalDecision = c.cfsp.decisionSpansLimitter.updateRate(currSecond, trace.SpanCount)
+		trace.FinalDecision = c.cfsp.decisionSpansLimitter.updateRate(now, trace.SpanCount)
 		if trace.FinalDecision == sampling.Sampled {
 			if trace.SelectedByProbabilisticFilter {
 				c.selectedByProbabilisticFilterSpans += int64(trace.SpanCount)
 			}
 
-			recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusSampled)
+			recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusSampled, trace.Partition)
 		} else {
-			recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusExceededKey)
+			recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusExceededKey, trace.Partition)
 		}
 	} else if provisionalDecision == sampling.SecondChance {
 		trace.FinalDecision = sampling.SecondChance
 	} else {
 		trace.FinalDecision = provisionalDecision
-		recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusNotSampled)
+		recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusNotSampled, trace.Partition)
 	}
 }
 
-func (c *cascade) secondPass(currSecond int64, trace *sampling.TraceData) {
+// secondPass sets the final decision of the second chance traces which fit within the global limit
+// and returns the final decision of the trace.
+func (c *cascade) secondPass(now time.Time, trace *sampling.TraceData) sampling.Decision {
+	trace.Lock()
+	defer trace.Unlock()
+
 	if trace.FinalDecision == sampling.SecondChance {
-		trace.FinalDecision = c.cfsp.decisionSpansLimitter.updateRate(currSecond, trace.SpanCount)
+		trace.FinalDecision = c.cfsp.decisionSpansLimitter.updateRate(now, trace.SpanCount)
 		if trace.FinalDecision == sampling.Sampled {
-			recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusSecondChanceSampled)
+			recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusSecondChanceSampled, trace.Partition)
 		} else {
-			recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusSecondChanceExceeded)
+			recordCascadingFilterDecision(c.cfsp.ctx, c.cfsp.instanceName, statusSecondChanceExceeded, trace.Partition)
 		}
 	}
+	return trace.FinalDecision
 }
 
-func (c *cascade) cleanup(trace *sampling.TraceData) {
+func (c *cascade) cleanup(trace *sampling.TraceData, finalDecision sampling.Decision) {
 	// Sampled or not, remove the batches
 	trace.Lock()
 	traceBatches := trace.ReceivedBatches
 	trace.ReceivedBatches = nil
 	trace.Unlock()
 
-	if trace.FinalDecision == sampling.Sampled {
+	if finalDecision == sampling.Sampled {
 		c.metrics.decisionSampled++
 
 		// Combine all individual batches into a single batch so
@@ -172,13 +189,20 @@ func (c *cascade) cleanup(trace *sampling.TraceData) {
 			batch.ResourceSpans().MoveAndAppendTo(allSpans.ResourceSpans())
 		}
 
-		if trace.SelectedByProbabilisticFilter {
-			updateProbabilisticRateTag(allSpans, c.selectedByProbabilisticFilterSpans, c.totalSpans)
+		if trace.SelectedByProbabilisticFilter && c.cfsp.traceIDFilter != nil {
+			c.cfsp.traceIDFilter.UpdateTraceState(allSpans)
+			updateProbabilisticRateTag(allSpans, c.cfsp.traceIDFilter.Probability())
+		} else if trace.SelectedByProbabilisticFilter {
+			updateProbabilisticRateTag(allSpans, float64(c.selectedByProbabilisticFilterSpans)/float64(c.totalSpans))
 		} else if len(c.cfsp.traceAcceptRules) > 0 {
 			// Set filtering tag only if there were actually any accept rules set otherwise
 			updateFilteringTag(allSpans, trace.ProvisionalDecisionFilterName)
 		}
 
+		if c.cfsp.shadowRuleAttribute && len(trace.ShadowDecisionFilterNames) > 0 {
+			updateShadowRuleTag(allSpans, strings.Join(trace.ShadowDecisionFilterNames, ","))
+		}
+
 		err := c.cfsp.nextConsumer.ConsumeTraces(c.cfsp.ctx, allSpans)
 		if err != nil {
 			c.cfsp.logger.Error("Sampling Policy Evaluation error on consuming traces", zap.Error(err))
@@ -232,7 +256,7 @@ func (c *cascade) makeProvisionalDecision(id pcommon.TraceID, trace *sampling.Tr
 				trace.ProvisionalDecisionFilterName = policy.Name
 			}
 
-			recordProvisionalDecisionMade(policy.ctx, c.cfsp.instanceName, statusSampled)
+			recordProvisionalDecisionMade(policy.ctx, c.cfsp.instanceName, statusSampled, trace.SpanCount)
 
 			// No need to continue
 			return provisionalDecision, policy
@@ -241,23 +265,44 @@ func (c *cascade) makeProvisionalDecision(id pcommon.TraceID, trace *sampling.Tr
 				provisionalDecision = sampling.NotSampled
 			}
 
-			recordProvisionalDecisionMade(policy.ctx, c.cfsp.instanceName, statusNotSampled)
+			recordProvisionalDecisionMade(policy.ctx, c.cfsp.instanceName, statusNotSampled, trace.SpanCount)
 		case sampling.SecondChance:
 			if provisionalDecision != sampling.Sampled {
 				provisionalDecision = sampling.SecondChance
 				trace.ProvisionalDecisionFilterName = policy.Name
 			}
 
-			recordProvisionalDecisionMade(policy.ctx, c.cfsp.instanceName, statusSecondChance)
+			recordProvisionalDecisionMade(policy.ctx, c.cfsp.instanceName, statusSecondChance, trace.SpanCount)
 		}
 	}
 
 	return provisionalDecision, nil
 }
 
-func updateProbabilisticRateTag(traces ptrace.Traces, probabilisticSpans int64, allSpans int64) {
-	ratio := float64(probabilisticSpans) / float64(allSpans)
+// evaluateShadowPolicies records the would-be decisions of the shadow policies, which do not affect the final decision
+func (c *cascade) evaluateShadowPolicies(id pcommon.TraceID, trace *sampling.TraceData) {
+	if len(c.cfsp.shadowTraceAcceptRules) == 0 {
+		return
+	}
+
+	// Shadow policies must not change the partition reported for the final decision
+	partition := trace.Partition
+	defer func() { trace.Partition = partition }()
 
+	for _, policy := range c.cfsp.shadowTraceAcceptRules {
+		switch policy.Evaluator.Evaluate(id, trace) {
+		case sampling.Sampled:
+			trace.ShadowDecisionFilterNames = append(trace.ShadowDecisionFilterNames, policy.Name)
+			recordProvisionalDecisionMade(policy.ctx, c.cfsp.instanceName, statusSampled, trace.SpanCount)
+		case sampling.NotSampled:
+			recordProvisionalDecisionMade(policy.ctx, c.cfsp.instanceName, statusNotSampled, trace.SpanCount)
+		case sampling.SecondChance:
+			recordProvisionalDecisionMade(policy.ctx, c.cfsp.instanceName, statusSecondChance, trace.SpanCount)
+		}
+	}
+}
+
+func updateProbabilisticRateTag(traces ptrace.Traces, ratio float64) {
 	rs := traces.ResourceSpans()
 
 	for i := 0; i < rs.Len(); i++ {
@@ -296,6 +341,20 @@ func updateFilteringTag(traces ptrace.Traces, filterName string) {
 	}
 }
 
+func updateShadowRuleTag(traces ptrace.Traces, shadowRules string) {
+	rs := traces.ResourceSpans()
+
+	for i := 0; i < rs.Len(); i++ {
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				spans.At(k).Attributes().UpsertString(AttributeSamplingShadowRule, shadowRules)
+			}
+		}
+	}
+}
+
 func updateLateArrival(traces ptrace.Traces, filterName string, probabilistic bool) {
 	rs := traces.ResourceSpans()
 
@@ -22,9 +22,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	cfconfig "github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/sampling"
@@ -33,6 +35,7 @@ import (
 var testValue = 10 * time.Millisecond
 var probabilisticFilteringRate = int32(10)
 var healthCheckPattern = "health"
+var minNumberOfSpans = 15
 var cfg = cfconfig.Config{
 	ProcessorSettings:       &config.ProcessorSettings{},
 	DecisionWait:            2 * time.Second,
@@ -185,6 +188,127 @@ func TestProbabilisticFilter(t *testing.T) {
 	cfg.ProbabilisticFilteringRatio = &ratio
 }
 
+func TestTraceIDProbabilisticFilter(t *testing.T) {
+	ratio := float32(1)
+	conf := cfg
+	conf.ProbabilisticFilteringMode = cfconfig.TraceIDProbabilisticFilteringMode
+	conf.ProbabilisticFilteringRatio = &ratio
+	cascading := createCascadeWithConfig(t, conf)
+	require.NotNil(t, cascading.cfsp.traceIDFilter)
+
+	trace1 := createTrace(cascading, 8, 1000)
+	decision, _ := cascading.makeProvisionalDecision(pcommon.NewTraceID([16]byte{0}), trace1)
+	require.Equal(t, sampling.Sampled, decision)
+	require.True(t, trace1.SelectedByProbabilisticFilter)
+
+	// The ratio is rounded down to a power of two and the effective one is logged.
+	ratio = 0.1
+	core, logs := observer.New(zap.WarnLevel)
+	processor, err := newCascadingFilterSpanProcessor(zap.New(core), nil, conf)
+	require.NoError(t, err)
+	require.Equal(t, 0.0625, processor.traceIDFilter.Probability())
+	require.Equal(t, 1, logs.FilterMessage("Trace ID probabilistic filtering ratio rounded down to a power of two").Len())
+
+	conf.ProbabilisticFilteringRate = &probabilisticFilteringRate
+	_, err = newCascadingFilterSpanProcessor(zap.NewNop(), nil, conf)
+	require.EqualError(t, err, "trace_id probabilistic filtering mode requires probabilistic_filtering_ratio and no probabilistic_filtering_rate")
+
+	conf.ProbabilisticFilteringMode = "unknown"
+	_, err = newCascadingFilterSpanProcessor(zap.NewNop(), nil, conf)
+	require.EqualError(t, err, "unexpected probabilistic filtering mode: unknown")
+}
+
+func TestShadowPolicies(t *testing.T) {
+	conf := cfg
+	conf.ShadowRuleAttribute = true
+	conf.PolicyCfgs = []cfconfig.TraceAcceptCfg{
+		{
+			Name:           "duration",
+			SpansPerSecond: 10,
+			PropertiesCfg: cfconfig.PropertiesCfg{
+				MinDuration: &testValue,
+			},
+		},
+		{
+			Name:           "shadow-duration",
+			SpansPerSecond: 100,
+			PropertiesCfg: cfconfig.PropertiesCfg{
+				MinDuration: &testValue,
+			},
+			Shadow: true,
+		},
+		{
+			Name:           "shadow-many-spans",
+			SpansPerSecond: 100,
+			PropertiesCfg: cfconfig.PropertiesCfg{
+				MinNumberOfSpans: &minNumberOfSpans,
+			},
+			Shadow: true,
+		},
+	}
+
+	sink := new(consumertest.TracesSink)
+	cfsp, err := newCascadingFilterSpanProcessor(zap.NewNop(), sink, conf)
+	require.NoError(t, err)
+	cascading := newCascade(cfsp)
+	require.Len(t, cascading.cfsp.traceAcceptRules, 1)
+	require.Len(t, cascading.cfsp.shadowTraceAcceptRules, 2)
+
+	// Shadow policies would select the trace which is not selected by the live policy
+	trace1 := createTrace(cascading, 20, 1000)
+	decision, _ := cascading.makeProvisionalDecision(pcommon.NewTraceID([16]byte{0}), trace1)
+	require.Equal(t, sampling.NotSampled, decision)
+	cascading.evaluateShadowPolicies(pcommon.NewTraceID([16]byte{0}), trace1)
+	require.Equal(t, []string{"shadow-many-spans"}, trace1.ShadowDecisionFilterNames)
+
+	// The final decision is made by the live policy only, shadow rules are added as the attribute
+	trace2 := createTrace(cascading, 8, 1000000)
+	decision, _ = cascading.makeProvisionalDecision(pcommon.NewTraceID([16]byte{1}), trace2)
+	require.Equal(t, sampling.Sampled, decision)
+	cascading.evaluateShadowPolicies(pcommon.NewTraceID([16]byte{1}), trace2)
+	require.Equal(t, []string{"shadow-duration"}, trace2.ShadowDecisionFilterNames)
+	require.Equal(t, "duration", trace2.ProvisionalDecisionFilterName)
+
+	cascading.cleanup(trace2, sampling.Sampled)
+	require.Len(t, sink.AllTraces(), 1)
+	attrs := sink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+	shadowRule, found := attrs.Get(AttributeSamplingShadowRule)
+	require.True(t, found)
+	require.Equal(t, "shadow-duration", shadowRule.StringVal())
+	filter, found := attrs.Get(AttributeSamplingFilter)
+	require.True(t, found)
+	require.Equal(t, "duration", filter.StringVal())
+}
+
+func TestTotalBurst(t *testing.T) {
+	// Without burst, the trace larger than the total limit never fits
+	cascading := createCascade(t)
+	trace1 := createTrace(cascading, 2000, 1000000)
+	cascading.firstPass(time.Now(), trace1, sampling.Sampled)
+	require.Equal(t, sampling.NotSampled, trace1.FinalDecision)
+
+	conf := cfg
+	conf.Burst = 3000
+	cascading = createCascadeWithConfig(t, conf)
+	trace2 := createTrace(cascading, 2000, 1000000)
+	cascading.firstPass(time.Now(), trace2, sampling.Sampled)
+	require.Equal(t, sampling.Sampled, trace2.FinalDecision)
+
+	trace3 := createTrace(cascading, 2000, 1000000)
+	cascading.firstPass(time.Now(), trace3, sampling.Sampled)
+	require.Equal(t, sampling.NotSampled, trace3.FinalDecision)
+
+	conf = cfg
+	conf.Burst = 100
+	_, err := newCascadingFilterSpanProcessor(zap.NewNop(), nil, conf)
+	require.EqualError(t, err, "burst cannot be lower than the total spans per second limit")
+
+	conf = cfgJustDropping
+	conf.Burst = 3000
+	_, err = newCascadingFilterSpanProcessor(zap.NewNop(), nil, conf)
+	require.EqualError(t, err, "burst requires the total spans per second limit")
+}
+
 func TestDropTraces(t *testing.T) {
 	cascading := createCascade(t)
 
@@ -34,8 +34,64 @@ type TraceAcceptCfg struct {
 	PropertiesCfg PropertiesCfg `mapstructure:"properties"`
 	// SpansPerSecond specifies the rule budget that should never be exceeded for it
 	SpansPerSecond int32 `mapstructure:"spans_per_second"`
+	// Burst (optional) replaces the per-second budget window with a token bucket refilled with SpansPerSecond
+	// spans per second and holding at most Burst spans, it cannot be lower than SpansPerSecond. When not set,
+	// the budget is reset every second
+	Burst int32 `mapstructure:"burst"`
 	// InvertMatch specifies if the match should be inverted. Default: false
 	InvertMatch bool `mapstructure:"invert_match"`
+	// Condition (optional) is a tree of conditions combined with and/or/not which must be met by the trace.
+	// It cannot be used together with the other matching options of the policy.
+	Condition *ConditionCfg `mapstructure:"condition"`
+	// Partition (optional) splits the SpansPerSecond budget between the partitions identified by the value
+	// of a resource attribute, so that a single noisy partition cannot exhaust the whole budget.
+	Partition *PartitionCfg `mapstructure:"partition"`
+	// Shadow makes the policy evaluated alongside the live ones without affecting the final decision.
+	// Its would-be decisions are recorded in the metrics with the shadow tag
+	Shadow bool `mapstructure:"shadow"`
+}
+
+// PartitionMode describes how the budget is split between the partitions
+type PartitionMode string
+
+const (
+	// EqualPartitionMode gives each partition the same part of the budget
+	EqualPartitionMode PartitionMode = "equal"
+	// FairSharePartitionMode gives each partition the same part of the budget, and lets the partitions
+	// use the part of the budget which is not needed by the other partitions
+	FairSharePartitionMode PartitionMode = "fair_share"
+)
+
+// PartitionCfg splits the spans per second budget between the partitions
+type PartitionCfg struct {
+	// Attribute is the resource attribute which value identifies the partition, e.g. service.name.
+	// Traces without the attribute are put in a separate partition.
+	Attribute string `mapstructure:"attribute"`
+	// Mode describes how the budget is split between the partitions:
+	//   * equal (default) - each partition active in the current or previous second gets the same part
+	//     of the budget
+	//   * fair_share - same as equal, but the budget which is not needed by the other partitions (basing on
+	//     their demand in the previous second) can be used by any partition
+	Mode PartitionMode `mapstructure:"mode"`
+}
+
+// ConditionCfg is a node of the condition tree. It either combines other conditions using And, Or or Not
+// or matches the trace using the remaining options, which all must be met (the same as for a policy).
+type ConditionCfg struct {
+	// And is met when all of the conditions are met.
+	And []ConditionCfg `mapstructure:"and"`
+	// Or is met when any of the conditions is met.
+	Or []ConditionCfg `mapstructure:"or"`
+	// Not is met when the condition is not met.
+	Not *ConditionCfg `mapstructure:"not"`
+	// Configs for numeric attribute filter.
+	NumericAttributeCfg *NumericAttributeCfg `mapstructure:"numeric_attribute"`
+	// Configs for string attribute filter.
+	StringAttributeCfg *StringAttributeCfg `mapstructure:"string_attribute"`
+	// AttributesCfg keeps generic string/numeric attributes for multiple keys
+	AttributeCfg []AttributeCfg `mapstructure:"attributes"`
+	// Configs for properties filter.
+	PropertiesCfg PropertiesCfg `mapstructure:"properties"`
 }
 
 // PropertiesCfg holds the configurable settings to create a duration filter
@@ -44,10 +100,22 @@ type PropertiesCfg struct {
 	NamePattern *string `mapstructure:"name_pattern"`
 	// MinDuration (optional) is the minimum duration of trace to be considered a match.
 	MinDuration *time.Duration `mapstructure:"min_duration"`
+	// MaxDuration (optional) is the maximum duration of trace to be considered a match.
+	MaxDuration *time.Duration `mapstructure:"max_duration"`
 	// MinNumberOfSpans (optional) is the minimum number spans that must be present in a matching trace.
 	MinNumberOfSpans *int `mapstructure:"min_number_of_spans"`
+	// MaxNumberOfSpans (optional) is the maximum number spans that can be present in a matching trace.
+	MaxNumberOfSpans *int `mapstructure:"max_number_of_spans"`
 	// MinNumberOfErrors (optional) is the minimum number of spans with the status set to error that must be present in a matching trace.
 	MinNumberOfErrors *int `mapstructure:"min_number_of_errors"`
+	// MaxNumberOfErrors (optional) is the maximum number of spans with the status set to error that can be present in a matching trace.
+	MaxNumberOfErrors *int `mapstructure:"max_number_of_errors"`
+	// SpanKinds (optional) is the list of span kinds (e.g. SERVER) of which one must be met by any span.
+	SpanKinds []string `mapstructure:"span_kinds"`
+	// StatusMessagePattern (optional) describes a regular expression that must be met by any span status message.
+	StatusMessagePattern *string `mapstructure:"status_message_pattern"`
+	// RootSpanOnly (default=false) limits matching the span properties and attributes to the root spans.
+	RootSpanOnly bool `mapstructure:"root_span_only"`
 }
 
 // NumericAttributeCfg holds the configurable settings to create a numeric attribute filter
@@ -88,8 +156,20 @@ type AttributeCfg struct {
 	UseRegex bool `mapstructure:"use_regex"`
 	// Ranges keep numeric attribute ranges
 	Ranges []AttributeRange `mapstructure:"ranges"`
+	// Scope (optional) limits matching to either the resource or the span attributes; both are checked by default
+	Scope AttributeScope `mapstructure:"scope"`
 }
 
+// AttributeScope describes which attributes are matched by the attribute filter
+type AttributeScope string
+
+const (
+	// AttributeScopeResource matches the resource attributes only
+	AttributeScopeResource AttributeScope = "resource"
+	// AttributeScopeSpan matches the span attributes only
+	AttributeScopeSpan AttributeScope = "span"
+)
+
 // TraceRejectCfg holds the configurable settings which drop all traces matching the specified criteria (all of them)
 // before further processing
 type TraceRejectCfg struct {
@@ -103,8 +183,31 @@ type TraceRejectCfg struct {
 	AttributeCfg []AttributeCfg `mapstructure:"attributes"`
 	// NamePattern (optional) describes a regular expression that must be met by any span operation name
 	NamePattern *string `mapstructure:"name_pattern"`
+	// SpanKinds (optional) is the list of span kinds (e.g. SERVER) of which one must be met by any span
+	SpanKinds []string `mapstructure:"span_kinds"`
+	// StatusMessagePattern (optional) describes a regular expression that must be met by any span status message
+	StatusMessagePattern *string `mapstructure:"status_message_pattern"`
+	// RootSpanOnly (default=false) limits matching the span properties and attributes to the root spans
+	RootSpanOnly bool `mapstructure:"root_span_only"`
+	// PropertiesCfg (optional) configs properties filter, the same as for the trace accept filters,
+	// except for the span criteria (name_pattern, span_kinds, status_message_pattern and root_span_only)
+	// which are set at the filter level
+	PropertiesCfg PropertiesCfg `mapstructure:"properties"`
+	// Condition (optional) is a tree of conditions combined with and/or/not which must be met by the trace.
+	// It cannot be used together with the other matching options of the filter.
+	Condition *ConditionCfg `mapstructure:"condition"`
 }
 
+// ProbabilisticFilteringMode represents probabilistic_filtering_mode
+type ProbabilisticFilteringMode string
+
+const (
+	// RateProbabilisticFilteringMode selects the traces randomly within the probabilistic filtering budget
+	RateProbabilisticFilteringMode ProbabilisticFilteringMode = "rate"
+	// TraceIDProbabilisticFilteringMode selects the traces basing on the trace ID
+	TraceIDProbabilisticFilteringMode ProbabilisticFilteringMode = "trace_id"
+)
+
 // Config holds the configuration for cascading-filter-based sampling.
 type Config struct {
 	*config.ProcessorSettings `mapstructure:"-"`
@@ -115,21 +218,49 @@ type Config struct {
 	// When set to zero (default value) - it is automatically calculated basing on the accept trace and
 	// probabilistic filtering rate (if present)
 	SpansPerSecond int32 `mapstructure:"spans_per_second"`
+	// Burst (optional) replaces the per-second window of the total budget with a token bucket refilled with
+	// SpansPerSecond spans per second and holding at most Burst spans, it cannot be lower than the total spans
+	// per second limit. When not set, the budget is reset every second
+	Burst int32 `mapstructure:"burst"`
 	// PriorSpansRate specifies the budget for traces where decision was already made previously
 	// By default, it equals to half of SpansPerSecond
 	PriorSpansRate *int32 `mapstructure:"prior_spans_rate"`
 	// ProbabilisticFilteringRatio describes which part (0.0-1.0) of the SpansPerSecond budget
-	// is exclusively allocated for probabilistically selected spans
+	// is exclusively allocated for probabilistically selected spans. In the trace_id mode,
+	// it describes which part of the traces is probabilistically selected, rounded down to a power of two,
+	// and the selected traces still count towards the SpansPerSecond limit
 	ProbabilisticFilteringRatio *float32 `mapstructure:"probabilistic_filtering_ratio"`
+	// ProbabilisticFilteringMode describes how the traces are probabilistically selected:
+	//   * rate (default) - traces are selected randomly within the probabilistic filtering budget
+	//   * trace_id - traces are selected basing on the trace ID and the W3C trace state, so that all
+	//     the collectors make the same decision for a given trace
+	ProbabilisticFilteringMode ProbabilisticFilteringMode `mapstructure:"probabilistic_filtering_mode"`
 	// ProbabilisticFilteringRate describes how many spans per second are exclusively allocated
 	// for probabilistically selected spans
 	ProbabilisticFilteringRate *int32 `mapstructure:"probabilistic_filtering_rate"`
+	// ProbabilisticFilteringPartition (optional) splits the probabilistic filtering budget between the partitions
+	// identified by the value of a resource attribute. It cannot be used in the trace_id mode
+	ProbabilisticFilteringPartition *PartitionCfg `mapstructure:"probabilistic_filtering_partition"`
 	// NumTraces is the number of traces kept on memory. Typically, most of the data
 	// of a trace is released after a sampling decision is taken.
 	NumTraces uint64 `mapstructure:"num_traces"`
+	// MaxBufferedSpans is the maximum number of spans kept in memory for traces awaiting the decision. When exceeded,
+	// the oldest traces are dropped before the decision is made. By default, the buffer is limited only by NumTraces
+	MaxBufferedSpans uint64 `mapstructure:"max_buffered_spans"`
+	// MaxBufferedBytes is the maximum size (in bytes, as OTLP protobuf) of spans kept in memory for traces awaiting
+	// the decision. When exceeded, the oldest traces are dropped before the decision is made. By default, the buffer
+	// is limited only by NumTraces
+	MaxBufferedBytes uint64 `mapstructure:"max_buffered_bytes"`
 	// HistorySize is the number of past decisions kept in memory. The implementation uses LRU, so
 	// decisions for long-running spans are honored. By default it equals to NumTraces
 	HistorySize *uint64 `mapstructure:"history_size"`
+	// DecisionHistoryStorage is the ID of the storage extension (e.g. file_storage) used to persist the
+	// decision history, so that decisions for late spans are honored across restarts. When not set,
+	// the decision history is kept only in memory
+	DecisionHistoryStorage *config.ComponentID `mapstructure:"decision_history_storage"`
+	// DecisionHistorySnapshotInterval is the interval of persisting the decision history in the storage,
+	// the decision history is also persisted on shutdown. By default it equals to 1 minute
+	DecisionHistorySnapshotInterval time.Duration `mapstructure:"decision_history_snapshot_interval"`
 	// ExpectedNewTracesPerSec sets the expected number of new traces sending to the Cascading Filter processor
 	// per second. This helps with allocating data structures with closer to actual usage size.
 	ExpectedNewTracesPerSec uint64 `mapstructure:"expected_new_traces_per_sec"`
@@ -142,4 +273,25 @@ type Config struct {
 	// TraceRejectCfgs sets the criteria for which traces are evaluated before applying sampling rules. If
 	// trace matches them, it is no further processed
 	TraceRejectCfgs []TraceRejectCfg `mapstructure:"trace_reject_filters"`
+	// ShadowRuleAttribute enables adding the names of the shadow policies which would select the trace
+	// as the sampling.shadow_rule attribute of the spans of sampled traces
+	ShadowRuleAttribute bool `mapstructure:"shadow_rule_attribute"`
+	// REDMetrics (optional) enables the request, error and duration metrics computed from all the spans
+	// before filtering, so that the rates are not affected by the filtering
+	REDMetrics *REDMetricsCfg `mapstructure:"red_metrics"`
+}
+
+// REDMetricsCfg configures the request, error and duration metrics computed from the spans
+type REDMetricsCfg struct {
+	// MetricsExporter is the ID of the exporter the metrics are sent to. It must be a part of a metrics pipeline.
+	MetricsExporter config.ComponentID `mapstructure:"metrics_exporter"`
+	// FlushInterval is the interval of sending the metrics. By default it equals to 15 seconds
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// LatencyHistogramBuckets are the upper bounds of the latency histogram buckets. By default,
+	// the buckets from 2ms to 15s are used
+	LatencyHistogramBuckets []time.Duration `mapstructure:"latency_histogram_buckets"`
+	// MaxSeries is the maximum number of the service, operation and span kind combinations the metrics
+	// are computed for. The spans of the combinations above the limit are counted in a single overflow
+	// series. By default it equals to 1000
+	MaxSeries int `mapstructure:"max_series"`
 }
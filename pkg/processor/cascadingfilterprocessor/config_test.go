@@ -46,6 +46,9 @@ func TestLoadConfig(t *testing.T) {
 	probFilteringRate := int32(100)
 	namePatternValue := "foo.*"
 	healthCheckNamePatternValue := "health.*"
+	maxDurationValue := time.Second
+	maxSpansValue := 1
+	maxErrorsValue := 0
 
 	id1 := config.NewComponentIDWithName("cascading_filter", "1")
 	ps1 := config.NewProcessorSettings(id1)
@@ -56,11 +59,24 @@ func TestLoadConfig(t *testing.T) {
 			NumTraces:                  100000,
 			ProcessorSettings:          &ps1,
 			ProbabilisticFilteringRate: &probFilteringRate,
+			ProbabilisticFilteringPartition: &cfconfig.PartitionCfg{
+				Attribute: "service.name",
+			},
+			ShadowRuleAttribute: true,
 			TraceRejectCfgs: []cfconfig.TraceRejectCfg{
 				{
 					Name:        "healthcheck-rule",
 					NamePattern: &healthCheckNamePatternValue,
 				},
+				{
+					Name: "healthcheck-not-errors",
+					Condition: &cfconfig.ConditionCfg{
+						And: []cfconfig.ConditionCfg{
+							{StringAttributeCfg: &cfconfig.StringAttributeCfg{Key: "service.name", Values: []string{"healthcheck"}}},
+							{Not: &cfconfig.ConditionCfg{PropertiesCfg: cfconfig.PropertiesCfg{MinNumberOfErrors: &minErrorsValue}}},
+						},
+					},
+				},
 			},
 			TraceAcceptCfgs: []cfconfig.TraceAcceptCfg{
 				{
@@ -69,6 +85,10 @@ func TestLoadConfig(t *testing.T) {
 					PropertiesCfg: cfconfig.PropertiesCfg{
 						MinNumberOfErrors: &minErrorsValue,
 					},
+					Partition: &cfconfig.PartitionCfg{
+						Attribute: "service.name",
+						Mode:      cfconfig.FairSharePartitionMode,
+					},
 				},
 				{
 					Name:           "include-long-traces",
@@ -96,21 +116,56 @@ func TestLoadConfig(t *testing.T) {
 						},
 					},
 				},
+				{
+					Name:           "include-errors-or-high-latency",
+					SpansPerSecond: 600,
+					Condition: &cfconfig.ConditionCfg{
+						Or: []cfconfig.ConditionCfg{
+							{And: []cfconfig.ConditionCfg{
+								{StringAttributeCfg: &cfconfig.StringAttributeCfg{Key: "service.name", Values: []string{"A"}}},
+								{PropertiesCfg: cfconfig.PropertiesCfg{MinNumberOfErrors: &minErrorsValue}},
+							}},
+							{And: []cfconfig.ConditionCfg{
+								{StringAttributeCfg: &cfconfig.StringAttributeCfg{Key: "service.name", Values: []string{"B"}}},
+								{PropertiesCfg: cfconfig.PropertiesCfg{MinDuration: &minDurationValue}},
+							}},
+						},
+					},
+				},
+				{
+					Name:           "shadow-long-traces",
+					SpansPerSecond: 100,
+					PropertiesCfg: cfconfig.PropertiesCfg{
+						MinNumberOfSpans: &minSpansValue,
+					},
+					Shadow: true,
+				},
 			},
 		})
 
 	id2 := config.NewComponentIDWithName("cascading_filter", "2")
 	priorSpansRate2 := int32(600)
 	priorHistorySize2 := uint64(100)
+	decisionHistoryStorage2 := config.NewComponentID("file_storage")
 	ps2 := config.NewProcessorSettings(id2)
 	assert.Equal(t, cfg.Processors[id2],
 		&cfconfig.Config{
-			ProcessorSettings:           &ps2,
-			DecisionWait:                10 * time.Second,
-			NumTraces:                   100,
-			ExpectedNewTracesPerSec:     10,
-			SpansPerSecond:              1000,
-			HistorySize:                 &priorHistorySize2,
+			ProcessorSettings:               &ps2,
+			DecisionWait:                    10 * time.Second,
+			NumTraces:                       100,
+			MaxBufferedBytes:                10000000,
+			ExpectedNewTracesPerSec:         10,
+			SpansPerSecond:                  1000,
+			Burst:                           2000,
+			HistorySize:                     &priorHistorySize2,
+			DecisionHistoryStorage:          &decisionHistoryStorage2,
+			DecisionHistorySnapshotInterval: 30 * time.Second,
+			REDMetrics: &cfconfig.REDMetricsCfg{
+				MetricsExporter:         config.NewComponentID("nop"),
+				FlushInterval:           30 * time.Second,
+				LatencyHistogramBuckets: []time.Duration{10 * time.Millisecond, 100 * time.Millisecond},
+				MaxSeries:               100,
+			},
 			PriorSpansRate:              &priorSpansRate2,
 			ProbabilisticFilteringRatio: &probFilteringRatio,
 			TraceRejectCfgs: []cfconfig.TraceRejectCfg{
@@ -128,6 +183,15 @@ func TestLoadConfig(t *testing.T) {
 						UseRegex: true,
 					},
 				},
+				{
+					Name:        "short-healthchecks",
+					NamePattern: &healthCheckNamePatternValue,
+					PropertiesCfg: cfconfig.PropertiesCfg{
+						MaxDuration:       &maxDurationValue,
+						MaxNumberOfSpans:  &maxSpansValue,
+						MaxNumberOfErrors: &maxErrorsValue,
+					},
+				},
 			},
 			TraceAcceptCfgs: []cfconfig.TraceAcceptCfg{
 				{
@@ -144,6 +208,7 @@ func TestLoadConfig(t *testing.T) {
 				{
 					Name:           "test-policy-4",
 					SpansPerSecond: 35,
+					Burst:          100,
 				},
 				{
 					Name:                "test-policy-5",
@@ -0,0 +1,219 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"go.opentelemetry.io/collector/component"
+	collectorconfig "go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/sampling"
+)
+
+const (
+	decisionHistoryStorageKey              = "decision_history"
+	defaultDecisionHistorySnapshotInterval = time.Minute
+)
+
+// decisionHistorySnapshot is the persisted form of the decision history
+type decisionHistorySnapshot struct {
+	Entries []decisionHistorySnapshotEntry `json:"entries"`
+}
+
+type decisionHistorySnapshotEntry struct {
+	TraceID             string            `json:"trace_id"`
+	FinalDecision       sampling.Decision `json:"final_decision"`
+	FilterName          string            `json:"filter_name,omitempty"`
+	ProbabilisticFilter bool              `json:"probabilistic_filter,omitempty"`
+}
+
+// decisionHistoryStorage persists the decision history using a storage extension, so that
+// the decisions for late spans are honored across restarts
+type decisionHistoryStorage struct {
+	storageID   collectorconfig.ComponentID
+	processorID collectorconfig.ComponentID
+	interval    time.Duration
+	history     *lru.TwoQueueCache
+	logger      *zap.Logger
+
+	client storage.Client
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newDecisionHistoryStorage(
+	logger *zap.Logger,
+	storageID collectorconfig.ComponentID,
+	processorID collectorconfig.ComponentID,
+	interval time.Duration,
+	history *lru.TwoQueueCache,
+) *decisionHistoryStorage {
+	if interval <= 0 {
+		interval = defaultDecisionHistorySnapshotInterval
+	}
+
+	return &decisionHistoryStorage{
+		storageID:   storageID,
+		processorID: processorID,
+		interval:    interval,
+		history:     history,
+		logger:      logger,
+		done:        make(chan struct{}),
+	}
+}
+
+// start restores the decision history from the storage and starts taking periodic snapshots
+func (s *decisionHistoryStorage) start(ctx context.Context, host component.Host) error {
+	extension, ok := host.GetExtensions()[s.storageID]
+	if !ok {
+		return fmt.Errorf("storage extension '%s' not found", s.storageID)
+	}
+	storageExtension, ok := extension.(storage.Extension)
+	if !ok {
+		return fmt.Errorf("extension '%s' is not a storage extension", s.storageID)
+	}
+
+	client, err := storageExtension.GetClient(ctx, component.KindProcessor, s.processorID, "")
+	if err != nil {
+		return fmt.Errorf("failed to get storage client for extension '%s': %s", s.storageID, err)
+	}
+	s.client = client
+	s.logger.Info("Initialized decision history storage", zap.Any("storage_extension_id", s.storageID))
+
+	if err := s.restore(ctx); err != nil {
+		// The decision history is not essential, so the processor starts with an empty one
+		s.logger.Warn("Failed to restore decision history", zap.Error(err))
+	}
+
+	s.wg.Add(1)
+	go s.snapshotLoop()
+
+	return nil
+}
+
+// shutdown stops the periodic snapshots, persists the final snapshot and releases the storage
+func (s *decisionHistoryStorage) shutdown(ctx context.Context) error {
+	if s.client == nil {
+		return nil
+	}
+
+	close(s.done)
+	s.wg.Wait()
+
+	err := s.snapshot(ctx)
+	if closeErr := s.client.Close(ctx); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *decisionHistoryStorage) snapshotLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.snapshot(context.Background()); err != nil {
+				s.logger.Warn("Failed to persist decision history", zap.Error(err))
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// snapshot persists the current decision history, from the least to the most recently used entry
+func (s *decisionHistoryStorage) snapshot(ctx context.Context) error {
+	keys := s.history.Keys()
+	snapshot := decisionHistorySnapshot{
+		Entries: make([]decisionHistorySnapshotEntry, 0, len(keys)),
+	}
+
+	for _, key := range keys {
+		value, ok := s.history.Peek(key)
+		if !ok {
+			continue
+		}
+		id := key.(traceKey)
+		info := value.(decisionHistoryInfo)
+		snapshot.Entries = append(snapshot.Entries, decisionHistorySnapshotEntry{
+			TraceID:             hex.EncodeToString(id[:]),
+			FinalDecision:       info.finalDecision,
+			FilterName:          info.filterName,
+			ProbabilisticFilter: info.probabilisticFilter,
+		})
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to serialize decision history: %s", err)
+	}
+	if err := s.client.Set(ctx, decisionHistoryStorageKey, data); err != nil {
+		return fmt.Errorf("failed to store decision history: %s", err)
+	}
+
+	s.logger.Debug("Persisted decision history", zap.Int("entries", len(snapshot.Entries)))
+	return nil
+}
+
+// restore adds the persisted decisions to the decision history
+func (s *decisionHistoryStorage) restore(ctx context.Context) error {
+	data, err := s.client.Get(ctx, decisionHistoryStorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve decision history from storage: %s", err)
+	}
+	if data == nil {
+		s.logger.Info("Decision history not found in storage")
+		return nil
+	}
+
+	var snapshot decisionHistorySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to parse decision history: %s", err)
+	}
+
+	restored := 0
+	for _, entry := range snapshot.Entries {
+		var id traceKey
+		decoded, err := hex.DecodeString(entry.TraceID)
+		if err != nil || len(decoded) != len(id) {
+			s.logger.Debug("Skipping invalid trace ID in decision history", zap.String("trace_id", entry.TraceID))
+			continue
+		}
+		copy(id[:], decoded)
+
+		s.history.Add(id, decisionHistoryInfo{
+			finalDecision:       entry.FinalDecision,
+			filterName:          entry.FilterName,
+			probabilisticFilter: entry.ProbabilisticFilter,
+		})
+		restored++
+	}
+
+	s.logger.Info("Restored decision history from storage", zap.Int("entries", restored))
+	return nil
+}
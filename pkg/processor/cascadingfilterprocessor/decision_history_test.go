@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/extension/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+
+	cfconfig "github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/sampling"
+)
+
+func buildCFSPWithDecisionHistoryStorage(t *testing.T, storageID config.ComponentID) *cascadingFilterSpanProcessor {
+	id := config.NewComponentIDWithName("cascading_filter", "1")
+	ps := config.NewProcessorSettings(id)
+	cfg := cfconfig.Config{
+		ProcessorSettings:       &ps,
+		DecisionWait:            defaultTestDecisionWait,
+		NumTraces:               100,
+		ExpectedNewTracesPerSec: 64,
+		PolicyCfgs:              testPolicy,
+		DecisionHistoryStorage:  &storageID,
+	}
+	sp, err := newTraceProcessor(zap.NewNop(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	return sp.(*cascadingFilterSpanProcessor)
+}
+
+func TestDecisionHistoryIsPersisted(t *testing.T) {
+	ctx := context.Background()
+	storageDir := t.TempDir()
+	storageID := config.NewComponentIDWithName("nop", "test")
+
+	sampledID := traceKey{1}
+	droppedID := traceKey{2}
+
+	host := storagetest.NewStorageHost(t, storageDir, "test")
+	tsp := buildCFSPWithDecisionHistoryStorage(t, storageID)
+	require.NoError(t, tsp.Start(ctx, host))
+	tsp.decisionHistory.Add(sampledID, decisionHistoryInfo{
+		finalDecision:       sampling.Sampled,
+		filterName:          "test-policy",
+		probabilisticFilter: true,
+	})
+	tsp.decisionHistory.Add(droppedID, decisionHistoryInfo{finalDecision: sampling.Dropped})
+	require.NoError(t, tsp.Shutdown(ctx))
+	for _, extension := range host.GetExtensions() {
+		require.NoError(t, extension.Shutdown(ctx))
+	}
+
+	host = storagetest.NewStorageHost(t, storageDir, "test")
+	tsp = buildCFSPWithDecisionHistoryStorage(t, storageID)
+	require.NoError(t, tsp.Start(ctx, host))
+	defer func() {
+		require.NoError(t, tsp.Shutdown(ctx))
+		for _, extension := range host.GetExtensions() {
+			require.NoError(t, extension.Shutdown(ctx))
+		}
+	}()
+
+	assert.Equal(t, 2, tsp.decisionHistory.Len())
+	info, found := tsp.decisionHistory.Get(sampledID)
+	require.True(t, found)
+	assert.Equal(t, decisionHistoryInfo{
+		finalDecision:       sampling.Sampled,
+		filterName:          "test-policy",
+		probabilisticFilter: true,
+	}, info)
+	info, found = tsp.decisionHistory.Get(droppedID)
+	require.True(t, found)
+	assert.Equal(t, decisionHistoryInfo{finalDecision: sampling.Dropped}, info)
+}
+
+func TestDecisionHistoryPeriodicSnapshot(t *testing.T) {
+	ctx := context.Background()
+	storageID := config.NewComponentIDWithName("nop", "test")
+	host := storagetest.NewStorageHost(t, t.TempDir(), "test")
+
+	tsp := buildCFSPWithDecisionHistoryStorage(t, storageID)
+	tsp.decisionHistoryStorage.interval = 10 * time.Millisecond
+	require.NoError(t, tsp.Start(ctx, host))
+	defer func() {
+		require.NoError(t, tsp.Shutdown(ctx))
+		for _, extension := range host.GetExtensions() {
+			require.NoError(t, extension.Shutdown(ctx))
+		}
+	}()
+
+	tsp.decisionHistory.Add(traceKey{1}, decisionHistoryInfo{finalDecision: sampling.NotSampled})
+
+	assert.Eventually(t, func() bool {
+		data, err := tsp.decisionHistoryStorage.client.Get(ctx, decisionHistoryStorageKey)
+		return err == nil && data != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDecisionHistoryStorageNotFound(t *testing.T) {
+	tsp := buildCFSPWithDecisionHistoryStorage(t, config.NewComponentID("file_storage"))
+
+	err := tsp.Start(context.Background(), componenttest.NewNopHost())
+	assert.EqualError(t, err, "storage extension 'file_storage' not found")
+	assert.NoError(t, tsp.Shutdown(context.Background()))
+}
@@ -37,6 +37,8 @@ var (
 	tagCascadingFilterDecisionKey, _ = tag.NewKey("cascading_filter_decision")
 	tagPolicyDecisionKey, _          = tag.NewKey("policy_decision")
 	tagProcessorKey, _               = tag.NewKey("processor")
+	tagPartitionKey, _               = tag.NewKey("partition")
+	tagShadowKey, _                  = tag.NewKey("shadow")
 
 	statDecisionLatencyMicroSec  = stats.Int64("policy_decision_latency", "Latency (in microseconds) of a given filtering policy", "µs")
 	statOverallDecisionLatencyus = stats.Int64("cascading_filtering_batch_processing_latency", "Latency (in microseconds) of each run of the cascading filter timer", "µs")
@@ -45,6 +47,7 @@ var (
 
 	statCascadingFilterDecision = stats.Int64("count_final_decision", "Count of traces that were filtered or not", stats.UnitDimensionless)
 	statPolicyDecision          = stats.Int64("count_policy_decision", "Count of provisional (policy) decisions if traces were filtered or not", stats.UnitDimensionless)
+	statPolicyDecisionSpans     = stats.Int64("count_policy_decision_spans", "Count of spans in traces of provisional (policy) decisions", stats.UnitDimensionless)
 
 	statCascadingFilterDecidedSpans = stats.Int64("count_decided_spans", "Count of spans that were handled on decision time", stats.UnitDimensionless)
 	statCascadingFilterLateSpans    = stats.Int64("count_late_spans", "Count of spans that were handled in batches after the one where decision was made", stats.UnitDimensionless)
@@ -54,7 +57,7 @@ var (
 	statTracesOnMemoryGauge     = stats.Int64("cascading_traces_on_memory", "Tracks the number of traces current on memory", stats.UnitDimensionless)
 )
 
-func recordProvisionalDecisionMade(ctx context.Context, instanceName string, decisionKey string) {
+func recordProvisionalDecisionMade(ctx context.Context, instanceName string, decisionKey string, spans int32) {
 	//nolint:errcheck
 	_ = stats.RecordWithTags(
 		ctx,
@@ -62,18 +65,21 @@ func recordProvisionalDecisionMade(ctx context.Context, instanceName string, dec
 			tag.Insert(tagProcessorKey, instanceName),
 			tag.Insert(tagPolicyDecisionKey, decisionKey),
 		},
-		statPolicyDecision.M(int64(1)))
+		statPolicyDecision.M(int64(1)),
+		statPolicyDecisionSpans.M(int64(spans)))
 }
 
-func recordCascadingFilterDecision(ctx context.Context, instanceName string, decisionKey string) {
+func recordCascadingFilterDecision(ctx context.Context, instanceName string, decisionKey string, partition string) {
+	mutators := []tag.Mutator{
+		tag.Insert(tagProcessorKey, instanceName),
+		tag.Insert(tagPolicyDecisionKey, decisionKey),
+	}
+	if partition != "" {
+		mutators = append(mutators, tag.Insert(tagPartitionKey, partition))
+	}
+
 	//nolint:errcheck
-	_ = stats.RecordWithTags(
-		ctx,
-		[]tag.Mutator{
-			tag.Insert(tagProcessorKey, instanceName),
-			tag.Insert(tagPolicyDecisionKey, decisionKey),
-		},
-		statCascadingFilterDecision.M(int64(1)))
+	_ = stats.RecordWithTags(ctx, mutators, statCascadingFilterDecision.M(int64(1)))
 }
 
 func recordSpanLateDecision(ctx context.Context, instanceName string, decision string, count int) {
@@ -122,7 +128,7 @@ func CascadingFilterMetricViews(level configtelemetry.Level) []*view.View {
 		Name:        statCascadingFilterDecision.Name(),
 		Measure:     statCascadingFilterDecision,
 		Description: statCascadingFilterDecision.Description(),
-		TagKeys:     []tag.Key{tagProcessorKey, tagPolicyKey, tagCascadingFilterDecisionKey},
+		TagKeys:     []tag.Key{tagProcessorKey, tagPolicyKey, tagCascadingFilterDecisionKey, tagPartitionKey},
 		Aggregation: view.Sum(),
 	}
 
@@ -130,7 +136,15 @@ func CascadingFilterMetricViews(level configtelemetry.Level) []*view.View {
 		Name:        statPolicyDecision.Name(),
 		Measure:     statPolicyDecision,
 		Description: statPolicyDecision.Description(),
-		TagKeys:     []tag.Key{tagProcessorKey, tagPolicyKey, tagPolicyDecisionKey},
+		TagKeys:     []tag.Key{tagProcessorKey, tagPolicyKey, tagPolicyDecisionKey, tagShadowKey},
+		Aggregation: view.Sum(),
+	}
+
+	countPolicyDecisionSpansView := &view.View{
+		Name:        statPolicyDecisionSpans.Name(),
+		Measure:     statPolicyDecisionSpans,
+		Description: statPolicyDecisionSpans.Description(),
+		TagKeys:     []tag.Key{tagProcessorKey, tagPolicyKey, tagPolicyDecisionKey, tagShadowKey},
 		Aggregation: view.Sum(),
 	}
 
@@ -184,6 +198,7 @@ func CascadingFilterMetricViews(level configtelemetry.Level) []*view.View {
 		overallDecisionLatencyView,
 
 		countPolicyDecisionsView,
+		countPolicyDecisionSpansView,
 		policyLatencyView,
 		countFinalDecisionView,
 
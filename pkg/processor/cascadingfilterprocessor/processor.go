@@ -16,6 +16,8 @@ package cascadingfilterprocessor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -29,6 +31,7 @@ import (
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
@@ -73,18 +76,37 @@ type cascadingFilterSpanProcessor struct {
 	maxNumTraces     uint64
 	traceAcceptRules []*TraceAcceptEvaluator
 	traceRejectRules []*TraceRejectEvaluator
-	logger           *zap.Logger
-	idToTrace        sync.Map
-	policyTicker     tTicker
-	decisionBatcher  idbatcher.Batcher
-	decisionHistory  *lru.TwoQueueCache
-	deleteChan       chan traceKey
-	numTracesOnMap   uint64
+	// shadowTraceAcceptRules are evaluated alongside traceAcceptRules but do not affect the final decision
+	shadowTraceAcceptRules []*TraceAcceptEvaluator
+	shadowRuleAttribute    bool
+	logger                 *zap.Logger
+	idToTrace              sync.Map
+	policyTicker           tTicker
+	decisionBatcher        idbatcher.Batcher
+	decisionHistory        *lru.TwoQueueCache
+	deleteChan             chan traceKey
+	numTracesOnMap         uint64
+
+	// maxBufferedSpans and maxBufferedBytes limit the data buffered for traces awaiting the decision,
+	// zero means no limit
+	maxBufferedSpans int64
+	maxBufferedBytes int64
+	bufferedSpans    int64
+	bufferedBytes    int64
+	sizer            ptrace.Sizer
 
 	filteringEnabled bool
 
 	decisionSpansLimitter *rateLimiter
 	priorSpansLimitter    *rateLimiter
+
+	// traceIDFilter is the probabilistic filter used in the trace_id mode
+	traceIDFilter *sampling.TraceIDProbabilisticFilter
+
+	// decisionHistoryStorage persists the decision history, it's nil when no storage is configured
+	decisionHistoryStorage *decisionHistoryStorage
+	// redMetrics computes the metrics from all the spans, it's nil when not configured
+	redMetrics *redMetrics
 }
 
 type decisionHistoryInfo struct {
@@ -100,6 +122,7 @@ const (
 	AttributeSamplingRule         = "sampling.rule"
 	AttributeSamplingFilter       = "sampling.filter"
 	AttributeSamplingLateArrival  = "sampling.late_arrival"
+	AttributeSamplingShadowRule   = "sampling.shadow_rule"
 
 	AttributeSamplingProbability = "sampling.probability"
 )
@@ -148,6 +171,7 @@ func newCascadingFilterSpanProcessor(logger *zap.Logger, nextConsumer consumer.T
 	// Prepare Trace Accept config
 
 	var policyCfgs []config.TraceAcceptCfg
+	var shadowPolicies []*TraceAcceptEvaluator
 	totalRate := int32(0)
 
 	if len(cfg.TraceAcceptCfgs) > 0 {
@@ -175,6 +199,18 @@ func newCascadingFilterSpanProcessor(logger *zap.Logger, nextConsumer consumer.T
 			ctx:                 policyCtx,
 			probabilisticFilter: false,
 		}
+		if policyCfg.Shadow {
+			// Shadow policies have their own budgets, which are not a part of the total rate
+			policy.ctx, err = tag.New(policyCtx, tag.Upsert(tagShadowKey, "true"))
+			if err != nil {
+				return nil, err
+			}
+			logger.Info("Adding shadow trace accept rule",
+				zap.String("name", policyCfg.Name),
+				zap.Int32("spans_per_second", policyCfg.SpansPerSecond))
+			shadowPolicies = append(shadowPolicies, policy)
+			continue
+		}
 		if policyCfg.SpansPerSecond > 0 {
 			totalRate += policyCfg.SpansPerSecond
 		}
@@ -199,28 +235,67 @@ func newCascadingFilterSpanProcessor(logger *zap.Logger, nextConsumer consumer.T
 		logger.Info("Not setting total spans per second limit (only selected traces will be filtered out)")
 	}
 
+	if cfg.Burst > 0 {
+		if spansPerSecond <= 0 {
+			return nil, errors.New("burst requires the total spans per second limit")
+		}
+		if cfg.Burst < spansPerSecond {
+			return nil, errors.New("burst cannot be lower than the total spans per second limit")
+		}
+		logger.Info("Setting total spans burst", zap.Int32("burst", cfg.Burst))
+	}
+
 	// Setup probabilistic filtering - using either ratio or rate.
 	// This must be always evaluated first as it must select traces independently of other traceAcceptRules
 
 	probabilisticFilteringRate := int32(-1)
-
-	if cfg.ProbabilisticFilteringRatio != nil && *cfg.ProbabilisticFilteringRatio > 0.0 && spansPerSecond > 0 {
-		probabilisticFilteringRate = int32(float32(spansPerSecond) * *cfg.ProbabilisticFilteringRatio)
-	} else if cfg.ProbabilisticFilteringRate != nil && *cfg.ProbabilisticFilteringRate > 0 {
-		probabilisticFilteringRate = *cfg.ProbabilisticFilteringRate
+	var traceIDFilter *sampling.TraceIDProbabilisticFilter
+
+	switch cfg.ProbabilisticFilteringMode {
+	case "", config.RateProbabilisticFilteringMode:
+		if cfg.ProbabilisticFilteringRatio != nil && *cfg.ProbabilisticFilteringRatio > 0.0 && spansPerSecond > 0 {
+			probabilisticFilteringRate = int32(float32(spansPerSecond) * *cfg.ProbabilisticFilteringRatio)
+		} else if cfg.ProbabilisticFilteringRate != nil && *cfg.ProbabilisticFilteringRate > 0 {
+			probabilisticFilteringRate = *cfg.ProbabilisticFilteringRate
+		}
+	case config.TraceIDProbabilisticFilteringMode:
+		if cfg.ProbabilisticFilteringRate != nil || cfg.ProbabilisticFilteringRatio == nil {
+			return nil, errors.New("trace_id probabilistic filtering mode requires probabilistic_filtering_ratio and no probabilistic_filtering_rate")
+		}
+		if cfg.ProbabilisticFilteringPartition != nil {
+			return nil, errors.New("probabilistic_filtering_partition cannot be used in the trace_id probabilistic filtering mode")
+		}
+		traceIDFilter, err = sampling.NewTraceIDProbabilisticFilter(logger, float64(*cfg.ProbabilisticFilteringRatio))
+		if err != nil {
+			return nil, err
+		}
+		if ratio := float64(*cfg.ProbabilisticFilteringRatio); traceIDFilter.Probability() != ratio {
+			logger.Warn("Trace ID probabilistic filtering ratio rounded down to a power of two",
+				zap.Float64("ratio", ratio),
+				zap.Float64("probability", traceIDFilter.Probability()),
+			)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected probabilistic filtering mode: %s", cfg.ProbabilisticFilteringMode)
 	}
 
-	if probabilisticFilteringRate > 0 {
-		logger.Info("Setting probabilistic filtering rate", zap.Int32("probabilistic_filtering_rate", probabilisticFilteringRate))
+	if traceIDFilter != nil || probabilisticFilteringRate > 0 {
+		var eval sampling.PolicyEvaluator
+		if traceIDFilter != nil {
+			logger.Info("Setting trace ID probabilistic filtering", zap.Float64("probability", traceIDFilter.Probability()))
+			eval = traceIDFilter
+		} else {
+			logger.Info("Setting probabilistic filtering rate", zap.Int32("probabilistic_filtering_rate", probabilisticFilteringRate))
+			eval, err = buildProbabilisticFilterEvaluator(logger, probabilisticFilteringRate, cfg.ProbabilisticFilteringPartition)
+			if err != nil {
+				return nil, err
+			}
+		}
 
 		policyCtx, err := tag.New(ctx, tag.Upsert(tagPolicyKey, probabilisticFilterPolicyName))
 		if err != nil {
 			return nil, err
 		}
-		eval, err := buildProbabilisticFilterEvaluator(logger, probabilisticFilteringRate)
-		if err != nil {
-			return nil, err
-		}
 		policy := &TraceAcceptEvaluator{
 			Name:                probabilisticFilterPolicyName,
 			Evaluator:           eval,
@@ -257,18 +332,41 @@ func newCascadingFilterSpanProcessor(logger *zap.Logger, nextConsumer consumer.T
 
 	// Build the span processor
 	cfsp := &cascadingFilterSpanProcessor{
-		ctx:                   ctx,
-		nextConsumer:          nextConsumer,
-		instanceName:          cfg.ProcessorSettings.ID().String(),
-		maxNumTraces:          cfg.NumTraces,
-		decisionSpansLimitter: newRateLimitter(spansPerSecond),
-		priorSpansLimitter:    newRateLimitter(priorSpansRate),
-		logger:                logger,
-		decisionBatcher:       inBatcher,
-		decisionHistory:       cache,
-		traceAcceptRules:      policies,
-		traceRejectRules:      dropTraceEvals,
-		filteringEnabled:      len(policies) > 0 || len(dropTraceEvals) > 0,
+		ctx:                    ctx,
+		nextConsumer:           nextConsumer,
+		instanceName:           cfg.ProcessorSettings.ID().String(),
+		maxNumTraces:           cfg.NumTraces,
+		decisionSpansLimitter:  newBurstRateLimitter(spansPerSecond, cfg.Burst),
+		priorSpansLimitter:     newRateLimitter(priorSpansRate),
+		logger:                 logger,
+		decisionBatcher:        inBatcher,
+		decisionHistory:        cache,
+		traceAcceptRules:       policies,
+		traceRejectRules:       dropTraceEvals,
+		shadowTraceAcceptRules: shadowPolicies,
+		shadowRuleAttribute:    cfg.ShadowRuleAttribute,
+		filteringEnabled:       len(policies) > 0 || len(dropTraceEvals) > 0 || len(shadowPolicies) > 0,
+		traceIDFilter:          traceIDFilter,
+		maxBufferedSpans:       int64(cfg.MaxBufferedSpans),
+		maxBufferedBytes:       int64(cfg.MaxBufferedBytes),
+	}
+
+	if cfsp.maxBufferedBytes > 0 {
+		cfsp.sizer = ptrace.NewProtoMarshaler().(ptrace.Sizer)
+	}
+
+	if cfg.DecisionHistoryStorage != nil {
+		cfsp.decisionHistoryStorage = newDecisionHistoryStorage(
+			logger,
+			*cfg.DecisionHistoryStorage,
+			cfg.ProcessorSettings.ID(),
+			cfg.DecisionHistorySnapshotInterval,
+			cache,
+		)
+	}
+
+	if cfg.REDMetrics != nil {
+		cfsp.redMetrics = newREDMetrics(logger, cfg.REDMetrics)
 	}
 
 	cfsp.policyTicker = &policyTicker{onTick: cfsp.samplingPolicyOnTick}
@@ -281,8 +379,8 @@ func buildPolicyEvaluator(logger *zap.Logger, cfg *config.TraceAcceptCfg) (sampl
 	return sampling.NewFilter(logger, cfg)
 }
 
-func buildProbabilisticFilterEvaluator(logger *zap.Logger, maxSpanRate int32) (sampling.PolicyEvaluator, error) {
-	return sampling.NewProbabilisticFilter(logger, maxSpanRate)
+func buildProbabilisticFilterEvaluator(logger *zap.Logger, maxSpanRate int32, partition *config.PartitionCfg) (sampling.PolicyEvaluator, error) {
+	return sampling.NewProbabilisticFilter(logger, maxSpanRate, partition)
 }
 
 type policyMetrics struct {
@@ -297,6 +395,10 @@ func (cfsp *cascadingFilterSpanProcessor) samplingPolicyOnTick() {
 
 // ConsumeTraces is required by the SpanProcessor interface.
 func (cfsp *cascadingFilterSpanProcessor) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	if cfsp.redMetrics != nil {
+		cfsp.redMetrics.record(td)
+	}
+
 	if !cfsp.filteringEnabled {
 		return cfsp.nextConsumer.ConsumeTraces(ctx, td)
 	}
@@ -382,17 +484,46 @@ func (cfsp *cascadingFilterSpanProcessor) bufferTraces(id traceKey, res pcommon.
 
 		traceTd := prepareTraceBatch(res, spans)
 		actualData.ReceivedBatches = append(actualData.ReceivedBatches, traceTd)
+
+		actualData.BufferedSpans += int64(lenSpans)
+		atomic.AddInt64(&cfsp.bufferedSpans, int64(lenSpans))
+		if cfsp.sizer != nil {
+			size := int64(cfsp.sizer.TracesSize(traceTd))
+			actualData.BufferedBytes += size
+			atomic.AddInt64(&cfsp.bufferedBytes, size)
+		}
 	}
 
 	actualData.Unlock()
 
+	cfsp.evictBufferedTraces()
+
 	return newTraceIDs
 }
 
+// isBufferFull checks if the data buffered for traces awaiting the decision exceeds the configured limits
+func (cfsp *cascadingFilterSpanProcessor) isBufferFull() bool {
+	return (cfsp.maxBufferedSpans > 0 && atomic.LoadInt64(&cfsp.bufferedSpans) > cfsp.maxBufferedSpans) ||
+		(cfsp.maxBufferedBytes > 0 && atomic.LoadInt64(&cfsp.bufferedBytes) > cfsp.maxBufferedBytes)
+}
+
+// evictBufferedTraces drops the oldest traces until the buffered data fits within the configured limits.
+// The dropped traces are reported as dropped too early when their decision time comes.
+func (cfsp *cascadingFilterSpanProcessor) evictBufferedTraces() {
+	for cfsp.isBufferFull() {
+		select {
+		case traceKeyToDrop := <-cfsp.deleteChan:
+			cfsp.dropTrace(traceKeyToDrop)
+		default:
+			return
+		}
+	}
+}
+
 func (cfsp *cascadingFilterSpanProcessor) processTraces(ctx context.Context, resourceSpans ptrace.ResourceSpans) {
 	// Group spans per their traceId to minimize contention on idToTrace
 	idToSpans := cfsp.groupSpansByTraceKey(resourceSpans)
-	currTime := time.Now().Unix()
+	currTime := time.Now()
 
 	var newTraceIDs int64
 	for id, spans := range idToSpans {
@@ -409,6 +540,9 @@ func (cfsp *cascadingFilterSpanProcessor) processTraces(ctx context.Context, res
 				// Forward the spans to the policy destinations
 				traceTd := prepareTraceBatch(resourceSpans.Resource(), spans)
 				updateLateArrival(traceTd, info.filterName, info.probabilisticFilter)
+				if info.probabilisticFilter && cfsp.traceIDFilter != nil {
+					cfsp.traceIDFilter.UpdateTraceState(traceTd)
+				}
 				if err := cfsp.nextConsumer.ConsumeTraces(ctx, traceTd); err != nil {
 					cfsp.logger.Warn("Error sending late arrived spans to destination",
 						zap.Error(err))
@@ -444,13 +578,30 @@ func (cfsp *cascadingFilterSpanProcessor) Capabilities() consumer.Capabilities {
 }
 
 // Start is invoked during service startup.
-func (cfsp *cascadingFilterSpanProcessor) Start(context.Context, component.Host) error {
+func (cfsp *cascadingFilterSpanProcessor) Start(ctx context.Context, host component.Host) error {
+	if cfsp.decisionHistoryStorage != nil {
+		if err := cfsp.decisionHistoryStorage.start(ctx, host); err != nil {
+			return err
+		}
+	}
+	if cfsp.redMetrics != nil {
+		if err := cfsp.redMetrics.start(host); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // Shutdown is invoked during service shutdown.
-func (cfsp *cascadingFilterSpanProcessor) Shutdown(context.Context) error {
-	return nil
+func (cfsp *cascadingFilterSpanProcessor) Shutdown(ctx context.Context) error {
+	var errs error
+	if cfsp.redMetrics != nil {
+		errs = multierr.Append(errs, cfsp.redMetrics.shutdown(ctx))
+	}
+	if cfsp.decisionHistoryStorage != nil {
+		errs = multierr.Append(errs, cfsp.decisionHistoryStorage.shutdown(ctx))
+	}
+	return errs
 }
 
 func (cfsp *cascadingFilterSpanProcessor) dropTrace(traceID traceKey) {
@@ -465,6 +616,17 @@ func (cfsp *cascadingFilterSpanProcessor) dropTrace(traceID traceKey) {
 		cfsp.logger.Debug("Attempt to delete traceID not on table")
 		return
 	}
+
+	trace.Lock()
+	// No more spans are buffered for a trace which is no longer on the table
+	if trace.FinalDecision == sampling.Pending || trace.FinalDecision == sampling.Unspecified {
+		trace.FinalDecision = sampling.Dropped
+	}
+	atomic.AddInt64(&cfsp.bufferedSpans, -trace.BufferedSpans)
+	atomic.AddInt64(&cfsp.bufferedBytes, -trace.BufferedBytes)
+	trace.BufferedSpans = 0
+	trace.BufferedBytes = 0
+	trace.Unlock()
 }
 
 func prepareTraceBatch(res pcommon.Resource, spans []*ptrace.Span) ptrace.Traces {
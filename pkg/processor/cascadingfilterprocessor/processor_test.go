@@ -19,6 +19,7 @@ import (
 	"errors"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -181,6 +182,129 @@ func TestConcurrentTraceMapSize(t *testing.T) {
 	require.Equal(t, maxSize, cnt, "Incorrect traces count on idToTrace")
 }
 
+func TestBufferedSpansLimit(t *testing.T) {
+	traceIds, batches := generateIdsAndBatches(20)
+	id := config.NewComponentIDWithName("cascading_filter", "1")
+	ps := config.NewProcessorSettings(id)
+	cfg := cfconfig.Config{
+		ProcessorSettings:       &ps,
+		DecisionWait:            defaultTestDecisionWait,
+		NumTraces:               100,
+		MaxBufferedSpans:        50,
+		ExpectedNewTracesPerSec: 64,
+		PolicyCfgs:              testPolicy,
+	}
+	sp, err := newTraceProcessor(zap.NewNop(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	tsp := sp.(*cascadingFilterSpanProcessor)
+
+	for _, batch := range batches {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), batch))
+	}
+
+	// Trace with index i has i+1 spans, so only the two last traces (39 spans) fit within the limit
+	for i := 0; i < len(traceIds)-2; i++ {
+		_, ok := tsp.idToTrace.Load(traceKey(traceIds[i].Bytes()))
+		require.False(t, ok, "Found unexpected traceId[%d] still on map (id: %v)", i, traceIds[i])
+	}
+	for i := len(traceIds) - 2; i < len(traceIds); i++ {
+		d, ok := tsp.idToTrace.Load(traceKey(traceIds[i].Bytes()))
+		require.True(t, ok, "Missing traceId[%d] on map (id: %v)", i, traceIds[i])
+		assert.Len(t, d.(*sampling.TraceData).ReceivedBatches, i+1)
+	}
+	assert.Equal(t, int64(39), tsp.bufferedSpans)
+	assert.Equal(t, int64(0), tsp.bufferedBytes)
+}
+
+func TestBufferedBytesLimit(t *testing.T) {
+	traceIds, batches := generateIdsAndBatches(20)
+	spanSize := ptrace.NewProtoMarshaler().(ptrace.Sizer).TracesSize(batches[0])
+	id := config.NewComponentIDWithName("cascading_filter", "1")
+	ps := config.NewProcessorSettings(id)
+	cfg := cfconfig.Config{
+		ProcessorSettings:       &ps,
+		DecisionWait:            defaultTestDecisionWait,
+		NumTraces:               100,
+		MaxBufferedBytes:        uint64(30 * spanSize),
+		ExpectedNewTracesPerSec: 64,
+		PolicyCfgs:              testPolicy,
+	}
+	sp, err := newTraceProcessor(zap.NewNop(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	tsp := sp.(*cascadingFilterSpanProcessor)
+
+	for _, batch := range batches {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), batch))
+	}
+
+	_, ok := tsp.idToTrace.Load(traceKey(traceIds[0].Bytes()))
+	assert.False(t, ok)
+	_, ok = tsp.idToTrace.Load(traceKey(traceIds[len(traceIds)-1].Bytes()))
+	assert.True(t, ok)
+	assert.LessOrEqual(t, tsp.bufferedBytes, int64(30*spanSize))
+	assert.Greater(t, tsp.bufferedBytes, int64(0))
+
+	// Decided traces no longer count towards the limit
+	tsp.dropTrace(traceKey(traceIds[len(traceIds)-1].Bytes()))
+	assert.Less(t, tsp.bufferedBytes, int64(30*spanSize-19*spanSize))
+}
+
+// TestEvictionDuringDecision verifies that the traces can be evicted while the decision is made, run with -race
+func TestEvictionDuringDecision(t *testing.T) {
+	traceIds, batches := generateIdsAndBatches(20)
+	id := config.NewComponentIDWithName("cascading_filter", "1")
+	ps := config.NewProcessorSettings(id)
+	cfg := cfconfig.Config{
+		ProcessorSettings:       &ps,
+		DecisionWait:            defaultTestDecisionWait,
+		NumTraces:               100,
+		ExpectedNewTracesPerSec: 64,
+		SpansPerSecond:          1000,
+		PolicyCfgs:              testPolicy,
+	}
+	sp, err := newTraceProcessor(zap.NewNop(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	tsp := sp.(*cascadingFilterSpanProcessor)
+
+	// Every other trace (with an odd trace ID) is evicted by another goroutine while it's evaluated
+	evaluator := &evictingPolicyEvaluator{evict: make(chan pcommon.TraceID)}
+	tsp.traceAcceptRules = []*TraceAcceptEvaluator{{Name: "evicting-policy", Evaluator: evaluator, ctx: context.TODO()}}
+
+	for _, batch := range batches {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), batch))
+	}
+	traces := make([]*sampling.TraceData, 0, len(traceIds))
+	for _, traceID := range traceIds {
+		d, ok := tsp.idToTrace.Load(traceKey(traceID.Bytes()))
+		require.True(t, ok)
+		traces = append(traces, d.(*sampling.TraceData))
+	}
+
+	evicted := make(chan struct{})
+	go func() {
+		defer close(evicted)
+		for traceID := range evaluator.evict {
+			tsp.dropTrace(traceKey(traceID.Bytes()))
+		}
+	}()
+
+	batch := idbatcher.Batch(traceIds)
+	newCascade(tsp).decideOnBatch(&batch)
+	close(evaluator.evict)
+	<-evicted
+
+	for i, trace := range traces {
+		trace.Lock()
+		if i%2 == 0 {
+			assert.Equal(t, sampling.Dropped, trace.FinalDecision, "trace %d", i)
+		} else {
+			assert.Equal(t, sampling.Sampled, trace.FinalDecision, "trace %d", i)
+		}
+		trace.Unlock()
+	}
+	assert.Equal(t, int64(0), atomic.LoadInt64(&tsp.bufferedSpans))
+}
+
 func TestSamplingPolicyTypicalPath(t *testing.T) {
 	const maxSize = 100
 	const decisionWaitSeconds = 5
@@ -712,6 +836,20 @@ type mockPolicyEvaluator struct {
 	OnDroppedSpanCount int
 }
 
+// evictingPolicyEvaluator samples the traces and passes the ones with an odd trace ID to be evicted while they're evaluated
+type evictingPolicyEvaluator struct {
+	evict chan pcommon.TraceID
+}
+
+func (e *evictingPolicyEvaluator) Evaluate(traceID pcommon.TraceID, _ *sampling.TraceData) sampling.Decision {
+	if traceID.Bytes()[15]%2 == 1 {
+		e.evict <- traceID
+		// Give the eviction the time to complete before the decision is made
+		time.Sleep(10 * time.Millisecond)
+	}
+	return sampling.Sampled
+}
+
 type mockDropTrueEvaluator struct{}
 type mockDropFalseEvaluator struct{}
 
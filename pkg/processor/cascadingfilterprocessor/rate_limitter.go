@@ -14,12 +14,18 @@
 
 package cascadingfilterprocessor
 
-import "github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/sampling"
+import (
+	"time"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/sampling"
+)
 
 type rateLimiter struct {
 	currentSecond        int64
 	maxSpansPerSecond    int32
 	spansInCurrentSecond int32
+	// bucket replaces the per-second window when the burst is set
+	bucket *sampling.TokenBucket
 }
 
 func newRateLimitter(maxSpansPerSecond int32) *rateLimiter {
@@ -28,14 +34,32 @@ func newRateLimitter(maxSpansPerSecond int32) *rateLimiter {
 	}
 }
 
+// newBurstRateLimitter creates a rate limiter using a token bucket holding at most burst spans,
+// when burst is not set it's the same as newRateLimitter
+func newBurstRateLimitter(maxSpansPerSecond int32, burst int32) *rateLimiter {
+	rl := newRateLimitter(maxSpansPerSecond)
+	if burst > 0 && maxSpansPerSecond > 0 {
+		rl.bucket = sampling.NewTokenBucket(maxSpansPerSecond, burst)
+	}
+	return rl
+}
+
 // updateRate checks if given limit can still fit in the current limit
 // returns Sampled when it's the case and NoTSampled otherwise
-func (rl *rateLimiter) updateRate(currSecond int64, numSpans int32) sampling.Decision {
+func (rl *rateLimiter) updateRate(now time.Time, numSpans int32) sampling.Decision {
 	// No limit equals no bounds
 	if rl.maxSpansPerSecond <= 0 {
 		return sampling.Sampled
 	}
 
+	if rl.bucket != nil {
+		if rl.bucket.Take(now, numSpans) {
+			return sampling.Sampled
+		}
+		return sampling.NotSampled
+	}
+
+	currSecond := now.Unix()
 	if rl.currentSecond < currSecond {
 		rl.currentSecond = currSecond
 		rl.spansInCurrentSecond = 0
@@ -0,0 +1,336 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	collectorconfig "go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
+)
+
+const (
+	defaultREDMetricsFlushInterval = 15 * time.Second
+	defaultREDMetricsMaxSeries     = 1000
+
+	redMetricsScopeName    = "cascadingfilterprocessor"
+	redMetricsRequestsName = "calls_total"
+	redMetricsErrorsName   = "errors_total"
+	redMetricsLatencyName  = "latency"
+
+	redMetricsServiceNameKey = "service.name"
+	redMetricsOperationKey   = "operation"
+	redMetricsSpanKindKey    = "span.kind"
+
+	// redMetricsOverflowOperation is the operation of the series counting
+	// the spans above the max series limit
+	redMetricsOverflowOperation = "__overflow__"
+)
+
+var defaultLatencyHistogramBuckets = []time.Duration{
+	2 * time.Millisecond,
+	4 * time.Millisecond,
+	6 * time.Millisecond,
+	8 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	400 * time.Millisecond,
+	800 * time.Millisecond,
+	1 * time.Second,
+	1400 * time.Millisecond,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	15 * time.Second,
+}
+
+type redMetricsKey struct {
+	service   string
+	operation string
+	kind      ptrace.SpanKind
+}
+
+type redMetricsValue struct {
+	requests      int64
+	errors        int64
+	latencySum    float64
+	latencyCounts []uint64
+}
+
+// redMetrics computes the request, error and duration (RED) metrics from all the incoming spans, before
+// any filtering decision is made, and periodically sends them to a metrics exporter
+type redMetrics struct {
+	exporterID    collectorconfig.ComponentID
+	flushInterval time.Duration
+	// latencyBounds are the histogram bucket bounds in milliseconds
+	latencyBounds []float64
+	maxSeries     int
+	logger        *zap.Logger
+
+	exporter  consumer.Metrics
+	startTime pcommon.Timestamp
+
+	lock   sync.Mutex
+	values map[redMetricsKey]*redMetricsValue
+	// overflowed is set once the max series limit is reached
+	overflowed bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newREDMetrics(logger *zap.Logger, cfg *config.REDMetricsCfg) *redMetrics {
+	flushInterval := cfg.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultREDMetricsFlushInterval
+	}
+
+	buckets := cfg.LatencyHistogramBuckets
+	if len(buckets) == 0 {
+		buckets = defaultLatencyHistogramBuckets
+	}
+	latencyBounds := make([]float64, len(buckets))
+	for i, bucket := range buckets {
+		latencyBounds[i] = float64(bucket) / float64(time.Millisecond)
+	}
+	sort.Float64s(latencyBounds)
+
+	maxSeries := cfg.MaxSeries
+	if maxSeries <= 0 {
+		maxSeries = defaultREDMetricsMaxSeries
+	}
+
+	return &redMetrics{
+		exporterID:    cfg.MetricsExporter,
+		flushInterval: flushInterval,
+		latencyBounds: latencyBounds,
+		maxSeries:     maxSeries,
+		logger:        logger,
+		values:        make(map[redMetricsKey]*redMetricsValue),
+		done:          make(chan struct{}),
+	}
+}
+
+// start finds the metrics exporter and starts sending the metrics periodically
+func (rm *redMetrics) start(host component.Host) error {
+	exporter, ok := host.GetExporters()[collectorconfig.MetricsDataType][rm.exporterID]
+	if !ok {
+		return fmt.Errorf("metrics exporter '%s' not found, make sure it's a part of a metrics pipeline", rm.exporterID)
+	}
+	metricsExporter, ok := exporter.(component.MetricsExporter)
+	if !ok {
+		return fmt.Errorf("exporter '%s' is not a metrics exporter", rm.exporterID)
+	}
+
+	rm.exporter = metricsExporter
+	rm.startTime = pcommon.NewTimestampFromTime(time.Now())
+	rm.logger.Info("Sending RED metrics", zap.Any("metrics_exporter", rm.exporterID))
+
+	rm.wg.Add(1)
+	go rm.flushLoop()
+
+	return nil
+}
+
+// shutdown stops the periodic sending and sends the final metrics
+func (rm *redMetrics) shutdown(ctx context.Context) error {
+	if rm.exporter == nil {
+		return nil
+	}
+
+	close(rm.done)
+	rm.wg.Wait()
+
+	return rm.flush(ctx)
+}
+
+func (rm *redMetrics) flushLoop() {
+	defer rm.wg.Done()
+
+	ticker := time.NewTicker(rm.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := rm.flush(context.Background()); err != nil {
+				rm.logger.Warn("Failed to send RED metrics", zap.Error(err))
+			}
+		case <-rm.done:
+			return
+		}
+	}
+}
+
+// record updates the metrics with all the spans
+func (rm *redMetrics) record(td ptrace.Traces) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		var service string
+		if value, found := rs.Resource().Attributes().Get(redMetricsServiceNameKey); found {
+			service = value.AsString()
+		}
+
+		ss := rs.ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				value := rm.getValue(redMetricsKey{service: service, operation: span.Name(), kind: span.Kind()})
+
+				value.requests++
+				if span.Status().Code() == ptrace.StatusCodeError {
+					value.errors++
+				}
+
+				latency := float64(span.EndTimestamp()-span.StartTimestamp()) / float64(time.Millisecond)
+				value.latencySum += latency
+				value.latencyCounts[sort.SearchFloat64s(rm.latencyBounds, latency)]++
+			}
+		}
+	}
+}
+
+// getValue returns the metrics of the series, or of the overflow series if the series
+// is new and the max series limit is reached. It must be called with the lock held
+func (rm *redMetrics) getValue(key redMetricsKey) *redMetricsValue {
+	if value, found := rm.values[key]; found {
+		return value
+	}
+
+	if len(rm.values) >= rm.maxSeries {
+		if !rm.overflowed {
+			rm.overflowed = true
+			rm.logger.Warn("RED metrics max series limit reached, counting new series as overflow",
+				zap.Int("max_series", rm.maxSeries),
+				zap.String("operation", redMetricsOverflowOperation),
+			)
+		}
+		key = redMetricsKey{operation: redMetricsOverflowOperation}
+		if value, found := rm.values[key]; found {
+			return value
+		}
+	}
+
+	value := &redMetricsValue{latencyCounts: make([]uint64, len(rm.latencyBounds)+1)}
+	rm.values[key] = value
+	return value
+}
+
+// flush sends the cumulative metrics to the exporter
+func (rm *redMetrics) flush(ctx context.Context) error {
+	metrics := rm.buildMetrics(pcommon.NewTimestampFromTime(time.Now()))
+	if metrics.DataPointCount() == 0 {
+		return nil
+	}
+	return rm.exporter.ConsumeMetrics(ctx, metrics)
+}
+
+// serviceREDMetrics are the metrics of a single service, with a data point per operation and span kind
+type serviceREDMetrics struct {
+	requests pmetric.Metric
+	errors   pmetric.Metric
+	latency  pmetric.Metric
+}
+
+func (rm *redMetrics) buildMetrics(timestamp pcommon.Timestamp) pmetric.Metrics {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+
+	metrics := pmetric.NewMetrics()
+	metricsByService := make(map[string]serviceREDMetrics)
+
+	for key, value := range rm.values {
+		sm, found := metricsByService[key.service]
+		if !found {
+			sm = newServiceREDMetrics(metrics, key.service)
+			metricsByService[key.service] = sm
+		}
+
+		rm.appendSumDataPoint(sm.requests, key, value.requests, timestamp)
+		rm.appendSumDataPoint(sm.errors, key, value.errors, timestamp)
+
+		dp := sm.latency.Histogram().DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(rm.startTime)
+		dp.SetTimestamp(timestamp)
+		dp.SetCount(uint64(value.requests))
+		dp.SetSum(value.latencySum)
+		dp.SetBucketCounts(pcommon.NewImmutableUInt64Slice(value.latencyCounts))
+		dp.SetExplicitBounds(pcommon.NewImmutableFloat64Slice(rm.latencyBounds))
+		insertREDMetricsAttributes(dp.Attributes(), key)
+	}
+
+	return metrics
+}
+
+func newServiceREDMetrics(metrics pmetric.Metrics, service string) serviceREDMetrics {
+	rms := metrics.ResourceMetrics().AppendEmpty()
+	if service != "" {
+		rms.Resource().Attributes().UpsertString(redMetricsServiceNameKey, service)
+	}
+	sm := rms.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(redMetricsScopeName)
+
+	latency := sm.Metrics().AppendEmpty()
+	latency.SetName(redMetricsLatencyName)
+	latency.SetUnit("ms")
+	latency.SetDataType(pmetric.MetricDataTypeHistogram)
+	latency.Histogram().SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+
+	return serviceREDMetrics{
+		requests: appendSum(sm.Metrics(), redMetricsRequestsName),
+		errors:   appendSum(sm.Metrics(), redMetricsErrorsName),
+		latency:  latency,
+	}
+}
+
+func appendSum(metrics pmetric.MetricSlice, name string) pmetric.Metric {
+	metric := metrics.AppendEmpty()
+	metric.SetName(name)
+	metric.SetDataType(pmetric.MetricDataTypeSum)
+	metric.Sum().SetIsMonotonic(true)
+	metric.Sum().SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+	return metric
+}
+
+func (rm *redMetrics) appendSumDataPoint(metric pmetric.Metric, key redMetricsKey, count int64, timestamp pcommon.Timestamp) {
+	dp := metric.Sum().DataPoints().AppendEmpty()
+	dp.SetStartTimestamp(rm.startTime)
+	dp.SetTimestamp(timestamp)
+	dp.SetIntVal(count)
+	insertREDMetricsAttributes(dp.Attributes(), key)
+}
+
+func insertREDMetricsAttributes(attrs pcommon.Map, key redMetricsKey) {
+	attrs.UpsertString(redMetricsOperationKey, key.operation)
+	attrs.UpsertString(redMetricsSpanKindKey, key.kind.String())
+}
@@ -0,0 +1,216 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	cfconfig "github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
+)
+
+type sinkMetricsExporter struct {
+	component.StartFunc
+	component.ShutdownFunc
+	*consumertest.MetricsSink
+}
+
+type exportersHost struct {
+	component.Host
+	exporters map[config.DataType]map[config.ComponentID]component.Exporter
+}
+
+func (h exportersHost) GetExporters() map[config.DataType]map[config.ComponentID]component.Exporter {
+	return h.exporters
+}
+
+func newREDMetricsHost(id config.ComponentID, sink *consumertest.MetricsSink) component.Host {
+	return exportersHost{
+		Host: componenttest.NewNopHost(),
+		exporters: map[config.DataType]map[config.ComponentID]component.Exporter{
+			config.MetricsDataType: {id: sinkMetricsExporter{MetricsSink: sink}},
+		},
+	}
+}
+
+func appendREDTestSpan(rs ptrace.ResourceSpans, name string, duration time.Duration, isError bool) {
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetName(name)
+	span.SetKind(ptrace.SpanKindServer)
+	span.SetStartTimestamp(pcommon.Timestamp(1000))
+	span.SetEndTimestamp(pcommon.Timestamp(1000 + duration.Nanoseconds()))
+	if isError {
+		span.Status().SetCode(ptrace.StatusCodeError)
+	}
+}
+
+func findREDMetricsDataPoints(t *testing.T, metrics pmetric.Metrics, service string, name string) pmetric.Metric {
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		value, _ := rms.At(i).Resource().Attributes().Get("service.name")
+		if value.StringVal() != service {
+			continue
+		}
+		ms := rms.At(i).ScopeMetrics().At(0).Metrics()
+		for j := 0; j < ms.Len(); j++ {
+			if ms.At(j).Name() == name {
+				return ms.At(j)
+			}
+		}
+	}
+	require.Failf(t, "metric not found", "service: %s, metric: %s", service, name)
+	return pmetric.Metric{}
+}
+
+func findREDMetricsOperation(t *testing.T, dps pmetric.NumberDataPointSlice, operation string) pmetric.NumberDataPoint {
+	for i := 0; i < dps.Len(); i++ {
+		if value, _ := dps.At(i).Attributes().Get("operation"); value.StringVal() == operation {
+			return dps.At(i)
+		}
+	}
+	require.Failf(t, "data point not found", "operation: %s", operation)
+	return pmetric.NumberDataPoint{}
+}
+
+func TestREDMetrics(t *testing.T) {
+	ctx := context.Background()
+	exporterID := config.NewComponentIDWithName("sumologic", "metrics")
+	sink := new(consumertest.MetricsSink)
+
+	id := config.NewComponentIDWithName("cascading_filter", "1")
+	ps := config.NewProcessorSettings(id)
+	cfg := cfconfig.Config{
+		ProcessorSettings:       &ps,
+		DecisionWait:            defaultTestDecisionWait,
+		NumTraces:               100,
+		ExpectedNewTracesPerSec: 64,
+		PolicyCfgs:              testPolicy,
+		REDMetrics: &cfconfig.REDMetricsCfg{
+			MetricsExporter:         exporterID,
+			FlushInterval:           time.Hour,
+			LatencyHistogramBuckets: []time.Duration{10 * time.Millisecond, 100 * time.Millisecond},
+		},
+	}
+	sp, err := newTraceProcessor(zap.NewNop(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, sp.Start(ctx, newREDMetricsHost(exporterID, sink)))
+
+	traces := simpleTracesWithID(pcommon.NewTraceID([16]byte{1}))
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("service.name", "foo")
+	appendREDTestSpan(rs, "GET /", 5*time.Millisecond, false)
+	appendREDTestSpan(rs, "GET /", 50*time.Millisecond, true)
+	appendREDTestSpan(rs, "GET /", 500*time.Millisecond, false)
+	appendREDTestSpan(rs, "POST /", 5*time.Millisecond, false)
+	require.NoError(t, sp.ConsumeTraces(ctx, traces))
+
+	require.NoError(t, sp.Shutdown(ctx))
+	require.Len(t, sink.AllMetrics(), 1)
+	metrics := sink.AllMetrics()[0]
+
+	// Each metric is sent once per service, with a data point per operation and span kind
+	ms := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	assert.Equal(t, 3, ms.Len())
+
+	requests := findREDMetricsDataPoints(t, metrics, "foo", "calls_total").Sum()
+	require.Equal(t, 2, requests.DataPoints().Len())
+	assert.True(t, requests.IsMonotonic())
+	requestsDP := findREDMetricsOperation(t, requests.DataPoints(), "GET /")
+	assert.Equal(t, int64(3), requestsDP.IntVal())
+	kind, _ := requestsDP.Attributes().Get("span.kind")
+	assert.Equal(t, ptrace.SpanKindServer.String(), kind.StringVal())
+	assert.Equal(t, int64(1), findREDMetricsOperation(t, requests.DataPoints(), "POST /").IntVal())
+
+	errors := findREDMetricsDataPoints(t, metrics, "foo", "errors_total").Sum()
+	require.Equal(t, 2, errors.DataPoints().Len())
+	assert.Equal(t, int64(1), findREDMetricsOperation(t, errors.DataPoints(), "GET /").IntVal())
+
+	latency := findREDMetricsDataPoints(t, metrics, "foo", "latency").Histogram()
+	require.Equal(t, 2, latency.DataPoints().Len())
+	var latencyDP pmetric.HistogramDataPoint
+	for i := 0; i < latency.DataPoints().Len(); i++ {
+		if operation, _ := latency.DataPoints().At(i).Attributes().Get("operation"); operation.StringVal() == "GET /" {
+			latencyDP = latency.DataPoints().At(i)
+		}
+	}
+	assert.Equal(t, uint64(3), latencyDP.Count())
+	assert.Equal(t, 555.0, latencyDP.Sum())
+	assert.Equal(t, []float64{10, 100}, latencyDP.ExplicitBounds().AsRaw())
+	assert.Equal(t, []uint64{1, 1, 1}, latencyDP.BucketCounts().AsRaw())
+
+	// The span without a service name is also counted
+	requests = findREDMetricsDataPoints(t, metrics, "", "calls_total").Sum()
+	assert.Equal(t, int64(1), requests.DataPoints().At(0).IntVal())
+}
+
+func TestREDMetricsExporterNotFound(t *testing.T) {
+	id := config.NewComponentIDWithName("cascading_filter", "1")
+	ps := config.NewProcessorSettings(id)
+	cfg := cfconfig.Config{
+		ProcessorSettings: &ps,
+		DecisionWait:      defaultTestDecisionWait,
+		NumTraces:         100,
+		REDMetrics: &cfconfig.REDMetricsCfg{
+			MetricsExporter: config.NewComponentID("sumologic"),
+		},
+	}
+	sp, err := newTraceProcessor(zap.NewNop(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+
+	err = sp.Start(context.Background(), componenttest.NewNopHost())
+	assert.EqualError(t, err, "metrics exporter 'sumologic' not found, make sure it's a part of a metrics pipeline")
+	assert.NoError(t, sp.Shutdown(context.Background()))
+}
+
+func TestREDMetricsMaxSeries(t *testing.T) {
+	rm := newREDMetrics(zap.NewNop(), &cfconfig.REDMetricsCfg{MaxSeries: 2})
+
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("service.name", "foo")
+	appendREDTestSpan(rs, "GET /1", time.Millisecond, false)
+	appendREDTestSpan(rs, "GET /2", time.Millisecond, false)
+	appendREDTestSpan(rs, "GET /3", time.Millisecond, true)
+	appendREDTestSpan(rs, "GET /4", time.Millisecond, false)
+	appendREDTestSpan(rs, "GET /1", time.Millisecond, false)
+	rm.record(traces)
+
+	// The spans of the new series above the limit are counted in the overflow series
+	assert.Len(t, rm.values, 3)
+	metrics := rm.buildMetrics(pcommon.NewTimestampFromTime(time.Now()))
+
+	requests := findREDMetricsDataPoints(t, metrics, "foo", "calls_total").Sum()
+	require.Equal(t, 2, requests.DataPoints().Len())
+	assert.Equal(t, int64(2), findREDMetricsOperation(t, requests.DataPoints(), "GET /1").IntVal())
+	assert.Equal(t, int64(1), findREDMetricsOperation(t, requests.DataPoints(), "GET /2").IntVal())
+
+	overflowRequests := findREDMetricsDataPoints(t, metrics, "", "calls_total").Sum()
+	require.Equal(t, 1, overflowRequests.DataPoints().Len())
+	assert.Equal(t, int64(2), findREDMetricsOperation(t, overflowRequests.DataPoints(), "__overflow__").IntVal())
+	overflowErrors := findREDMetricsDataPoints(t, metrics, "", "errors_total").Sum()
+	assert.Equal(t, int64(1), findREDMetricsOperation(t, overflowErrors.DataPoints(), "__overflow__").IntVal())
+}
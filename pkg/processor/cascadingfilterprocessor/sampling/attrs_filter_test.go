@@ -20,9 +20,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
 )
 
 func newAttrsFilter(filters []attributeFilter) policyEvaluator {
@@ -133,6 +137,56 @@ func TestAttributesFilter(t *testing.T) {
 	}
 }
 
+func TestAttributesFilterScope(t *testing.T) {
+	resourceTraces, _ := newTrace()
+	resourceTraces.ReceivedBatches[0].ResourceSpans().At(0).Resource().Attributes().InsertString("foo", "foobar")
+
+	spanTraces, spanAttrs := newTrace()
+	spanAttrs.InsertString("foo", "foobar")
+
+	cases := []struct {
+		Desc      string
+		Scope     config.AttributeScope
+		Match     []*TraceData
+		DontMatch []*TraceData
+	}{
+		{
+			Desc:  "resource and span",
+			Match: []*TraceData{resourceTraces, spanTraces},
+		},
+		{
+			Desc:      "resource",
+			Scope:     config.AttributeScopeResource,
+			Match:     []*TraceData{resourceTraces},
+			DontMatch: []*TraceData{spanTraces},
+		},
+		{
+			Desc:      "span",
+			Scope:     config.AttributeScopeSpan,
+			Match:     []*TraceData{spanTraces},
+			DontMatch: []*TraceData{resourceTraces},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			filter, err := createAttributeFilter(config.AttributeCfg{Key: "foo", Values: []string{"foobar"}, Scope: c.Scope})
+			require.NoError(t, err)
+			evaluator := newAttrsFilter([]attributeFilter{*filter})
+
+			for _, traces := range c.Match {
+				evaluate(t, evaluator, traces, Sampled)
+			}
+			for _, traces := range c.DontMatch {
+				evaluate(t, evaluator, traces, NotSampled)
+			}
+		})
+	}
+
+	_, err := createAttributeFilter(config.AttributeCfg{Key: "foo", Scope: "scope"})
+	assert.EqualError(t, err, `invalid attribute scope: "scope"`)
+}
+
 func newTrace() (*TraceData, pcommon.Map) {
 	endTs := time.Now().UnixNano()
 	startTs := endTs - 100000
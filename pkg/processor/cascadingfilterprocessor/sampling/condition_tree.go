@@ -0,0 +1,182 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
+)
+
+// conditionNode is a node of the condition tree, it either combines other nodes
+// or matches the trace using the rules evaluator
+type conditionNode struct {
+	and   []*conditionNode
+	or    []*conditionNode
+	not   *conditionNode
+	rules *policyEvaluator
+}
+
+// conditionTreeEvaluator is a policy evaluator which samples the traces meeting the condition tree,
+// within a single spans per second budget
+type conditionTreeEvaluator struct {
+	root   *conditionNode
+	budget *policyEvaluator
+
+	invertMatch bool
+}
+
+var _ PolicyEvaluator = (*conditionTreeEvaluator)(nil)
+
+// conditionTreeDropEvaluator is a drop trace evaluator which drops the traces meeting the condition tree
+type conditionTreeDropEvaluator struct {
+	root *conditionNode
+}
+
+var _ DropTraceEvaluator = (*conditionTreeDropEvaluator)(nil)
+
+func newConditionNode(logger *zap.Logger, cfg *config.ConditionCfg) (*conditionNode, error) {
+	hasRules := hasMatchingOptions(cfg.NumericAttributeCfg, cfg.StringAttributeCfg, cfg.AttributeCfg, cfg.PropertiesCfg)
+
+	kinds := 0
+	for _, set := range []bool{len(cfg.And) > 0, len(cfg.Or) > 0, cfg.Not != nil, hasRules} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, errors.New("condition must specify exactly one of and, or, not or matching options")
+	}
+
+	node := &conditionNode{}
+	switch {
+	case len(cfg.And) > 0:
+		for i := range cfg.And {
+			child, err := newConditionNode(logger, &cfg.And[i])
+			if err != nil {
+				return nil, err
+			}
+			node.and = append(node.and, child)
+		}
+	case len(cfg.Or) > 0:
+		for i := range cfg.Or {
+			child, err := newConditionNode(logger, &cfg.Or[i])
+			if err != nil {
+				return nil, err
+			}
+			node.or = append(node.or, child)
+		}
+	case cfg.Not != nil:
+		child, err := newConditionNode(logger, cfg.Not)
+		if err != nil {
+			return nil, err
+		}
+		node.not = child
+	default:
+		rules, err := newRulesEvaluator(logger, cfg.NumericAttributeCfg, cfg.StringAttributeCfg, cfg.AttributeCfg, cfg.PropertiesCfg)
+		if err != nil {
+			return nil, err
+		}
+		node.rules = rules
+	}
+
+	return node, nil
+}
+
+// matches checks if the trace meets the condition
+func (cn *conditionNode) matches(traceID pcommon.TraceID, trace *TraceData) bool {
+	switch {
+	case len(cn.and) > 0:
+		for _, child := range cn.and {
+			if !child.matches(traceID, trace) {
+				return false
+			}
+		}
+		return true
+	case len(cn.or) > 0:
+		for _, child := range cn.or {
+			if child.matches(traceID, trace) {
+				return true
+			}
+		}
+		return false
+	case cn.not != nil:
+		return !cn.not.matches(traceID, trace)
+	default:
+		return cn.rules.evaluateRules(traceID, trace) == Sampled
+	}
+}
+
+func newConditionTreeFilter(logger *zap.Logger, cfg *config.TraceAcceptCfg) (PolicyEvaluator, error) {
+	root, err := newConditionNode(logger, cfg.Condition)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := &policyEvaluator{
+		logger:            logger,
+		maxSpansPerSecond: cfg.SpansPerSecond,
+	}
+	if err := budget.setBurst(cfg.Burst); err != nil {
+		return nil, err
+	}
+	if err := budget.setPartition(cfg.Partition); err != nil {
+		return nil, err
+	}
+
+	return &conditionTreeEvaluator{
+		root:        root,
+		budget:      budget,
+		invertMatch: cfg.InvertMatch,
+	}, nil
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision. Also takes into account
+// the usage of sampling rate budget
+func (cte *conditionTreeEvaluator) Evaluate(traceID pcommon.TraceID, trace *TraceData) Decision {
+	now := time.Now()
+
+	if !cte.budget.shouldConsider(now, trace) {
+		return NotSampled
+	}
+
+	if cte.root.matches(traceID, trace) == cte.invertMatch {
+		return NotSampled
+	}
+
+	if cte.budget.emitsSecondChance() {
+		return SecondChance
+	}
+
+	return cte.budget.updateTraceRate(now, trace)
+}
+
+func newConditionTreeDropEvaluator(logger *zap.Logger, cfg *config.ConditionCfg) (DropTraceEvaluator, error) {
+	root, err := newConditionNode(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conditionTreeDropEvaluator{root: root}, nil
+}
+
+// ShouldDrop checks if trace should be dropped
+func (ctde *conditionTreeDropEvaluator) ShouldDrop(traceID pcommon.TraceID, trace *TraceData) bool {
+	return ctde.root.matches(traceID, trace)
+}
@@ -0,0 +1,174 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
+)
+
+func newServiceTrace(service string, duration time.Duration, numberOfErrors int) *TraceData {
+	trace := newTraceAttrs("foobar", duration, 2, numberOfErrors)
+	trace.ReceivedBatches[0].ResourceSpans().At(0).Resource().Attributes().InsertString("service.name", service)
+	return trace
+}
+
+func serviceCondition(service string, properties config.PropertiesCfg) config.ConditionCfg {
+	return config.ConditionCfg{
+		And: []config.ConditionCfg{
+			{StringAttributeCfg: &config.StringAttributeCfg{Key: "service.name", Values: []string{service}}},
+			{PropertiesCfg: properties},
+		},
+	}
+}
+
+func TestConditionTreeFilter(t *testing.T) {
+	oneError := 1
+	twoSeconds := 2 * time.Second
+
+	// errors in service A or latency over 2s in service B
+	errorsOrLatency := config.ConditionCfg{
+		Or: []config.ConditionCfg{
+			serviceCondition("A", config.PropertiesCfg{MinNumberOfErrors: &oneError}),
+			serviceCondition("B", config.PropertiesCfg{MinDuration: &twoSeconds}),
+		},
+	}
+	notServiceA := config.ConditionCfg{
+		Not: &config.ConditionCfg{
+			StringAttributeCfg: &config.StringAttributeCfg{Key: "service.name", Values: []string{"A"}},
+		},
+	}
+
+	cases := []struct {
+		Desc      string
+		Condition config.ConditionCfg
+		Match     []*TraceData
+		DontMatch []*TraceData
+	}{
+		{
+			Desc:      "or of and conditions",
+			Condition: errorsOrLatency,
+			Match:     []*TraceData{newServiceTrace("A", time.Second, 1), newServiceTrace("B", 3*time.Second, 0)},
+			DontMatch: []*TraceData{newServiceTrace("A", 3*time.Second, 0), newServiceTrace("B", time.Second, 1), newServiceTrace("C", 3*time.Second, 1)},
+		},
+		{
+			Desc:      "not condition",
+			Condition: notServiceA,
+			Match:     []*TraceData{newServiceTrace("B", time.Second, 0)},
+			DontMatch: []*TraceData{newServiceTrace("A", time.Second, 0)},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			condition := c.Condition
+			evaluator, err := NewFilter(zap.NewNop(), &config.TraceAcceptCfg{
+				SpansPerSecond: math.MaxInt32,
+				Condition:      &condition,
+			})
+			require.NoError(t, err)
+
+			dropEvaluator, err := NewDropTraceEvaluator(zap.NewNop(), config.TraceRejectCfg{Condition: &condition})
+			require.NoError(t, err)
+
+			u, err := uuid.NewRandom()
+			require.NoError(t, err)
+			traceID := pcommon.NewTraceID(u)
+
+			for _, trace := range c.Match {
+				assert.Equal(t, Sampled, evaluator.Evaluate(traceID, trace))
+				assert.True(t, dropEvaluator.ShouldDrop(traceID, trace))
+			}
+			for _, trace := range c.DontMatch {
+				assert.Equal(t, NotSampled, evaluator.Evaluate(traceID, trace))
+				assert.False(t, dropEvaluator.ShouldDrop(traceID, trace))
+			}
+		})
+	}
+}
+
+func TestConditionTreeFilterInvalid(t *testing.T) {
+	namePattern := "foo.*"
+
+	cases := []struct {
+		Desc      string
+		Cfg       config.TraceAcceptCfg
+		ErrorText string
+	}{
+		{
+			Desc:      "empty condition",
+			Cfg:       config.TraceAcceptCfg{Condition: &config.ConditionCfg{}},
+			ErrorText: "condition must specify exactly one of and, or, not or matching options",
+		},
+		{
+			Desc: "and combined with matching options",
+			Cfg: config.TraceAcceptCfg{Condition: &config.ConditionCfg{
+				And:           []config.ConditionCfg{{PropertiesCfg: config.PropertiesCfg{NamePattern: &namePattern}}},
+				PropertiesCfg: config.PropertiesCfg{NamePattern: &namePattern},
+			}},
+			ErrorText: "condition must specify exactly one of and, or, not or matching options",
+		},
+		{
+			Desc: "condition combined with policy matching options",
+			Cfg: config.TraceAcceptCfg{
+				PropertiesCfg: config.PropertiesCfg{NamePattern: &namePattern},
+				Condition:     &config.ConditionCfg{PropertiesCfg: config.PropertiesCfg{NamePattern: &namePattern}},
+			},
+			ErrorText: "condition cannot be used together with other matching options",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			_, err := NewFilter(zap.NewNop(), &c.Cfg)
+			assert.EqualError(t, err, c.ErrorText)
+		})
+	}
+}
+
+func TestConditionTreeDropInvalid(t *testing.T) {
+	namePattern := "foo.*"
+	condition := &config.ConditionCfg{PropertiesCfg: config.PropertiesCfg{NamePattern: &namePattern}}
+
+	cases := []struct {
+		Desc string
+		Cfg  config.TraceRejectCfg
+	}{
+		{
+			Desc: "condition combined with name pattern",
+			Cfg:  config.TraceRejectCfg{NamePattern: &namePattern, Condition: condition},
+		},
+		{
+			Desc: "condition combined with root span only",
+			Cfg:  config.TraceRejectCfg{RootSpanOnly: true, Condition: condition},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			_, err := NewDropTraceEvaluator(zap.NewNop(), c.Cfg)
+			assert.EqualError(t, err, "condition cannot be used together with other matching options")
+		})
+	}
+}
@@ -15,9 +15,11 @@
 package sampling
 
 import (
+	"errors"
 	"regexp"
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
 
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
@@ -29,6 +31,13 @@ type dropTraceEvaluator struct {
 	attrs       []attributeFilter
 	operationRe *regexp.Regexp
 
+	spanKinds       map[ptrace.SpanKind]struct{}
+	statusMessageRe *regexp.Regexp
+	rootSpanOnly    bool
+
+	// properties evaluates the properties filter, the same way as for the trace accept filters
+	properties *policyEvaluator
+
 	logger *zap.Logger
 }
 
@@ -36,6 +45,20 @@ var _ DropTraceEvaluator = (*dropTraceEvaluator)(nil)
 
 // NewDropTraceEvaluator creates a drop trace evaluator that checks if trace should be dropped
 func NewDropTraceEvaluator(logger *zap.Logger, cfg config.TraceRejectCfg) (DropTraceEvaluator, error) {
+	if cfg.Condition != nil {
+		if cfg.NumericAttributeCfg != nil || cfg.StringAttributeCfg != nil || len(cfg.AttributeCfg) > 0 ||
+			cfg.NamePattern != nil || len(cfg.SpanKinds) > 0 || cfg.StatusMessagePattern != nil || cfg.RootSpanOnly ||
+			hasPropertiesCfg(cfg.PropertiesCfg) {
+			return nil, errors.New("condition cannot be used together with other matching options")
+		}
+		return newConditionTreeDropEvaluator(logger, cfg.Condition)
+	}
+
+	// The span criteria are set at the filter level, so that they have a single location.
+	if p := cfg.PropertiesCfg; p.NamePattern != nil || len(p.SpanKinds) > 0 || p.StatusMessagePattern != nil || p.RootSpanOnly {
+		return nil, errors.New("name_pattern, span_kinds, status_message_pattern and root_span_only have to be set at the filter level, not in properties")
+	}
+
 	numericAttrFilter := createNumericAttributeFilter(cfg.NumericAttributeCfg)
 	stringAttrFilter, err := createStringAttributeFilter(cfg.StringAttributeCfg)
 	if err != nil {
@@ -46,10 +69,22 @@ func NewDropTraceEvaluator(logger *zap.Logger, cfg config.TraceRejectCfg) (DropT
 		return nil, err
 	}
 
-	var operationRe *regexp.Regexp
+	operationRe, err := createPattern(cfg.NamePattern)
+	if err != nil {
+		return nil, err
+	}
+	statusMessageRe, err := createPattern(cfg.StatusMessagePattern)
+	if err != nil {
+		return nil, err
+	}
+	spanKinds, err := createSpanKinds(cfg.SpanKinds)
+	if err != nil {
+		return nil, err
+	}
 
-	if cfg.NamePattern != nil {
-		operationRe, err = regexp.Compile(*cfg.NamePattern)
+	var properties *policyEvaluator
+	if hasPropertiesCfg(cfg.PropertiesCfg) {
+		properties, err = newRulesEvaluator(logger, nil, nil, nil, cfg.PropertiesCfg)
 		if err != nil {
 			return nil, err
 		}
@@ -60,12 +95,23 @@ func NewDropTraceEvaluator(logger *zap.Logger, cfg config.TraceRejectCfg) (DropT
 		numericAttr: numericAttrFilter,
 		attrs:       attrsFilter,
 		operationRe: operationRe,
-		logger:      logger,
+
+		spanKinds:       spanKinds,
+		statusMessageRe: statusMessageRe,
+		rootSpanOnly:    cfg.RootSpanOnly,
+
+		properties: properties,
+
+		logger: logger,
 	}, nil
 }
 
 // ShouldDrop checks if trace should be dropped
-func (dte *dropTraceEvaluator) ShouldDrop(_ pcommon.TraceID, trace *TraceData) bool {
+func (dte *dropTraceEvaluator) ShouldDrop(traceID pcommon.TraceID, trace *TraceData) bool {
+	if dte.properties != nil && dte.properties.evaluateRules(traceID, trace) != Sampled {
+		return false
+	}
+
 	trace.Lock()
 	batches := trace.ReceivedBatches
 	trace.Unlock()
@@ -74,6 +120,8 @@ func (dte *dropTraceEvaluator) ShouldDrop(_ pcommon.TraceID, trace *TraceData) b
 	matchingStringAttrFound := false
 	matchingNumericAttrFound := false
 	matchingAttrsFound := false
+	matchingSpanKindFound := false
+	matchingStatusMessageFound := false
 
 	for _, batch := range batches {
 		rs := batch.ResourceSpans()
@@ -94,6 +142,10 @@ func (dte *dropTraceEvaluator) ShouldDrop(_ pcommon.TraceID, trace *TraceData) b
 				for k := 0; k < spans.Len(); k++ {
 					span := spans.At(k)
 
+					if dte.rootSpanOnly && !isRootSpan(span) {
+						continue
+					}
+
 					if !matchingAttrsFound && len(dte.attrs) > 0 {
 						matchingAttrsFound = checkIfAttrsMatched(res.Attributes(), span.Attributes(), dte.attrs)
 					}
@@ -109,18 +161,26 @@ func (dte *dropTraceEvaluator) ShouldDrop(_ pcommon.TraceID, trace *TraceData) b
 							matchingOperationFound = true
 						}
 					}
+					if dte.spanKinds != nil && !matchingSpanKindFound {
+						matchingSpanKindFound = checkIfSpanKindMatched(span, dte.spanKinds)
+					}
+					if dte.statusMessageRe != nil && !matchingStatusMessageFound {
+						matchingStatusMessageFound = dte.statusMessageRe.MatchString(span.Status().Message())
+					}
 				}
 			}
 		}
 	}
 
 	conditionMet := struct {
-		operationName, stringAttr, numericAttr, attrs bool
+		operationName, stringAttr, numericAttr, attrs, spanKind, statusMessage bool
 	}{
 		operationName: true,
 		stringAttr:    true,
 		numericAttr:   true,
 		attrs:         true,
+		spanKind:      true,
+		statusMessage: true,
 	}
 
 	if dte.operationRe != nil {
@@ -135,6 +195,13 @@ func (dte *dropTraceEvaluator) ShouldDrop(_ pcommon.TraceID, trace *TraceData) b
 	if len(dte.attrs) > 0 {
 		conditionMet.attrs = matchingAttrsFound
 	}
+	if dte.spanKinds != nil {
+		conditionMet.spanKind = matchingSpanKindFound
+	}
+	if dte.statusMessageRe != nil {
+		conditionMet.statusMessage = matchingStatusMessageFound
+	}
 
-	return conditionMet.operationName && conditionMet.numericAttr && conditionMet.stringAttr && conditionMet.attrs
+	return conditionMet.operationName && conditionMet.numericAttr && conditionMet.stringAttr && conditionMet.attrs &&
+		conditionMet.spanKind && conditionMet.statusMessage
 }
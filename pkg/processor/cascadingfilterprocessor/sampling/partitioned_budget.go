@@ -0,0 +1,173 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
+)
+
+// partitionedBudget splits the spans per second budget of a policy between the partitions, which
+// are identified by the value of a resource attribute
+type partitionedBudget struct {
+	attribute         string
+	fairShare         bool
+	maxSpansPerSecond int32
+
+	currentSecond        int64
+	spansInCurrentSecond int32
+	partitions           map[string]*partitionUsage
+	previousDemand       map[string]int32
+}
+
+type partitionUsage struct {
+	// spans is the number of spans selected in the current second
+	spans int32
+	// demand is the number of spans of the matching traces in the current second
+	demand int32
+}
+
+func newPartitionedBudget(cfg *config.PartitionCfg, maxSpansPerSecond int32) (*partitionedBudget, error) {
+	if cfg.Attribute == "" {
+		return nil, errors.New("partition attribute is required")
+	}
+	if maxSpansPerSecond <= 0 {
+		return nil, errors.New("partition requires spans_per_second greater than 0")
+	}
+
+	var fairShare bool
+	switch cfg.Mode {
+	case "", config.EqualPartitionMode:
+	case config.FairSharePartitionMode:
+		fairShare = true
+	default:
+		return nil, fmt.Errorf("unexpected partition mode: %s", cfg.Mode)
+	}
+
+	return &partitionedBudget{
+		attribute:         cfg.Attribute,
+		fairShare:         fairShare,
+		maxSpansPerSecond: maxSpansPerSecond,
+		partitions:        make(map[string]*partitionUsage),
+		previousDemand:    make(map[string]int32),
+	}, nil
+}
+
+// partition returns the partition of the trace, which is the value of the attribute in the first resource having it
+func (pb *partitionedBudget) partition(trace *TraceData) string {
+	trace.Lock()
+	batches := trace.ReceivedBatches
+	trace.Unlock()
+
+	for _, batch := range batches {
+		rs := batch.ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			if value, found := rs.At(i).Resource().Attributes().Get(pb.attribute); found {
+				return value.AsString()
+			}
+		}
+	}
+	return ""
+}
+
+func (pb *partitionedBudget) startSecond(currSecond int64) {
+	if pb.currentSecond == currSecond {
+		return
+	}
+
+	previousDemand := make(map[string]int32, len(pb.partitions))
+	if pb.currentSecond == currSecond-1 {
+		for partition, usage := range pb.partitions {
+			previousDemand[partition] = usage.demand
+		}
+	}
+
+	pb.currentSecond = currSecond
+	pb.spansInCurrentSecond = 0
+	pb.partitions = make(map[string]*partitionUsage, len(previousDemand))
+	pb.previousDemand = previousDemand
+}
+
+// share returns the part of the budget of each partition, which are the ones active in the current or previous second
+func (pb *partitionedBudget) share() int32 {
+	numPartitions := len(pb.partitions)
+	for partition := range pb.previousDemand {
+		if _, found := pb.partitions[partition]; !found {
+			numPartitions++
+		}
+	}
+	return pb.maxSpansPerSecond / int32(numPartitions)
+}
+
+// reservedByOthers returns the part of the budget which is still expected to be used by partitions other than
+// the provided one, basing on their demand in the current and previous second, up to their share
+func (pb *partitionedBudget) reservedByOthers(partition string, share int32) int32 {
+	var reserved int32
+	reserve := func(demand int32, spans int32) {
+		if demand > share {
+			demand = share
+		}
+		if demand > spans {
+			reserved += demand - spans
+		}
+	}
+
+	for p, usage := range pb.partitions {
+		if p == partition {
+			continue
+		}
+		demand := usage.demand
+		if pb.previousDemand[p] > demand {
+			demand = pb.previousDemand[p]
+		}
+		reserve(demand, usage.spans)
+	}
+	for p, demand := range pb.previousDemand {
+		if _, found := pb.partitions[p]; !found && p != partition {
+			reserve(demand, 0)
+		}
+	}
+	return reserved
+}
+
+// updateRate checks if the spans fit within the budget of the partition and updates its usage
+func (pb *partitionedBudget) updateRate(currSecond int64, partition string, numSpans int32) Decision {
+	pb.startSecond(currSecond)
+
+	usage, found := pb.partitions[partition]
+	if !found {
+		usage = &partitionUsage{}
+		pb.partitions[partition] = usage
+	}
+	usage.demand += numSpans
+
+	spansInSecondIfSampled := pb.spansInCurrentSecond + numSpans
+	if spansInSecondIfSampled > pb.maxSpansPerSecond {
+		return NotSampled
+	}
+
+	share := pb.share()
+	fitsInShare := usage.spans+numSpans <= share
+	fitsInUnused := pb.fairShare && spansInSecondIfSampled+pb.reservedByOthers(partition, share) <= pb.maxSpansPerSecond
+	if !fitsInShare && !fitsInUnused {
+		return NotSampled
+	}
+
+	usage.spans += numSpans
+	pb.spansInCurrentSecond = spansInSecondIfSampled
+	return Sampled
+}
@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
+)
+
+// requestSpans requests the spans in chunks of 10 spans and returns the number of selected spans
+func requestSpans(pb *partitionedBudget, currSecond int64, partition string, numSpans int32) int32 {
+	var selected int32
+	for ; numSpans > 0; numSpans -= 10 {
+		if pb.updateRate(currSecond, partition, 10) == Sampled {
+			selected += 10
+		}
+	}
+	return selected
+}
+
+func TestPartitionedBudgetEqualMode(t *testing.T) {
+	pb, err := newPartitionedBudget(&config.PartitionCfg{Attribute: "service.name"}, 100)
+	require.NoError(t, err)
+
+	// With no history, the first partition can take the whole budget
+	assert.Equal(t, int32(80), requestSpans(pb, 1, "noisy", 80))
+	assert.Equal(t, int32(20), requestSpans(pb, 1, "quiet", 30))
+
+	// Partitions active in the previous second get equal shares
+	assert.Equal(t, int32(50), requestSpans(pb, 2, "noisy", 80))
+	assert.Equal(t, int32(30), requestSpans(pb, 2, "quiet", 30))
+
+	// A new partition reduces the share of the others
+	assert.Equal(t, int32(30), requestSpans(pb, 3, "other", 50))
+	assert.Equal(t, int32(30), requestSpans(pb, 3, "noisy", 80))
+	assert.Equal(t, int32(30), requestSpans(pb, 3, "quiet", 30))
+}
+
+func TestPartitionedBudgetFairShareMode(t *testing.T) {
+	pb, err := newPartitionedBudget(&config.PartitionCfg{Attribute: "service.name", Mode: config.FairSharePartitionMode}, 100)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(80), requestSpans(pb, 1, "noisy", 80))
+	assert.Equal(t, int32(10), requestSpans(pb, 1, "quiet", 10))
+
+	// The noisy partition uses the budget not needed by the quiet one, which still gets its part
+	assert.Equal(t, int32(90), requestSpans(pb, 2, "noisy", 200))
+	assert.Equal(t, int32(10), requestSpans(pb, 2, "quiet", 10))
+
+	// When the quiet partition needs more, the noisy one gets only what is not needed by the quiet one
+	assert.Equal(t, int32(40), requestSpans(pb, 3, "quiet", 40))
+	assert.Equal(t, int32(60), requestSpans(pb, 3, "noisy", 200))
+	assert.Equal(t, int32(60), requestSpans(pb, 4, "noisy", 200))
+	assert.Equal(t, int32(40), requestSpans(pb, 4, "quiet", 40))
+}
+
+func TestPartitionedBudgetConfig(t *testing.T) {
+	_, err := newPartitionedBudget(&config.PartitionCfg{}, 100)
+	assert.EqualError(t, err, "partition attribute is required")
+
+	_, err = newPartitionedBudget(&config.PartitionCfg{Attribute: "service.name"}, -1)
+	assert.EqualError(t, err, "partition requires spans_per_second greater than 0")
+
+	_, err = newPartitionedBudget(&config.PartitionCfg{Attribute: "service.name", Mode: "mode"}, 100)
+	assert.EqualError(t, err, "unexpected partition mode: mode")
+}
+
+func TestPartitionedFilter(t *testing.T) {
+	filter, err := NewFilter(zap.NewNop(), &config.TraceAcceptCfg{
+		Name:           "partitioned",
+		SpansPerSecond: 2,
+		Partition:      &config.PartitionCfg{Attribute: "service.name"},
+	})
+	require.NoError(t, err)
+
+	traceID := pcommon.NewTraceID([16]byte{1})
+	newServiceTrace := func(service string) *TraceData {
+		trace, _ := newTrace()
+		trace.SpanCount = 1
+		trace.ReceivedBatches[0].ResourceSpans().At(0).Resource().Attributes().InsertString("service.name", service)
+		return trace
+	}
+
+	trace := newServiceTrace("foo")
+	assert.Equal(t, Sampled, filter.Evaluate(traceID, trace))
+	assert.Equal(t, "foo", trace.Partition)
+
+	trace = newServiceTrace("bar")
+	filter.Evaluate(traceID, trace)
+	assert.Equal(t, "bar", trace.Partition)
+
+	// The partition of traces without the attribute is empty
+	trace, _ = newTrace()
+	trace.SpanCount = 1
+	filter.Evaluate(traceID, trace)
+	assert.Equal(t, "", trace.Partition)
+}
@@ -33,6 +33,10 @@ type TraceData struct {
 	SelectedByProbabilisticFilter bool
 	// ProvisionalDecisionFilter includes the name of the filter which has selected the trace
 	ProvisionalDecisionFilterName string
+	// Partition is the budget partition of the trace, set by the first partitioned policy which has matched it
+	Partition string
+	// ShadowDecisionFilterNames includes the names of the shadow policies which would select the trace
+	ShadowDecisionFilterNames []string
 	// Arrival time the first span for the trace was received.
 	ArrivalTime time.Time
 	// Decisiontime time when sampling decision was taken.
@@ -41,6 +45,10 @@ type TraceData struct {
 	SpanCount int32
 	// ReceivedBatches stores all the batches received for the trace.
 	ReceivedBatches []ptrace.Traces
+	// BufferedSpans is the number of spans in ReceivedBatches, used for limiting the buffered data.
+	BufferedSpans int64
+	// BufferedBytes is the size of ReceivedBatches, it's tracked only when the buffered bytes are limited.
+	BufferedBytes int64
 }
 
 // Decision gives the status of sampling decision.
@@ -16,9 +16,12 @@ package sampling
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
 
 	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
@@ -45,6 +48,7 @@ type attributeFilter struct {
 	values   map[string]struct{}
 	patterns []*regexp.Regexp
 	ranges   []attributeRange
+	scope    config.AttributeScope
 }
 
 type policyEvaluator struct {
@@ -54,12 +58,22 @@ type policyEvaluator struct {
 
 	operationRe       *regexp.Regexp
 	minDuration       *time.Duration
+	maxDuration       *time.Duration
 	minNumberOfSpans  *int
+	maxNumberOfSpans  *int
 	minNumberOfErrors *int
+	maxNumberOfErrors *int
+	spanKinds         map[ptrace.SpanKind]struct{}
+	statusMessageRe   *regexp.Regexp
+	rootSpanOnly      bool
 
 	currentSecond        int64
 	maxSpansPerSecond    int32
 	spansInCurrentSecond int32
+	// partitions splits the budget between the partitions, when configured
+	partitions *partitionedBudget
+	// bucket replaces the per-second budget window when the burst is configured
+	bucket *TokenBucket
 
 	invertMatch bool
 
@@ -124,6 +138,12 @@ func createAttributeFilter(cfg config.AttributeCfg) (*attributeFilter, error) {
 			}
 		}
 	}
+	switch cfg.Scope {
+	case "", config.AttributeScopeResource, config.AttributeScopeSpan:
+	default:
+		return nil, fmt.Errorf("invalid attribute scope: %q", cfg.Scope)
+	}
+
 	var ranges []attributeRange
 	for _, r := range cfg.Ranges {
 		ranges = append(ranges, attributeRange{
@@ -137,6 +157,7 @@ func createAttributeFilter(cfg config.AttributeCfg) (*attributeFilter, error) {
 		values:   valuesMap,
 		patterns: patterns,
 		ranges:   ranges,
+		scope:    cfg.Scope,
 	}, nil
 }
 
@@ -157,57 +178,202 @@ func createAttributesFilter(cfg []config.AttributeCfg) ([]attributeFilter, error
 	return filters, nil
 }
 
-// NewProbabilisticFilter creates a policy evaluator intended for selecting samples probabilistically
-func NewProbabilisticFilter(logger *zap.Logger, maxSpanRate int32) (PolicyEvaluator, error) {
-	return &policyEvaluator{
+var spanKindsByName = map[string]ptrace.SpanKind{
+	"UNSPECIFIED": ptrace.SpanKindUnspecified,
+	"INTERNAL":    ptrace.SpanKindInternal,
+	"SERVER":      ptrace.SpanKindServer,
+	"CLIENT":      ptrace.SpanKindClient,
+	"PRODUCER":    ptrace.SpanKindProducer,
+	"CONSUMER":    ptrace.SpanKindConsumer,
+}
+
+func createSpanKinds(names []string) (map[ptrace.SpanKind]struct{}, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	kinds := make(map[ptrace.SpanKind]struct{}, len(names))
+	for _, name := range names {
+		kind, ok := spanKindsByName[strings.TrimPrefix(strings.ToUpper(name), "SPAN_KIND_")]
+		if !ok {
+			return nil, fmt.Errorf("invalid span kind: %q", name)
+		}
+		kinds[kind] = struct{}{}
+	}
+
+	return kinds, nil
+}
+
+func createPattern(pattern *string) (*regexp.Regexp, error) {
+	if pattern == nil {
+		return nil, nil
+	}
+	return regexp.Compile(*pattern)
+}
+
+// NewProbabilisticFilter creates a policy evaluator intended for selecting samples probabilistically,
+// the partition config is optional
+func NewProbabilisticFilter(logger *zap.Logger, maxSpanRate int32, partition *config.PartitionCfg) (PolicyEvaluator, error) {
+	pe := &policyEvaluator{
 		logger:               logger,
 		currentSecond:        0,
 		spansInCurrentSecond: 0,
 		maxSpansPerSecond:    maxSpanRate,
-	}, nil
+	}
+	if err := pe.setPartition(partition); err != nil {
+		return nil, err
+	}
+	return pe, nil
 }
 
 // NewFilter creates a policy evaluator that samples all traces with the specified criteria
 func NewFilter(logger *zap.Logger, cfg *config.TraceAcceptCfg) (PolicyEvaluator, error) {
-	numericAttrFilter := createNumericAttributeFilter(cfg.NumericAttributeCfg)
-	stringAttrFilter, err := createStringAttributeFilter(cfg.StringAttributeCfg)
+	if cfg.Condition != nil {
+		if hasMatchingOptions(cfg.NumericAttributeCfg, cfg.StringAttributeCfg, cfg.AttributeCfg, cfg.PropertiesCfg) {
+			return nil, errors.New("condition cannot be used together with other matching options")
+		}
+		return newConditionTreeFilter(logger, cfg)
+	}
+
+	pe, err := newRulesEvaluator(logger, cfg.NumericAttributeCfg, cfg.StringAttributeCfg, cfg.AttributeCfg, cfg.PropertiesCfg)
 	if err != nil {
 		return nil, err
 	}
-	attrsFilter, err := createAttributesFilter(cfg.AttributeCfg)
-	if err != nil {
+	pe.maxSpansPerSecond = cfg.SpansPerSecond
+	pe.invertMatch = cfg.InvertMatch
+	if err := pe.setBurst(cfg.Burst); err != nil {
+		return nil, err
+	}
+	if err := pe.setPartition(cfg.Partition); err != nil {
 		return nil, err
 	}
+	return pe, nil
+}
+
+// setBurst replaces the per-second budget window of the policy evaluator with a token bucket, when the burst is set
+func (pe *policyEvaluator) setBurst(burst int32) error {
+	if burst <= 0 {
+		return nil
+	}
+	if pe.maxSpansPerSecond <= 0 {
+		return errors.New("burst requires spans_per_second greater than 0")
+	}
+	if burst < pe.maxSpansPerSecond {
+		return errors.New("burst cannot be lower than spans_per_second")
+	}
 
-	var operationRe *regexp.Regexp
+	pe.bucket = NewTokenBucket(pe.maxSpansPerSecond, burst)
+	return nil
+}
 
-	if cfg.PropertiesCfg.NamePattern != nil {
-		operationRe, err = regexp.Compile(*cfg.PropertiesCfg.NamePattern)
-		if err != nil {
-			return nil, err
-		}
+// setPartition splits the budget of the policy evaluator between the partitions, when configured
+func (pe *policyEvaluator) setPartition(cfg *config.PartitionCfg) error {
+	if cfg == nil {
+		return nil
+	}
+	if pe.bucket != nil {
+		return errors.New("partition cannot be used together with burst")
+	}
+
+	partitions, err := newPartitionedBudget(cfg, pe.maxSpansPerSecond)
+	if err != nil {
+		return err
 	}
+	pe.partitions = partitions
+	return nil
+}
 
-	if cfg.PropertiesCfg.MinDuration != nil && *cfg.PropertiesCfg.MinDuration < 0*time.Second {
+// newRulesEvaluator creates a policy evaluator which matches the traces using the specified criteria
+func newRulesEvaluator(
+	logger *zap.Logger,
+	numericAttrCfg *config.NumericAttributeCfg,
+	stringAttrCfg *config.StringAttributeCfg,
+	attrCfg []config.AttributeCfg,
+	propertiesCfg config.PropertiesCfg,
+) (*policyEvaluator, error) {
+	numericAttrFilter := createNumericAttributeFilter(numericAttrCfg)
+	stringAttrFilter, err := createStringAttributeFilter(stringAttrCfg)
+	if err != nil {
+		return nil, err
+	}
+	attrsFilter, err := createAttributesFilter(attrCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	operationRe, err := createPattern(propertiesCfg.NamePattern)
+	if err != nil {
+		return nil, err
+	}
+	statusMessageRe, err := createPattern(propertiesCfg.StatusMessagePattern)
+	if err != nil {
+		return nil, err
+	}
+	spanKinds, err := createSpanKinds(propertiesCfg.SpanKinds)
+	if err != nil {
+		return nil, err
+	}
+
+	if propertiesCfg.MinDuration != nil && *propertiesCfg.MinDuration < 0*time.Second {
 		return nil, errors.New("minimum span duration must be a non-negative number")
 	}
 
-	if cfg.PropertiesCfg.MinNumberOfSpans != nil && *cfg.PropertiesCfg.MinNumberOfSpans < 1 {
+	if propertiesCfg.MaxDuration != nil && *propertiesCfg.MaxDuration < 0*time.Second {
+		return nil, errors.New("maximum span duration must be a non-negative number")
+	}
+
+	if propertiesCfg.MinNumberOfSpans != nil && *propertiesCfg.MinNumberOfSpans < 1 {
 		return nil, errors.New("minimum number of spans must be a positive number")
 	}
 
+	if propertiesCfg.MaxNumberOfSpans != nil && *propertiesCfg.MaxNumberOfSpans < 1 {
+		return nil, errors.New("maximum number of spans must be a positive number")
+	}
+
+	if propertiesCfg.MaxNumberOfErrors != nil && *propertiesCfg.MaxNumberOfErrors < 0 {
+		return nil, errors.New("maximum number of errors must be a non-negative number")
+	}
+
 	return &policyEvaluator{
-		stringAttr:           stringAttrFilter,
-		numericAttr:          numericAttrFilter,
-		attrs:                attrsFilter,
-		operationRe:          operationRe,
-		minDuration:          cfg.PropertiesCfg.MinDuration,
-		minNumberOfSpans:     cfg.PropertiesCfg.MinNumberOfSpans,
-		minNumberOfErrors:    cfg.PropertiesCfg.MinNumberOfErrors,
-		logger:               logger,
-		currentSecond:        0,
-		spansInCurrentSecond: 0,
-		maxSpansPerSecond:    cfg.SpansPerSecond,
-		invertMatch:          cfg.InvertMatch,
+		stringAttr:        stringAttrFilter,
+		numericAttr:       numericAttrFilter,
+		attrs:             attrsFilter,
+		operationRe:       operationRe,
+		minDuration:       propertiesCfg.MinDuration,
+		maxDuration:       propertiesCfg.MaxDuration,
+		minNumberOfSpans:  propertiesCfg.MinNumberOfSpans,
+		maxNumberOfSpans:  propertiesCfg.MaxNumberOfSpans,
+		minNumberOfErrors: propertiesCfg.MinNumberOfErrors,
+		maxNumberOfErrors: propertiesCfg.MaxNumberOfErrors,
+		spanKinds:         spanKinds,
+		statusMessageRe:   statusMessageRe,
+		rootSpanOnly:      propertiesCfg.RootSpanOnly,
+		logger:            logger,
 	}, nil
 }
+
+// hasMatchingOptions checks if any of the matching options is set
+func hasMatchingOptions(
+	numericAttrCfg *config.NumericAttributeCfg,
+	stringAttrCfg *config.StringAttributeCfg,
+	attrCfg []config.AttributeCfg,
+	propertiesCfg config.PropertiesCfg,
+) bool {
+	return numericAttrCfg != nil ||
+		stringAttrCfg != nil ||
+		len(attrCfg) > 0 ||
+		hasPropertiesCfg(propertiesCfg)
+}
+
+// hasPropertiesCfg checks if any of the properties is set
+func hasPropertiesCfg(propertiesCfg config.PropertiesCfg) bool {
+	return propertiesCfg.NamePattern != nil ||
+		propertiesCfg.MinDuration != nil ||
+		propertiesCfg.MaxDuration != nil ||
+		propertiesCfg.MinNumberOfSpans != nil ||
+		propertiesCfg.MaxNumberOfSpans != nil ||
+		propertiesCfg.MinNumberOfErrors != nil ||
+		propertiesCfg.MaxNumberOfErrors != nil ||
+		len(propertiesCfg.SpanKinds) > 0 ||
+		propertiesCfg.StatusMessagePattern != nil
+}
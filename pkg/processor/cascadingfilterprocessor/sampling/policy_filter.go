@@ -19,6 +19,8 @@ import (
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
 )
 
 func tsToMicros(ts pcommon.Timestamp) int64 {
@@ -27,10 +29,18 @@ func tsToMicros(ts pcommon.Timestamp) int64 {
 
 func checkIfAttrsMatched(resAttrs pcommon.Map, spanAttrs pcommon.Map, filters []attributeFilter) bool {
 	for _, filter := range filters {
-		var resAttrMatched bool
-		spanAttrMatched, spanAttrFound := checkAttributeFilterMatchedAndFound(spanAttrs, filter)
-		if !spanAttrFound {
+		var resAttrMatched, spanAttrMatched bool
+		switch filter.scope {
+		case config.AttributeScopeResource:
 			resAttrMatched, _ = checkAttributeFilterMatchedAndFound(resAttrs, filter)
+		case config.AttributeScopeSpan:
+			spanAttrMatched, _ = checkAttributeFilterMatchedAndFound(spanAttrs, filter)
+		default:
+			var spanAttrFound bool
+			spanAttrMatched, spanAttrFound = checkAttributeFilterMatchedAndFound(spanAttrs, filter)
+			if !spanAttrFound {
+				resAttrMatched, _ = checkAttributeFilterMatchedAndFound(resAttrs, filter)
+			}
 		}
 
 		if !resAttrMatched && !spanAttrMatched {
@@ -91,6 +101,15 @@ func checkAttributeFilterMatchedAndFound(attrs pcommon.Map, filter attributeFilt
 	return false, false
 }
 
+func isRootSpan(span ptrace.Span) bool {
+	return span.ParentSpanID().IsEmpty()
+}
+
+func checkIfSpanKindMatched(span ptrace.Span, kinds map[ptrace.SpanKind]struct{}) bool {
+	_, ok := kinds[span.Kind()]
+	return ok
+}
+
 func checkIfNumericAttrFound(attrs pcommon.Map, filter *numericAttributeFilter) bool {
 	if v, ok := attrs.Get(filter.key); ok {
 		value := v.IntVal()
@@ -133,6 +152,8 @@ func (pe *policyEvaluator) evaluateRules(_ pcommon.TraceID, trace *TraceData) De
 	matchingStringAttrFound := false
 	matchingNumericAttrFound := false
 	matchingAttrsFound := false
+	matchingSpanKindFound := false
+	matchingStatusMessageFound := false
 
 	spanCount := 0
 	errorCount := 0
@@ -160,25 +181,35 @@ func (pe *policyEvaluator) evaluateRules(_ pcommon.TraceID, trace *TraceData) De
 				for k := 0; k < spans.Len(); k++ {
 					span := spans.At(k)
 
-					if !matchingAttrsFound && len(pe.attrs) > 0 {
-						matchingAttrsFound = checkIfAttrsMatched(res.Attributes(), span.Attributes(), pe.attrs)
-					}
+					if !pe.rootSpanOnly || isRootSpan(span) {
+						if !matchingAttrsFound && len(pe.attrs) > 0 {
+							matchingAttrsFound = checkIfAttrsMatched(res.Attributes(), span.Attributes(), pe.attrs)
+						}
 
-					if !matchingStringAttrFound && pe.stringAttr != nil {
-						matchingStringAttrFound = checkIfStringAttrFound(span.Attributes(), pe.stringAttr)
-					}
+						if !matchingStringAttrFound && pe.stringAttr != nil {
+							matchingStringAttrFound = checkIfStringAttrFound(span.Attributes(), pe.stringAttr)
+						}
 
-					if !matchingNumericAttrFound && pe.numericAttr != nil {
-						matchingNumericAttrFound = checkIfNumericAttrFound(span.Attributes(), pe.numericAttr)
-					}
+						if !matchingNumericAttrFound && pe.numericAttr != nil {
+							matchingNumericAttrFound = checkIfNumericAttrFound(span.Attributes(), pe.numericAttr)
+						}
+
+						if pe.operationRe != nil && !matchingOperationFound {
+							if pe.operationRe.MatchString(span.Name()) {
+								matchingOperationFound = true
+							}
+						}
+
+						if pe.spanKinds != nil && !matchingSpanKindFound {
+							matchingSpanKindFound = checkIfSpanKindMatched(span, pe.spanKinds)
+						}
 
-					if pe.operationRe != nil && !matchingOperationFound {
-						if pe.operationRe.MatchString(span.Name()) {
-							matchingOperationFound = true
+						if pe.statusMessageRe != nil && !matchingStatusMessageFound {
+							matchingStatusMessageFound = pe.statusMessageRe.MatchString(span.Status().Message())
 						}
 					}
 
-					if pe.minDuration != nil {
+					if pe.minDuration != nil || pe.maxDuration != nil {
 						startTs := tsToMicros(span.StartTimestamp())
 						endTs := tsToMicros(span.EndTimestamp())
 
@@ -204,15 +235,20 @@ func (pe *policyEvaluator) evaluateRules(_ pcommon.TraceID, trace *TraceData) De
 	}
 
 	conditionMet := struct {
-		operationName, minDuration, minSpanCount, stringAttr, numericAttr, attrs, minErrorCount bool
+		operationName, minDuration, maxDuration, minSpanCount, maxSpanCount, stringAttr, numericAttr, attrs, minErrorCount, maxErrorCount, spanKind, statusMessage bool
 	}{
 		operationName: true,
 		minDuration:   true,
+		maxDuration:   true,
 		minSpanCount:  true,
+		maxSpanCount:  true,
 		stringAttr:    true,
 		numericAttr:   true,
 		attrs:         true,
 		minErrorCount: true,
+		maxErrorCount: true,
+		spanKind:      true,
+		statusMessage: true,
 	}
 
 	if pe.operationRe != nil {
@@ -221,9 +257,15 @@ func (pe *policyEvaluator) evaluateRules(_ pcommon.TraceID, trace *TraceData) De
 	if pe.minNumberOfSpans != nil {
 		conditionMet.minSpanCount = spanCount >= *pe.minNumberOfSpans
 	}
+	if pe.maxNumberOfSpans != nil {
+		conditionMet.maxSpanCount = spanCount <= *pe.maxNumberOfSpans
+	}
 	if pe.minDuration != nil {
 		conditionMet.minDuration = maxEndTime > minStartTime && maxEndTime-minStartTime >= pe.minDuration.Microseconds()
 	}
+	if pe.maxDuration != nil {
+		conditionMet.maxDuration = maxEndTime-minStartTime <= pe.maxDuration.Microseconds()
+	}
 	if pe.numericAttr != nil {
 		conditionMet.numericAttr = matchingNumericAttrFound
 	}
@@ -236,14 +278,28 @@ func (pe *policyEvaluator) evaluateRules(_ pcommon.TraceID, trace *TraceData) De
 	if pe.minNumberOfErrors != nil {
 		conditionMet.minErrorCount = errorCount >= *pe.minNumberOfErrors
 	}
+	if pe.maxNumberOfErrors != nil {
+		conditionMet.maxErrorCount = errorCount <= *pe.maxNumberOfErrors
+	}
+	if pe.spanKinds != nil {
+		conditionMet.spanKind = matchingSpanKindFound
+	}
+	if pe.statusMessageRe != nil {
+		conditionMet.statusMessage = matchingStatusMessageFound
+	}
 
 	if conditionMet.minSpanCount &&
+		conditionMet.maxSpanCount &&
 		conditionMet.minDuration &&
+		conditionMet.maxDuration &&
 		conditionMet.operationName &&
 		conditionMet.numericAttr &&
 		conditionMet.stringAttr &&
 		conditionMet.attrs &&
-		conditionMet.minErrorCount {
+		conditionMet.minErrorCount &&
+		conditionMet.maxErrorCount &&
+		conditionMet.spanKind &&
+		conditionMet.statusMessage {
 		if pe.invertMatch {
 			return NotSampled
 		}
@@ -256,13 +312,20 @@ func (pe *policyEvaluator) evaluateRules(_ pcommon.TraceID, trace *TraceData) De
 	return NotSampled
 }
 
-func (pe *policyEvaluator) shouldConsider(currSecond int64, trace *TraceData) bool {
+func (pe *policyEvaluator) shouldConsider(now time.Time, trace *TraceData) bool {
+	currSecond := now.Unix()
 	if pe.maxSpansPerSecond < 0 {
 		// This emits "second chance" traces
 		return true
+	} else if pe.bucket != nil {
+		// The trace fits when there are enough tokens, it might be larger than the per-second budget
+		return pe.bucket.Fits(now, trace.SpanCount)
 	} else if trace.SpanCount > pe.maxSpansPerSecond {
 		// This trace will never fit, there are more spans than max limit
 		return false
+	} else if pe.partitions != nil {
+		// The partition budget is checked only for the matching traces, so the partition demand is known
+		return true
 	} else if pe.currentSecond == currSecond && trace.SpanCount > pe.maxSpansPerSecond-pe.spansInCurrentSecond {
 		// This trace will not fit in this second, no way
 		return false
@@ -276,7 +339,15 @@ func (pe *policyEvaluator) emitsSecondChance() bool {
 	return pe.maxSpansPerSecond < 0
 }
 
-func (pe *policyEvaluator) updateRate(currSecond int64, numSpans int32) Decision {
+func (pe *policyEvaluator) updateRate(now time.Time, numSpans int32) Decision {
+	if pe.bucket != nil {
+		if pe.bucket.Take(now, numSpans) {
+			return Sampled
+		}
+		return NotSampled
+	}
+
+	currSecond := now.Unix()
 	if pe.currentSecond != currSecond {
 		pe.currentSecond = currSecond
 		pe.spansInCurrentSecond = 0
@@ -291,12 +362,26 @@ func (pe *policyEvaluator) updateRate(currSecond int64, numSpans int32) Decision
 	return NotSampled
 }
 
+// updateTraceRate checks if the trace fits within the budget and updates its usage. When the budget
+// is partitioned, the budget of the trace partition is used
+func (pe *policyEvaluator) updateTraceRate(now time.Time, trace *TraceData) Decision {
+	if pe.partitions == nil {
+		return pe.updateRate(now, trace.SpanCount)
+	}
+
+	partition := pe.partitions.partition(trace)
+	if trace.Partition == "" {
+		trace.Partition = partition
+	}
+	return pe.partitions.updateRate(now.Unix(), partition, trace.SpanCount)
+}
+
 // Evaluate looks at the trace data and returns a corresponding SamplingDecision. Also takes into account
 // the usage of sampling rate budget
 func (pe *policyEvaluator) Evaluate(traceID pcommon.TraceID, trace *TraceData) Decision {
-	currSecond := time.Now().Unix()
+	now := time.Now()
 
-	if !pe.shouldConsider(currSecond, trace) {
+	if !pe.shouldConsider(now, trace) {
 		return NotSampled
 	}
 
@@ -309,5 +394,5 @@ func (pe *policyEvaluator) Evaluate(traceID pcommon.TraceID, trace *TraceData) D
 		return SecondChance
 	}
 
-	return pe.updateRate(currSecond, trace.SpanCount)
+	return pe.updateTraceRate(now, trace)
 }
@@ -26,6 +26,8 @@ import (
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
 )
 
 var (
@@ -184,3 +186,180 @@ func newTraceAttrs(operationName string, duration time.Duration, numberOfSpans i
 		ReceivedBatches: traceBatches,
 	}
 }
+
+func newTraceWithRootSpan(rootKind ptrace.SpanKind, childKind ptrace.SpanKind, childStatusMessage string) *TraceData {
+	traces := ptrace.NewTraces()
+	spans := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+
+	root := spans.AppendEmpty()
+	root.SetName("root")
+	root.SetKind(rootKind)
+
+	child := spans.AppendEmpty()
+	child.SetName("child")
+	child.SetKind(childKind)
+	child.SetParentSpanID(pcommon.NewSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	child.Status().SetMessage(childStatusMessage)
+
+	return &TraceData{
+		ReceivedBatches: []ptrace.Traces{traces},
+	}
+}
+
+func TestSpanKindStatusMessageAndRootSpanFilter(t *testing.T) {
+	statusMessagePattern := "timeout.*"
+
+	cases := []struct {
+		Desc       string
+		Properties config.PropertiesCfg
+		Match      []*TraceData
+		DontMatch  []*TraceData
+	}{
+		{
+			Desc:       "span kind",
+			Properties: config.PropertiesCfg{SpanKinds: []string{"SERVER", "span_kind_consumer"}},
+			Match: []*TraceData{
+				newTraceWithRootSpan(ptrace.SpanKindServer, ptrace.SpanKindInternal, ""),
+				newTraceWithRootSpan(ptrace.SpanKindInternal, ptrace.SpanKindConsumer, ""),
+			},
+			DontMatch: []*TraceData{newTraceWithRootSpan(ptrace.SpanKindClient, ptrace.SpanKindInternal, "")},
+		},
+		{
+			Desc:       "span kind of root span",
+			Properties: config.PropertiesCfg{SpanKinds: []string{"SERVER"}, RootSpanOnly: true},
+			Match:      []*TraceData{newTraceWithRootSpan(ptrace.SpanKindServer, ptrace.SpanKindInternal, "")},
+			DontMatch:  []*TraceData{newTraceWithRootSpan(ptrace.SpanKindInternal, ptrace.SpanKindServer, "")},
+		},
+		{
+			Desc:       "status message",
+			Properties: config.PropertiesCfg{StatusMessagePattern: &statusMessagePattern},
+			Match:      []*TraceData{newTraceWithRootSpan(ptrace.SpanKindServer, ptrace.SpanKindClient, "timeout after 5s")},
+			DontMatch:  []*TraceData{newTraceWithRootSpan(ptrace.SpanKindServer, ptrace.SpanKindClient, "connection refused")},
+		},
+		{
+			Desc:       "status message of root span",
+			Properties: config.PropertiesCfg{StatusMessagePattern: &statusMessagePattern, RootSpanOnly: true},
+			DontMatch:  []*TraceData{newTraceWithRootSpan(ptrace.SpanKindServer, ptrace.SpanKindClient, "timeout after 5s")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			filter, err := NewFilter(zap.NewNop(), &config.TraceAcceptCfg{
+				SpansPerSecond: math.MaxInt32,
+				PropertiesCfg:  c.Properties,
+			})
+			require.NoError(t, err)
+
+			dropFilter, err := NewDropTraceEvaluator(zap.NewNop(), config.TraceRejectCfg{
+				SpanKinds:            c.Properties.SpanKinds,
+				StatusMessagePattern: c.Properties.StatusMessagePattern,
+				RootSpanOnly:         c.Properties.RootSpanOnly,
+			})
+			require.NoError(t, err)
+
+			for _, trace := range c.Match {
+				assert.Equal(t, Sampled, filter.Evaluate(pcommon.InvalidTraceID(), trace))
+				assert.True(t, dropFilter.ShouldDrop(pcommon.InvalidTraceID(), trace))
+			}
+			for _, trace := range c.DontMatch {
+				assert.Equal(t, NotSampled, filter.Evaluate(pcommon.InvalidTraceID(), trace))
+				assert.False(t, dropFilter.ShouldDrop(pcommon.InvalidTraceID(), trace))
+			}
+		})
+	}
+}
+
+func TestInvalidSpanKind(t *testing.T) {
+	_, err := NewFilter(zap.NewNop(), &config.TraceAcceptCfg{
+		PropertiesCfg: config.PropertiesCfg{SpanKinds: []string{"SERVERLESS"}},
+	})
+	assert.EqualError(t, err, `invalid span kind: "SERVERLESS"`)
+}
+
+func TestMaxSpanPropertiesFilter(t *testing.T) {
+	maxDuration := 500 * time.Microsecond
+	maxNumberOfSpans := 1
+	maxNumberOfErrors := 0
+
+	filter, err := NewFilter(zap.NewNop(), &config.TraceAcceptCfg{
+		SpansPerSecond: math.MaxInt32,
+		PropertiesCfg: config.PropertiesCfg{
+			MaxDuration:       &maxDuration,
+			MaxNumberOfSpans:  &maxNumberOfSpans,
+			MaxNumberOfErrors: &maxNumberOfErrors,
+		},
+	})
+	require.NoError(t, err)
+
+	evaluate(t, *filter.(*policyEvaluator), newTraceAttrs("foobar", 100*time.Microsecond, 1, 0), Sampled)
+	evaluate(t, *filter.(*policyEvaluator), newTraceAttrs("foobar", 1000*time.Microsecond, 1, 0), NotSampled)
+	evaluate(t, *filter.(*policyEvaluator), newTraceAttrs("foobar", 100*time.Microsecond, 2, 0), NotSampled)
+	evaluate(t, *filter.(*policyEvaluator), newTraceAttrs("foobar", 100*time.Microsecond, 1, 1), NotSampled)
+}
+
+func TestDropTraceByProperties(t *testing.T) {
+	healthCheckPattern := "health.*"
+	maxDuration := 500 * time.Microsecond
+	maxNumberOfSpans := 1
+	maxNumberOfErrors := 0
+
+	// Drops short health checks, which have a single span and no errors
+	dropFilter, err := NewDropTraceEvaluator(zap.NewNop(), config.TraceRejectCfg{
+		NamePattern: &healthCheckPattern,
+		PropertiesCfg: config.PropertiesCfg{
+			MaxDuration:       &maxDuration,
+			MaxNumberOfSpans:  &maxNumberOfSpans,
+			MaxNumberOfErrors: &maxNumberOfErrors,
+		},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		Desc       string
+		Trace      *TraceData
+		ShouldDrop bool
+	}{
+		{
+			Desc:       "short health check",
+			Trace:      newTraceAttrs("healthz", 100*time.Microsecond, 1, 0),
+			ShouldDrop: true,
+		},
+		{
+			Desc:  "long health check",
+			Trace: newTraceAttrs("healthz", 1000*time.Microsecond, 1, 0),
+		},
+		{
+			Desc:  "health check with many spans",
+			Trace: newTraceAttrs("healthz", 100*time.Microsecond, 2, 0),
+		},
+		{
+			Desc:  "failed health check",
+			Trace: newTraceAttrs("healthz", 100*time.Microsecond, 1, 1),
+		},
+		{
+			Desc:  "other trace",
+			Trace: newTraceAttrs("foobar", 100*time.Microsecond, 1, 0),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			assert.Equal(t, c.ShouldDrop, dropFilter.ShouldDrop(pcommon.InvalidTraceID(), c.Trace))
+		})
+	}
+}
+
+func TestDropTraceByPropertiesSpanCriteria(t *testing.T) {
+	healthCheckPattern := "health.*"
+
+	_, err := NewDropTraceEvaluator(zap.NewNop(), config.TraceRejectCfg{
+		PropertiesCfg: config.PropertiesCfg{NamePattern: &healthCheckPattern},
+	})
+	assert.EqualError(t, err, "name_pattern, span_kinds, status_message_pattern and root_span_only have to be set at the filter level, not in properties")
+
+	_, err = NewDropTraceEvaluator(zap.NewNop(), config.TraceRejectCfg{
+		PropertiesCfg: config.PropertiesCfg{RootSpanOnly: true},
+	})
+	assert.Error(t, err)
+}
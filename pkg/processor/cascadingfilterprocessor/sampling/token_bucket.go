@@ -0,0 +1,74 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import "time"
+
+// TokenBucket limits the rate of spans, while allowing bursts up to its capacity. The tokens are refilled
+// continuously, so the budget is not consumed at the beginning of each second
+type TokenBucket struct {
+	refillRate float64
+	capacity   float64
+	tokens     float64
+	lastRefill time.Time
+}
+
+// NewTokenBucket creates a token bucket refilled with spansPerSecond tokens per second, holding at most burst tokens.
+// The bucket is initially full
+func NewTokenBucket(spansPerSecond int32, burst int32) *TokenBucket {
+	return &TokenBucket{
+		refillRate: float64(spansPerSecond),
+		capacity:   float64(burst),
+		tokens:     float64(burst),
+	}
+}
+
+func (tb *TokenBucket) refill(now time.Time) {
+	if tb.lastRefill.IsZero() {
+		tb.lastRefill = now
+		return
+	}
+
+	elapsed := now.Sub(tb.lastRefill)
+	if elapsed <= 0 {
+		return
+	}
+	tb.lastRefill = now
+
+	tb.tokens += elapsed.Seconds() * tb.refillRate
+	if tb.tokens > tb.capacity {
+		tb.tokens = tb.capacity
+	}
+}
+
+// Fits checks if the spans would fit within the currently available tokens, without taking them
+func (tb *TokenBucket) Fits(now time.Time, numSpans int32) bool {
+	tb.refill(now)
+	return float64(numSpans) <= tb.tokens
+}
+
+// Take takes the tokens for the spans and returns true when they fit, otherwise no tokens are taken
+func (tb *TokenBucket) Take(now time.Time, numSpans int32) bool {
+	if !tb.Fits(now, numSpans) {
+		return false
+	}
+	tb.tokens -= float64(numSpans)
+	return true
+}
+
+// Capacity returns the maximum number of spans which might be taken at once
+func (tb *TokenBucket) Capacity() int32 {
+	return int32(tb.capacity)
+}
@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+
+	"github.com/SumoLogic/sumologic-otel-collector/pkg/processor/cascadingfilterprocessor/config"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tb := NewTokenBucket(100, 300)
+	assert.Equal(t, int32(300), tb.Capacity())
+
+	// The bucket is initially full, so the burst can be taken at once
+	assert.True(t, tb.Take(now, 250))
+	assert.False(t, tb.Take(now, 100))
+	assert.True(t, tb.Take(now, 50))
+	assert.False(t, tb.Fits(now, 1))
+
+	// The tokens are refilled continuously
+	now = now.Add(100 * time.Millisecond)
+	assert.True(t, tb.Fits(now, 10))
+	assert.False(t, tb.Fits(now, 11))
+	assert.True(t, tb.Take(now, 10))
+
+	// The refilled tokens never exceed the capacity
+	now = now.Add(time.Hour)
+	assert.False(t, tb.Take(now, 301))
+	assert.True(t, tb.Take(now, 300))
+}
+
+func TestRateLimiterWithBurst(t *testing.T) {
+	var empty = map[string]interface{}{}
+
+	trace := newTraceStringAttrs(empty, "example", "value")
+	traceID := pcommon.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	rateLimiter := newRateLimiterFilter(3)
+	assert.NoError(t, rateLimiter.setBurst(10))
+
+	// Trace span count greater than burst
+	trace.SpanCount = 11
+	decision := rateLimiter.Evaluate(traceID, trace)
+	assert.Equal(t, decision, NotSampled)
+
+	// Trace span count greater than spans per second, but within the burst
+	trace.SpanCount = 8
+	decision = rateLimiter.Evaluate(traceID, trace)
+	assert.Equal(t, decision, Sampled)
+
+	// Trace span count greater than the remaining tokens
+	trace.SpanCount = 3
+	decision = rateLimiter.Evaluate(traceID, trace)
+	assert.Equal(t, decision, NotSampled)
+}
+
+func TestBurstValidation(t *testing.T) {
+	pe := &policyEvaluator{logger: zap.NewNop()}
+	assert.EqualError(t, pe.setBurst(10), "burst requires spans_per_second greater than 0")
+
+	pe = newRateLimiterFilter(3)
+	assert.NoError(t, pe.setBurst(0))
+	assert.Nil(t, pe.bucket)
+	assert.EqualError(t, pe.setBurst(2), "burst cannot be lower than spans_per_second")
+	assert.Nil(t, pe.bucket)
+	assert.NoError(t, pe.setBurst(10))
+	assert.EqualError(t, pe.setPartition(&config.PartitionCfg{Attribute: "service.name"}), "partition cannot be used together with burst")
+}
@@ -0,0 +1,215 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+const (
+	// otelTraceStateKey is the key of the OpenTelemetry entry of the W3C trace state
+	otelTraceStateKey = "ot"
+	// maxPValue is the p-value for the zero probability, it's also the upper bound for r-values
+	maxPValue = 63
+	// maxRValue is the maximum r-value, as the randomness is limited to 62 bits
+	maxRValue = 62
+)
+
+// TraceIDProbabilisticFilter is a policy evaluator which selects the traces basing on their trace ID,
+// so that all the collectors make the same decision for a given trace. It follows the OpenTelemetry
+// consistent probability sampling, using the `ot=p:<p-value>;r:<r-value>` entry of the W3C trace state.
+// The selected traces are still subject to the processor spans per second limit, which is not consistent
+// across the collectors.
+type TraceIDProbabilisticFilter struct {
+	pValue int
+
+	logger *zap.Logger
+}
+
+var _ PolicyEvaluator = (*TraceIDProbabilisticFilter)(nil)
+
+// NewTraceIDProbabilisticFilter creates a policy evaluator which selects the provided ratio of traces basing
+// on their trace ID. The ratio is rounded down to a power of two, as required by consistent probability sampling.
+func NewTraceIDProbabilisticFilter(logger *zap.Logger, ratio float64) (*TraceIDProbabilisticFilter, error) {
+	if ratio <= 0 || ratio > 1 {
+		return nil, errors.New("trace ID sampling ratio must be greater than 0 and lower or equal 1")
+	}
+
+	pValue := int(math.Ceil(-math.Log2(ratio)))
+	if pValue > maxRValue {
+		pValue = maxRValue
+	}
+
+	return &TraceIDProbabilisticFilter{
+		pValue: pValue,
+		logger: logger,
+	}, nil
+}
+
+// Probability returns the probability of selecting a trace
+func (f *TraceIDProbabilisticFilter) Probability() float64 {
+	return math.Pow(2, -float64(f.pValue))
+}
+
+// Evaluate selects the trace when its r-value is at least the configured p-value. The r-value is taken
+// from the trace state when it's present, otherwise it's derived from the trace ID.
+func (f *TraceIDProbabilisticFilter) Evaluate(traceID pcommon.TraceID, trace *TraceData) Decision {
+	trace.Lock()
+	batches := trace.ReceivedBatches
+	trace.Unlock()
+
+	rValue, found := traceRValue(batches)
+	if !found {
+		rValue = rValueFromTraceID(traceID)
+	}
+
+	if f.pValue <= rValue {
+		return Sampled
+	}
+	return NotSampled
+}
+
+// UpdateTraceState sets the p-value and the r-value of the trace state of the selected spans. When the spans were
+// already sampled with a lower probability (higher p-value), the latter is kept.
+func (f *TraceIDProbabilisticFilter) UpdateTraceState(traces ptrace.Traces) {
+	rs := traces.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				traceState := string(span.TraceState())
+
+				pValue, rValue, hasP, hasR := parseOTelTraceState(traceState)
+				if !hasP || pValue < f.pValue {
+					pValue = f.pValue
+				}
+				if !hasR {
+					rValue = rValueFromTraceID(span.TraceID())
+				}
+
+				span.SetTraceState(ptrace.TraceState(updateOTelTraceState(traceState, pValue, rValue)))
+			}
+		}
+	}
+}
+
+// traceRValue returns the r-value of the first span which has it set in the trace state
+func traceRValue(batches []ptrace.Traces) (int, bool) {
+	for _, batch := range batches {
+		rs := batch.ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			ss := rs.At(i).ScopeSpans()
+			for j := 0; j < ss.Len(); j++ {
+				spans := ss.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					if _, rValue, _, hasR := parseOTelTraceState(string(spans.At(k).TraceState())); hasR {
+						return rValue, true
+					}
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// rValueFromTraceID derives the r-value from the mixed bits of the trace ID, it's the number of leading zeros
+// of a 62 bits long random value, so that the probability of r-value being at least n equals 2^-n
+func rValueFromTraceID(traceID pcommon.TraceID) int {
+	bytes := traceID.Bytes()
+	random := mixBits(binary.BigEndian.Uint64(bytes[:8])^mixBits(binary.BigEndian.Uint64(bytes[8:]))) >> 2
+
+	if random == 0 {
+		return maxRValue
+	}
+	return bits.LeadingZeros64(random) - 2
+}
+
+// mixBits is the splitmix64 finalizer, it spreads every input bit over the whole output, so that
+// non-random trace IDs (e.g. sequential ones) still produce uniformly distributed r-values
+func mixBits(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// parseOTelTraceState returns the p-value and the r-value of the `ot` entry of the W3C trace state
+func parseOTelTraceState(traceState string) (pValue int, rValue int, hasP bool, hasR bool) {
+	for _, member := range strings.Split(traceState, ",") {
+		member = strings.TrimSpace(member)
+		if !strings.HasPrefix(member, otelTraceStateKey+"=") {
+			continue
+		}
+
+		for _, field := range strings.Split(strings.TrimPrefix(member, otelTraceStateKey+"="), ";") {
+			key, value, ok := strings.Cut(field, ":")
+			if !ok {
+				continue
+			}
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 0 {
+				continue
+			}
+
+			switch {
+			case key == "p" && number <= maxPValue:
+				pValue, hasP = number, true
+			case key == "r" && number <= maxRValue:
+				rValue, hasR = number, true
+			}
+		}
+	}
+	return pValue, rValue, hasP, hasR
+}
+
+// updateOTelTraceState sets the p-value and the r-value in the `ot` entry of the W3C trace state, keeping
+// the other fields and entries. The updated entry is moved to the beginning, as required by the W3C spec.
+func updateOTelTraceState(traceState string, pValue int, rValue int) string {
+	otelFields := []string{"p:" + strconv.Itoa(pValue), "r:" + strconv.Itoa(rValue)}
+	var members []string
+
+	for _, member := range strings.Split(traceState, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		if !strings.HasPrefix(member, otelTraceStateKey+"=") {
+			members = append(members, member)
+			continue
+		}
+
+		for _, field := range strings.Split(strings.TrimPrefix(member, otelTraceStateKey+"="), ";") {
+			if field != "" && !strings.HasPrefix(field, "p:") && !strings.HasPrefix(field, "r:") {
+				otelFields = append(otelFields, field)
+			}
+		}
+	}
+
+	otelMember := otelTraceStateKey + "=" + strings.Join(otelFields, ";")
+	return strings.Join(append([]string{otelMember}, members...), ",")
+}
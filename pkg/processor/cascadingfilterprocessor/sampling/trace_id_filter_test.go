@@ -0,0 +1,152 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func newTraceIDTrace(traceID pcommon.TraceID, traceState string) *TraceData {
+	traces := ptrace.NewTraces()
+	span := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(traceID)
+	span.SetTraceState(ptrace.TraceState(traceState))
+
+	return &TraceData{
+		ReceivedBatches: []ptrace.Traces{traces},
+	}
+}
+
+func testTraceID(i uint64) pcommon.TraceID {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[8:], i)
+	return pcommon.NewTraceID(id)
+}
+
+func TestTraceIDProbabilisticFilterRatio(t *testing.T) {
+	for _, ratio := range []float64{0, -0.5, 1.5} {
+		_, err := NewTraceIDProbabilisticFilter(zap.NewNop(), ratio)
+		assert.EqualError(t, err, "trace ID sampling ratio must be greater than 0 and lower or equal 1")
+	}
+
+	cases := map[float64]float64{
+		1:    1,
+		0.5:  0.5,
+		0.3:  0.25,
+		0.2:  0.125,
+		0.01: 0.0078125,
+	}
+	for ratio, probability := range cases {
+		filter, err := NewTraceIDProbabilisticFilter(zap.NewNop(), ratio)
+		require.NoError(t, err)
+		assert.Equal(t, probability, filter.Probability())
+	}
+}
+
+func TestTraceIDProbabilisticFilterIsConsistent(t *testing.T) {
+	filter, err := NewTraceIDProbabilisticFilter(zap.NewNop(), 0.25)
+	require.NoError(t, err)
+	otherFilter, err := NewTraceIDProbabilisticFilter(zap.NewNop(), 0.25)
+	require.NoError(t, err)
+	lowerFilter, err := NewTraceIDProbabilisticFilter(zap.NewNop(), 0.0625)
+	require.NoError(t, err)
+
+	const total = 10000
+	sampled := 0
+	for i := uint64(0); i < total; i++ {
+		traceID := testTraceID(i)
+		decision := filter.Evaluate(traceID, newTraceIDTrace(traceID, ""))
+		assert.Equal(t, decision, otherFilter.Evaluate(traceID, newTraceIDTrace(traceID, "")))
+
+		// Traces selected with the lower probability are always selected with the higher one
+		if lowerFilter.Evaluate(traceID, newTraceIDTrace(traceID, "")) == Sampled {
+			assert.Equal(t, Sampled, decision)
+		}
+		if decision == Sampled {
+			sampled++
+		}
+	}
+
+	assert.InDelta(t, 0.25, float64(sampled)/total, 0.02)
+}
+
+func TestTraceIDProbabilisticFilterUsesTraceStateRValue(t *testing.T) {
+	filter, err := NewTraceIDProbabilisticFilter(zap.NewNop(), 0.25)
+	require.NoError(t, err)
+
+	traceID := testTraceID(1)
+	assert.Equal(t, Sampled, filter.Evaluate(traceID, newTraceIDTrace(traceID, "ot=r:2")))
+	assert.Equal(t, Sampled, filter.Evaluate(traceID, newTraceIDTrace(traceID, "vendor=abc,ot=p:1;r:5")))
+	assert.Equal(t, NotSampled, filter.Evaluate(traceID, newTraceIDTrace(traceID, "ot=r:1")))
+	assert.Equal(t, NotSampled, filter.Evaluate(traceID, newTraceIDTrace(traceID, "ot=p:0;r:0")))
+}
+
+func TestTraceIDProbabilisticFilterUpdateTraceState(t *testing.T) {
+	filter, err := NewTraceIDProbabilisticFilter(zap.NewNop(), 0.25)
+	require.NoError(t, err)
+
+	traceID := testTraceID(1)
+	rValue := rValueFromTraceID(traceID)
+
+	cases := []struct {
+		Desc     string
+		Input    string
+		Expected string
+	}{
+		{
+			Desc:     "empty trace state",
+			Input:    "",
+			Expected: "ot=p:2;r:" + strconv.Itoa(rValue),
+		},
+		{
+			Desc:     "r-value is kept",
+			Input:    "ot=r:4",
+			Expected: "ot=p:2;r:4",
+		},
+		{
+			Desc:     "higher p-value is kept",
+			Input:    "ot=p:3;r:4",
+			Expected: "ot=p:3;r:4",
+		},
+		{
+			Desc:     "lower p-value is replaced",
+			Input:    "ot=p:1;r:4",
+			Expected: "ot=p:2;r:4",
+		},
+		{
+			Desc:     "other entries and fields are kept",
+			Input:    "vendor=abc,ot=r:4;x:foo",
+			Expected: "ot=p:2;r:4;x:foo,vendor=abc",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			traces := newTraceIDTrace(traceID, c.Input).ReceivedBatches[0]
+			filter.UpdateTraceState(traces)
+
+			span := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+			assert.Equal(t, c.Expected, string(span.TraceState()))
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologichttpreceiver
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/config/confighttp"
+)
+
+// Config defines configuration for the receiver.
+type Config struct {
+	config.ReceiverSettings `mapstructure:",squash"`
+	// squash ensures fields are correctly decoded in embedded struct.
+	confighttp.HTTPServerSettings `mapstructure:",squash"`
+
+	// MaxLineSize is the maximum size in bytes of a single log line or
+	// a single metric sample in the request body. Longer lines make the whole
+	// request fail with 413 Request Entity Too Large.
+	MaxLineSize int `mapstructure:"max_line_size"`
+}
+
+// Validate checks if the receiver configuration is valid
+func (cfg *Config) Validate() error {
+	if err := cfg.ReceiverSettings.Validate(); err != nil {
+		return err
+	}
+	if cfg.Endpoint == "" {
+		return errors.New("endpoint cannot be empty")
+	}
+	if cfg.MaxLineSize <= 0 {
+		return errors.New("max_line_size has to be greater than 0")
+	}
+	return nil
+}
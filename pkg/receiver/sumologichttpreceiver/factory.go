@@ -0,0 +1,100 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologichttpreceiver
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+const (
+	// Value of "type" key in configuration.
+	typeStr        = "sumologic_http"
+	stabilityLevel = component.StabilityLevelAlpha
+
+	defaultEndpoint    = "0.0.0.0:8008"
+	defaultMaxLineSize = 1024 * 1024
+)
+
+// receivers holds the receiver instances created per configuration so that
+// the logs and the metrics pipelines using the same receiver configuration
+// share one HTTP server.
+var (
+	receiversLock sync.Mutex
+	receivers     = map[*Config]*sumologicHTTPReceiver{}
+)
+
+// NewFactory creates a factory for Sumo Logic HTTP receiver.
+func NewFactory() component.ReceiverFactory {
+	return component.NewReceiverFactory(
+		typeStr,
+		createDefaultConfig,
+		component.WithLogsReceiver(createLogsReceiver, stabilityLevel),
+		component.WithMetricsReceiver(createMetricsReceiver, stabilityLevel),
+	)
+}
+
+func createDefaultConfig() config.Receiver {
+	return &Config{
+		ReceiverSettings: config.NewReceiverSettings(config.NewComponentID(typeStr)),
+		HTTPServerSettings: confighttp.HTTPServerSettings{
+			Endpoint: defaultEndpoint,
+		},
+		MaxLineSize: defaultMaxLineSize,
+	}
+}
+
+func createLogsReceiver(
+	_ context.Context,
+	params component.ReceiverCreateSettings,
+	cfg config.Receiver,
+	consumer consumer.Logs,
+) (component.LogsReceiver, error) {
+	r := getOrCreateReceiver(cfg.(*Config), params)
+	r.logsConsumer = consumer
+	return r, nil
+}
+
+func createMetricsReceiver(
+	_ context.Context,
+	params component.ReceiverCreateSettings,
+	cfg config.Receiver,
+	consumer consumer.Metrics,
+) (component.MetricsReceiver, error) {
+	r := getOrCreateReceiver(cfg.(*Config), params)
+	r.metricsConsumer = consumer
+	return r, nil
+}
+
+func getOrCreateReceiver(cfg *Config, params component.ReceiverCreateSettings) *sumologicHTTPReceiver {
+	receiversLock.Lock()
+	defer receiversLock.Unlock()
+
+	r, ok := receivers[cfg]
+	if !ok {
+		r = newSumologicHTTPReceiver(cfg, params, func() {
+			receiversLock.Lock()
+			delete(receivers, cfg)
+			receiversLock.Unlock()
+		})
+		receivers[cfg] = r
+	}
+	return r
+}
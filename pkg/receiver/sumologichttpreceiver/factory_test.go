@@ -0,0 +1,65 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologichttpreceiver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+)
+
+func TestDefaultConfig(t *testing.T) {
+	cfg := createDefaultConfig()
+	rCfg, ok := cfg.(*Config)
+	require.True(t, ok)
+
+	assert.Equal(t, &Config{
+		ReceiverSettings: config.NewReceiverSettings(config.NewComponentID(typeStr)),
+		HTTPServerSettings: confighttp.HTTPServerSettings{
+			Endpoint: "0.0.0.0:8008",
+		},
+		MaxLineSize: 1024 * 1024,
+	}, rCfg)
+}
+
+func TestFactoryType(t *testing.T) {
+	assert.Equal(t, config.Type("sumologic_http"), NewFactory().Type())
+}
+
+func TestCreateReceiversShareInstance(t *testing.T) {
+	rCfg := createDefaultConfig().(*Config)
+	params := componenttest.NewNopReceiverCreateSettings()
+
+	lr, err := createLogsReceiver(context.Background(), params, rCfg, consumertest.NewNop())
+	require.NoError(t, err)
+	mr, err := createMetricsReceiver(context.Background(), params, rCfg, consumertest.NewNop())
+	require.NoError(t, err)
+
+	assert.Same(t, lr, mr)
+
+	require.NoError(t, lr.Shutdown(context.Background()))
+
+	// After shutdown a new instance is created for the same config.
+	lr2, err := createLogsReceiver(context.Background(), params, rCfg, consumertest.NewNop())
+	require.NoError(t, err)
+	assert.NotSame(t, lr, lr2)
+	require.NoError(t, lr2.Shutdown(context.Background()))
+}
@@ -0,0 +1,294 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologichttpreceiver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	headerHost     string = "X-Sumo-Host"
+	headerName     string = "X-Sumo-Name"
+	headerCategory string = "X-Sumo-Category"
+	headerFields   string = "X-Sumo-Fields"
+
+	// Those are the same attributes that sumologicexporter reads back
+	// when setting source related headers and fields.
+	attributeKeySourceHost     = "_sourceHost"
+	attributeKeySourceName     = "_sourceName"
+	attributeKeySourceCategory = "_sourceCategory"
+)
+
+// fillResourceAttributes translates Sumo Logic HTTP Source headers into
+// resource attributes.
+//
+// X-Sumo-Fields is expected to be a comma separated list of key=value pairs,
+// e.g. `cluster=prod, team=ops`.
+func fillResourceAttributes(h http.Header, attrs pcommon.Map) {
+	if v := h.Get(headerFields); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			key, value, ok := strings.Cut(field, "=")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				continue
+			}
+			attrs.UpsertString(key, strings.TrimSpace(value))
+		}
+	}
+
+	for header, attribute := range map[string]string{
+		headerHost:     attributeKeySourceHost,
+		headerName:     attributeKeySourceName,
+		headerCategory: attributeKeySourceCategory,
+	} {
+		if v := h.Get(header); v != "" {
+			attrs.UpsertString(attribute, v)
+		}
+	}
+}
+
+// scanLines calls f for every non empty line read from r.
+func scanLines(r io.Reader, maxLineSize int, f func(line string) error) error {
+	// The maximum token size is the larger of the initial buffer capacity
+	// and the max so make sure the buffer doesn't exceed the max.
+	initialSize := 64 * 1024
+	if maxLineSize < initialSize {
+		initialSize = maxLineSize
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, initialSize), maxLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := f(line); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return errLineTooLong
+		}
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	return nil
+}
+
+// parseLogs creates one log record for every line in the body.
+// For JSON format every line which is a valid JSON object becomes a map body,
+// other lines are kept as strings.
+func parseLogs(h http.Header, body io.Reader, format string, maxLineSize int) (plog.Logs, error) {
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	fillResourceAttributes(h, rl.Resource().Attributes())
+	logs := rl.ScopeLogs().AppendEmpty().LogRecords()
+
+	now := pcommon.NewTimestampFromTime(time.Now())
+	err := scanLines(body, maxLineSize, func(line string) error {
+		lr := logs.AppendEmpty()
+		lr.SetObservedTimestamp(now)
+
+		if format == formatJSON {
+			var m map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &m); err == nil {
+				v := pcommon.NewValueMap()
+				pcommon.NewMapFromRaw(m).CopyTo(v.MapVal())
+				v.CopyTo(lr.Body())
+				return nil
+			}
+		}
+
+		lr.Body().SetStringVal(line)
+		return nil
+	})
+	if err != nil {
+		return plog.Logs{}, err
+	}
+
+	return ld, nil
+}
+
+// parsePrometheusMetrics creates a gauge data point for every sample
+// in the body. Samples with the same name are grouped into one metric.
+func parsePrometheusMetrics(h http.Header, body io.Reader, maxLineSize int) (pmetric.Metrics, error) {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	fillResourceAttributes(h, rm.Resource().Attributes())
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+
+	byName := map[string]pmetric.Metric{}
+	now := pcommon.NewTimestampFromTime(time.Now())
+	err := scanLines(body, maxLineSize, func(line string) error {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			return nil
+		}
+
+		s, err := parsePrometheusLine(line)
+		if err != nil {
+			return err
+		}
+
+		m, ok := byName[s.name]
+		if !ok {
+			m = metrics.AppendEmpty()
+			m.SetName(s.name)
+			m.SetDataType(pmetric.MetricDataTypeGauge)
+			byName[s.name] = m
+		}
+
+		dp := m.Gauge().DataPoints().AppendEmpty()
+		dp.SetDoubleVal(s.value)
+		if s.timestamp != 0 {
+			dp.SetTimestamp(pcommon.NewTimestampFromTime(time.UnixMilli(s.timestamp)))
+		} else {
+			dp.SetTimestamp(now)
+		}
+		for _, l := range s.labels {
+			dp.Attributes().UpsertString(l.key, l.value)
+		}
+		return nil
+	})
+	if err != nil {
+		return pmetric.Metrics{}, err
+	}
+
+	return md, nil
+}
+
+type prometheusLabel struct {
+	key   string
+	value string
+}
+
+type prometheusSample struct {
+	name      string
+	labels    []prometheusLabel
+	value     float64
+	timestamp int64
+}
+
+// parsePrometheusLine parses a single sample line in Prometheus text format:
+//
+//	metric_name{label="value",other="value"} 1.5 1609459200000
+//
+// The timestamp is optional and is expressed in milliseconds.
+func parsePrometheusLine(line string) (prometheusSample, error) {
+	var s prometheusSample
+
+	line = strings.TrimSpace(line)
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd <= 0 {
+		return s, fmt.Errorf("invalid prometheus line: %q", line)
+	}
+	s.name = line[:nameEnd]
+	rest := line[nameEnd:]
+
+	if rest[0] == '{' {
+		labels, n, err := parsePrometheusLabels(rest)
+		if err != nil {
+			return s, fmt.Errorf("invalid prometheus line: %q: %w", line, err)
+		}
+		s.labels = labels
+		rest = rest[n:]
+	}
+
+	values := strings.Fields(rest)
+	if len(values) == 0 || len(values) > 2 {
+		return s, fmt.Errorf("invalid prometheus line: %q", line)
+	}
+
+	value, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid prometheus sample value in line %q: %w", line, err)
+	}
+	s.value = value
+
+	if len(values) == 2 {
+		ts, err := strconv.ParseInt(values[1], 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("invalid prometheus sample timestamp in line %q: %w", line, err)
+		}
+		s.timestamp = ts
+	}
+
+	return s, nil
+}
+
+// parsePrometheusLabels parses the labels block starting with `{` and returns
+// the labels together with the number of bytes consumed.
+func parsePrometheusLabels(s string) ([]prometheusLabel, int, error) {
+	var labels []prometheusLabel
+
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, errors.New("unterminated labels")
+		}
+		if s[i] == '}' {
+			return labels, i + 1, nil
+		}
+
+		eq := strings.IndexByte(s[i:], '=')
+		if eq <= 0 {
+			return nil, 0, errors.New("invalid label")
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 1
+
+		if i >= len(s) || s[i] != '"' {
+			return nil, 0, fmt.Errorf("label %s value is not quoted", key)
+		}
+		i++
+
+		var value strings.Builder
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("label %s value is not terminated", key)
+		}
+		i++
+
+		labels = append(labels, prometheusLabel{key: key, value: value.String()})
+	}
+}
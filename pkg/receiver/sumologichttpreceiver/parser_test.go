@@ -0,0 +1,91 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologichttpreceiver
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrometheusLine(t *testing.T) {
+	testcases := []struct {
+		line     string
+		expected prometheusSample
+	}{
+		{
+			line:     "up 1",
+			expected: prometheusSample{name: "up", value: 1},
+		},
+		{
+			line: `http_requests_total{method="post",code="200"} 1027 1395066363000`,
+			expected: prometheusSample{
+				name: "http_requests_total",
+				labels: []prometheusLabel{
+					{key: "method", value: "post"},
+					{key: "code", value: "200"},
+				},
+				value:     1027,
+				timestamp: 1395066363000,
+			},
+		},
+		{
+			line: `msdos_file_access_time_seconds{path="C:\\DIR\\FILE.TXT",error="Cannot find file:\n\"FILE.TXT\""} 1.458255915e9`,
+			expected: prometheusSample{
+				name: "msdos_file_access_time_seconds",
+				labels: []prometheusLabel{
+					{key: "path", value: `C:\DIR\FILE.TXT`},
+					{key: "error", value: "Cannot find file:\n\"FILE.TXT\""},
+				},
+				value: 1.458255915e9,
+			},
+		},
+		{
+			line: `histogram_bucket{le="+Inf",} +Inf`,
+			expected: prometheusSample{
+				name:   "histogram_bucket",
+				labels: []prometheusLabel{{key: "le", value: "+Inf"}},
+				value:  math.Inf(1),
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.line, func(t *testing.T) {
+			s, err := parsePrometheusLine(tc.line)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, s)
+		})
+	}
+}
+
+func TestParsePrometheusLineErrors(t *testing.T) {
+	for _, line := range []string{
+		"up",
+		"{a=\"b\"} 1",
+		`up{a="b" 1`,
+		`up{a=b} 1`,
+		"up one",
+		"up 1 now",
+		"up 1 2 3",
+	} {
+		t.Run(line, func(t *testing.T) {
+			_, err := parsePrometheusLine(line)
+			assert.Error(t, err)
+		})
+	}
+}
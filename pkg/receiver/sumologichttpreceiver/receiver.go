@@ -0,0 +1,269 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologichttpreceiver
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/obsreport"
+	"go.uber.org/zap"
+)
+
+const (
+	headerContentType     string = "Content-Type"
+	headerContentEncoding string = "Content-Encoding"
+
+	contentTypePrometheus string = "application/vnd.sumologic.prometheus"
+	contentTypeCarbon2    string = "application/vnd.sumologic.carbon2"
+	contentTypeGraphite   string = "application/vnd.sumologic.graphite"
+	contentTypeJSON       string = "application/json"
+	contentTypeNDJSON     string = "application/x-ndjson"
+
+	contentEncodingGzip     string = "gzip"
+	contentEncodingDeflate  string = "deflate"
+	contentEncodingIdentity string = "identity"
+
+	transportHTTP string = "http"
+	formatText    string = "text"
+	formatJSON    string = "json"
+	formatProm    string = "prometheus"
+)
+
+var (
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+	errLineTooLong         = errors.New("line exceeds max_line_size")
+)
+
+type sumologicHTTPReceiver struct {
+	cfg      *Config
+	settings component.ReceiverCreateSettings
+	logger   *zap.Logger
+	obsrecv  *obsreport.Receiver
+
+	logsConsumer    consumer.Logs
+	metricsConsumer consumer.Metrics
+
+	server       *http.Server
+	shutdownWG   sync.WaitGroup
+	startOnce    sync.Once
+	shutdownOnce sync.Once
+	onShutdown   func()
+}
+
+func newSumologicHTTPReceiver(
+	cfg *Config,
+	params component.ReceiverCreateSettings,
+	onShutdown func(),
+) *sumologicHTTPReceiver {
+	return &sumologicHTTPReceiver{
+		cfg:      cfg,
+		settings: params,
+		logger:   params.Logger,
+		obsrecv: obsreport.NewReceiver(obsreport.ReceiverSettings{
+			ReceiverID:             cfg.ID(),
+			Transport:              transportHTTP,
+			ReceiverCreateSettings: params,
+		}),
+		onShutdown: onShutdown,
+	}
+}
+
+// Start starts the HTTP server. It is called for every pipeline the receiver
+// is used in, but the server is started only once.
+func (r *sumologicHTTPReceiver) Start(_ context.Context, host component.Host) error {
+	var err error
+	r.startOnce.Do(func() {
+		err = r.startServer(host)
+	})
+	return err
+}
+
+func (r *sumologicHTTPReceiver) startServer(host component.Host) error {
+	listener, err := r.cfg.HTTPServerSettings.ToListener()
+	if err != nil {
+		return fmt.Errorf("failed to bind to address %s: %w", r.cfg.Endpoint, err)
+	}
+
+	r.server, err = r.cfg.HTTPServerSettings.ToServer(host, r.settings.TelemetrySettings, r)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("Starting HTTP server", zap.String("endpoint", r.cfg.Endpoint))
+	r.shutdownWG.Add(1)
+	go func() {
+		defer r.shutdownWG.Done()
+		if errHTTP := r.server.Serve(listener); errHTTP != nil && !errors.Is(errHTTP, http.ErrServerClosed) {
+			host.ReportFatalError(errHTTP)
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown stops the HTTP server.
+func (r *sumologicHTTPReceiver) Shutdown(ctx context.Context) error {
+	var err error
+	r.shutdownOnce.Do(func() {
+		if r.server != nil {
+			err = r.server.Shutdown(ctx)
+			r.shutdownWG.Wait()
+		}
+		if r.onShutdown != nil {
+			r.onShutdown()
+		}
+	})
+	return err
+}
+
+// ServeHTTP handles requests in the same way as Sumo Logic HTTP Source does.
+// The data format is taken from the Content-Type header: Prometheus metrics
+// are sent with application/vnd.sumologic.prometheus, while everything else
+// is treated as logs, one log record per line.
+func (r *sumologicHTTPReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := decompressedBody(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	defer body.Close()
+
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get(headerContentType))
+
+	switch contentType {
+	case contentTypePrometheus:
+		r.handleMetrics(w, req, body)
+	case contentTypeCarbon2, contentTypeGraphite:
+		http.Error(w, fmt.Sprintf("unsupported content type: %s", contentType), http.StatusUnsupportedMediaType)
+	case contentTypeJSON, contentTypeNDJSON:
+		r.handleLogs(w, req, body, formatJSON)
+	default:
+		r.handleLogs(w, req, body, formatText)
+	}
+}
+
+func (r *sumologicHTTPReceiver) handleLogs(w http.ResponseWriter, req *http.Request, body io.Reader, format string) {
+	if r.logsConsumer == nil {
+		http.Error(w, "logs are not supported by this receiver", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.obsrecv.StartLogsOp(req.Context())
+	ld, err := parseLogs(req.Header, body, format, r.cfg.MaxLineSize)
+	if err != nil {
+		r.obsrecv.EndLogsOp(ctx, format, 0, err)
+		writeParseError(w, err)
+		return
+	}
+
+	err = r.logsConsumer.ConsumeLogs(ctx, ld)
+	r.obsrecv.EndLogsOp(ctx, format, ld.LogRecordCount(), err)
+	if err != nil {
+		r.logger.Debug("Failed to consume logs", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *sumologicHTTPReceiver) handleMetrics(w http.ResponseWriter, req *http.Request, body io.Reader) {
+	if r.metricsConsumer == nil {
+		http.Error(w, "metrics are not supported by this receiver", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.obsrecv.StartMetricsOp(req.Context())
+	md, err := parsePrometheusMetrics(req.Header, body, r.cfg.MaxLineSize)
+	if err != nil {
+		r.obsrecv.EndMetricsOp(ctx, formatProm, 0, err)
+		writeParseError(w, err)
+		return
+	}
+
+	err = r.metricsConsumer.ConsumeMetrics(ctx, md)
+	r.obsrecv.EndMetricsOp(ctx, formatProm, md.DataPointCount(), err)
+	if err != nil {
+		r.logger.Debug("Failed to consume metrics", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeParseError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errLineTooLong) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// decompressedBody returns the request body decompressed according to the
+// Content-Encoding header.
+// Deflate payloads are accepted both with and without the zlib wrapper
+// because sumologicexporter sends raw deflate streams.
+func decompressedBody(req *http.Request) (io.ReadCloser, error) {
+	switch encoding := req.Header.Get(headerContentEncoding); encoding {
+	case "", contentEncodingIdentity:
+		return req.Body, nil
+
+	case contentEncodingGzip:
+		gr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip body: %w", err)
+		}
+		return gr, nil
+
+	case contentEncodingDeflate:
+		br := bufio.NewReader(req.Body)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read deflate body: %w", err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(br), nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedEncoding, encoding)
+	}
+}
+
+// isZlibHeader checks whether the provided bytes form a valid zlib header
+// as per RFC 1950: deflate compression method and a correct check sum.
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
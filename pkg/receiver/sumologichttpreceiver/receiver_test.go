@@ -0,0 +1,297 @@
+// Copyright 2022 Sumo Logic, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sumologichttpreceiver
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func newTestReceiver(t *testing.T) (*sumologicHTTPReceiver, *consumertest.LogsSink, *consumertest.MetricsSink) {
+	cfg := createDefaultConfig().(*Config)
+	r := newSumologicHTTPReceiver(cfg, componenttest.NewNopReceiverCreateSettings(), nil)
+
+	logsSink := new(consumertest.LogsSink)
+	metricsSink := new(consumertest.MetricsSink)
+	r.logsConsumer = logsSink
+	r.metricsConsumer = metricsSink
+
+	return r, logsSink, metricsSink
+}
+
+func compressBody(t *testing.T, encoding string, body string) io.Reader {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w, err = flate.NewWriter(&buf, flate.BestSpeed)
+		require.NoError(t, err)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	default:
+		return strings.NewReader(body)
+	}
+
+	_, err = w.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return &buf
+}
+
+func TestReceiverLogs(t *testing.T) {
+	testcases := []struct {
+		name            string
+		contentType     string
+		contentEncoding string
+		body            string
+		expectedBodies  []interface{}
+	}{
+		{
+			name:           "text",
+			body:           "first line\r\nsecond line\n\n",
+			expectedBodies: []interface{}{"first line", "second line"},
+		},
+		{
+			name:            "text gzip",
+			contentEncoding: "gzip",
+			body:            "first line\nsecond line",
+			expectedBodies:  []interface{}{"first line", "second line"},
+		},
+		{
+			name:            "text deflate",
+			contentEncoding: "deflate",
+			body:            "first line\nsecond line",
+			expectedBodies:  []interface{}{"first line", "second line"},
+		},
+		{
+			name:            "text deflate with zlib wrapper",
+			contentEncoding: "zlib",
+			body:            "first line\nsecond line",
+			expectedBodies:  []interface{}{"first line", "second line"},
+		},
+		{
+			name:        "json lines",
+			contentType: "application/json; charset=utf-8",
+			body:        "{\"level\":\"info\",\"count\":2}\nnot a json",
+			expectedBodies: []interface{}{
+				map[string]interface{}{"level": "info", "count": float64(2)},
+				"not a json",
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, logsSink, _ := newTestReceiver(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/receiver/v1/http/token", compressBody(t, tc.contentEncoding, tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			if tc.contentEncoding == "zlib" {
+				req.Header.Set("Content-Encoding", "deflate")
+			} else if tc.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tc.contentEncoding)
+			}
+			req.Header.Set("X-Sumo-Category", "my/category")
+			req.Header.Set("X-Sumo-Name", "my-name")
+			req.Header.Set("X-Sumo-Host", "my-host")
+			req.Header.Set("X-Sumo-Fields", "cluster=prod, team = ops,invalid")
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			require.Len(t, logsSink.AllLogs(), 1)
+			rl := logsSink.AllLogs()[0].ResourceLogs().At(0)
+			assert.Equal(t, map[string]interface{}{
+				"_sourceCategory": "my/category",
+				"_sourceName":     "my-name",
+				"_sourceHost":     "my-host",
+				"cluster":         "prod",
+				"team":            "ops",
+			}, rl.Resource().Attributes().AsRaw())
+
+			logs := rl.ScopeLogs().At(0).LogRecords()
+			require.Equal(t, len(tc.expectedBodies), logs.Len())
+			for i, expected := range tc.expectedBodies {
+				body := logs.At(i).Body()
+				if body.Type() == pcommon.ValueTypeMap {
+					assert.Equal(t, expected, body.MapVal().AsRaw())
+				} else {
+					assert.Equal(t, expected, body.StringVal())
+				}
+			}
+		})
+	}
+}
+
+func TestReceiverPrometheusMetrics(t *testing.T) {
+	r, _, metricsSink := newTestReceiver(t)
+
+	body := strings.Join([]string{
+		"# HELP cpu_usage CPU usage",
+		`cpu_usage{host="a",core="0"} 12.5 1609459200000`,
+		`cpu_usage{host="a",core="1"} 13.5 1609459200000`,
+		"up 1",
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/receiver/v1/http/token", compressBody(t, "gzip", body))
+	req.Header.Set("Content-Type", "application/vnd.sumologic.prometheus")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("X-Sumo-Category", "metrics/category")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	require.Len(t, metricsSink.AllMetrics(), 1)
+	md := metricsSink.AllMetrics()[0]
+	assert.Equal(t, 3, md.DataPointCount())
+
+	rm := md.ResourceMetrics().At(0)
+	assert.Equal(t, map[string]interface{}{
+		"_sourceCategory": "metrics/category",
+	}, rm.Resource().Attributes().AsRaw())
+
+	metrics := rm.ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 2, metrics.Len())
+
+	cpu := metrics.At(0)
+	assert.Equal(t, "cpu_usage", cpu.Name())
+	assert.Equal(t, pmetric.MetricDataTypeGauge, cpu.DataType())
+	require.Equal(t, 2, cpu.Gauge().DataPoints().Len())
+	dp := cpu.Gauge().DataPoints().At(1)
+	assert.Equal(t, 13.5, dp.DoubleVal())
+	assert.Equal(t, int64(1609459200000), dp.Timestamp().AsTime().UnixMilli())
+	assert.Equal(t, map[string]interface{}{"host": "a", "core": "1"}, dp.Attributes().AsRaw())
+
+	assert.Equal(t, "up", metrics.At(1).Name())
+}
+
+func TestReceiverErrors(t *testing.T) {
+	testcases := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		body         string
+		setup        func(r *sumologicHTTPReceiver)
+		expectedCode int
+	}{
+		{
+			name:         "wrong method",
+			method:       http.MethodGet,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "unsupported encoding",
+			headers:      map[string]string{"Content-Encoding": "br"},
+			body:         "log",
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "unsupported content type",
+			headers:      map[string]string{"Content-Type": "application/vnd.sumologic.carbon2"},
+			body:         "metric=cpu 1 1609459200",
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "invalid prometheus",
+			headers:      map[string]string{"Content-Type": "application/vnd.sumologic.prometheus"},
+			body:         `cpu{host="a} 1`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "line too long",
+			body: strings.Repeat("a", 20),
+			setup: func(r *sumologicHTTPReceiver) {
+				r.cfg.MaxLineSize = 10
+			},
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "metrics not configured",
+			setup: func(r *sumologicHTTPReceiver) {
+				r.metricsConsumer = nil
+			},
+			headers:      map[string]string{"Content-Type": "application/vnd.sumologic.prometheus"},
+			body:         "up 1",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "consumer error",
+			setup: func(r *sumologicHTTPReceiver) {
+				r.logsConsumer = consumertest.NewErr(errors.New("pipeline is full"))
+			},
+			body:         "log",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _, _ := newTestReceiver(t)
+			if tc.setup != nil {
+				tc.setup(r)
+			}
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/", strings.NewReader(tc.body))
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestReceiverStartShutdown(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = "127.0.0.1:0"
+	r := newSumologicHTTPReceiver(cfg, componenttest.NewNopReceiverCreateSettings(), nil)
+
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+	// Starting the receiver for the second pipeline is a no-op.
+	require.NoError(t, r.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, r.Shutdown(context.Background()))
+	require.NoError(t, r.Shutdown(context.Background()))
+}